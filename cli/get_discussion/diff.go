@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/textdiff"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
+)
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var (
+		outputDir  string
+		sortKey    string
+		limit      int
+		sourceList string
+		unified    bool
+		write      bool
+		rescan     bool
+		layout     storage.Options
+		delay      float64
+		verbose    bool
+	)
+	fs.StringVar(&outputDir, "output-dir", "discussion", "Directory of the saved discussions.")
+	fs.StringVar(&sortKey, "sort", "hotness", "Listing sort used when no URL is given.")
+	fs.IntVar(&limit, "limit", 10, "Threads to compare when no URL is given.")
+	fs.StringVar(&sourceList, "sources", "api,html", "Fallback order of discussion sources: api, html, archive.")
+	fs.BoolVar(&unified, "unified", false, "Show a unified diff of the files instead of a comment-level summary.")
+	fs.BoolVar(&write, "write", false, "Apply the update to the saved files, as --update does.")
+	fs.BoolVar(&rescan, "rescan", false, "Scan --output-dir for files added, moved or deleted by hand instead of trusting manifest.json.")
+	fs.StringVar(&layout.FilenameTemplate, "filename-template", storage.DefaultFilenameTemplate, "Path of each thread under --output-dir, used by --write.")
+	fs.BoolVar(&layout.Fsync, "fsync", false, "Flush each file written by --write to disk.")
+	fs.Float64Var(&delay, "delay", 0.5, "Delay in seconds between requests.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose logging.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: get_discussion diff [flags] [URL...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if err := storage.ValidateTemplate(layout.FilenameTemplate); err != nil {
+		log.Fatal(err)
+	}
+	c := client.NewClient(verbose)
+	sources, err := discussion.ParseSources(sourceList, c, discussion.SourceOptions{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	urls := fs.Args()
+	if len(urls) == 0 {
+		target, err := selectForum(c, 0, "", "", "", os.Getenv("COMPETITION"), sortKey, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		urls = listForum(c, sources, target, sortKey, "", limit, api.TopicFilter{})
+	}
+	if len(urls) == 0 {
+		log.Fatal("No discussions to compare")
+	}
+	if write {
+		lock := lockOutput(outputDir)
+		defer lock.Unlock()
+	}
+	manifest := storage.LoadManifest(outputDir, rescan)
+	existingByLink := manifest.Links()
+
+	d := time.Duration(float64(time.Second) * delay)
+	for item := range source.IterDiscussions(urls, sources, d) {
+		td, err := storage.DiffDiscussion(item, outputDir, existingByLink, layout)
+		if err != nil {
+			log.Printf("[warn] Skipping %s: %v", item.Link, err)
+			continue
+		}
+		name := td.Path
+		if name == "" {
+			name = item.Link
+		}
+		fmt.Printf("%s: %s\n", name, td.Changes)
+		for _, c := range td.Conflicts {
+			fmt.Printf("  ! %s\n", c)
+		}
+
+		if unified {
+			fmt.Print(textdiff.Unified("a/"+name, "b/"+name, td.Old, td.New, 3))
+		} else {
+			printCommentChanges(td.Comments)
+		}
+
+		if write && td.Changes != (storage.Changes{}) {
+			if err := td.Apply(); err != nil {
+				log.Printf("[warn] Failed to save %s: %v", item.Link, err)
+			} else if err := manifest.Record(item, td.Path); err != nil {
+				log.Printf("[warn] Failed to record %s in the manifest: %v", td.Path, err)
+			}
+		}
+	}
+	if write {
+		if err := manifest.Save(layout.Fsync); err != nil {
+			log.Printf("[warn] Failed to write manifest: %v", err)
+		}
+	}
+}
+
+// printCommentChanges lists new replies, edits, deletions and vote changes.
+func printCommentChanges(changes []storage.CommentChange) {
+	for _, ch := range changes {
+		who := ch.Author
+		if who == "" {
+			who = "Unknown"
+		}
+		switch ch.Kind {
+		case storage.CommentNew:
+			fmt.Printf("  + #%d %s: %s\n", ch.ID, who, excerpt(ch.Body))
+		case storage.CommentEdited:
+			fmt.Printf("  ~ #%d %s: %s\n", ch.ID, who, excerpt(ch.Body))
+		case storage.CommentDeleted:
+			fmt.Printf("  - #%d %s\n", ch.ID, who)
+		case storage.CommentVotes:
+			fmt.Printf("  ^ #%d %s: votes %d -> %d\n", ch.ID, who, ch.OldVotes, ch.Votes)
+		}
+	}
+}
+
+// excerpt returns the first line of body, shortened to 80 characters.
+func excerpt(body string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(body), "\n")
+	if r := []rune(line); len(r) > 80 {
+		return string(r[:77]) + "..."
+	}
+	return line
+}
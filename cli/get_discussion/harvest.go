@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/solutions"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// harvestTopicLimit caps the forum topics scanned for "Nth place" titles.
+// They are read most voted first, and write-ups are among a forum's most
+// voted threads, so this reaches them without paging a large forum to the
+// end.
+const harvestTopicLimit = 500
+
+func runHarvest(args []string) {
+	fs := flag.NewFlagSet("harvest", flag.ExitOnError)
+	var (
+		competition string
+		outputDir   string
+		delay       float64
+		verbose     bool
+	)
+	fs.StringVar(&competition, "competition", os.Getenv("COMPETITION"), "Competition slug (default $COMPETITION).")
+	fs.StringVar(&outputDir, "output-dir", "discussion", "Root directory; files go to <output-dir>/solutions/<competition>.")
+	fs.Float64Var(&delay, "delay", 0.5, "Delay in seconds between requests.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose logging.")
+	fs.Parse(args)
+
+	if competition == "" {
+		log.Fatal("--competition or COMPETITION is required")
+	}
+
+	c := client.NewClient(verbose)
+	candidates := collectSolutions(c, competition)
+	if len(candidates) == 0 {
+		log.Fatalf("No solution write-ups found for competition=%s", competition)
+	}
+
+	urls := make([]string, 0, len(candidates))
+	for link := range candidates {
+		urls = append(urls, link)
+	}
+	sort.Strings(urls)
+	dir := filepath.Join(outputDir, storage.SolutionsDir, competition)
+	lock := lockOutput(dir)
+	manifest := storage.LoadManifest(dir, false)
+	existingByLink := manifest.Links()
+	d := time.Duration(float64(time.Second) * delay)
+
+	var entries []solutions.Entry
+	for item := range source.IterDiscussions(urls, discussion.DefaultSources(c), d) {
+		entry := candidateFor(candidates, item.Link)
+		entry.Title = item.Title
+		entry.Link = item.Link
+		if entry.Placement == 0 {
+			entry.Placement, _ = solutions.ParsePlacement(item.Title)
+		}
+		if entry.Team == "" {
+			entry.Team = solutions.ParseTeam(item.Title)
+		}
+		if entry.Team == "" {
+			entry.Team = item.Author
+		}
+		entry.Code, entry.Notebooks = solutions.ExtractLinks(item.ContentMD)
+
+		if item.Extra == nil {
+			item.Extra = map[string]any{}
+		}
+		item.Extra["competition"] = competition
+		item.Extra["team"] = entry.Team
+		item.Extra["code_links"] = entry.Code
+		item.Extra["notebook_links"] = entry.Notebooks
+		if entry.Placement > 0 {
+			item.Extra["placement"] = entry.Placement
+		}
+
+		path, err := storage.SaveDiscussion(item, dir, existingByLink, storage.Options{})
+		if err != nil {
+			log.Printf("[warn] Failed to save %s: %v", item.Link, err)
+			continue
+		}
+		if err := manifest.Record(item, path); err != nil {
+			log.Printf("[warn] Failed to record %s in the manifest: %v", path, err)
+		}
+		entry.Path = path
+		entries = append(entries, entry)
+		fmt.Println(path)
+	}
+
+	if err := manifest.Save(false); err != nil {
+		log.Printf("[warn] Failed to write manifest: %v", err)
+	}
+	indexPath := filepath.Join(dir, "index.md")
+	err := storage.WriteFileAtomic(indexPath, []byte(solutions.BuildIndex(competition, dir, entries)), 0o644, false)
+	lock.Unlock()
+	if err != nil {
+		log.Fatalf("Failed to write index: %v", err)
+	}
+	fmt.Println(indexPath)
+}
+
+// collectSolutions gathers write-up links keyed by canonical URL from the
+// write-ups API and from "Nth place" threads in the competition forum,
+// falling back to a forum search.
+func collectSolutions(c *client.Client, competition string) map[string]solutions.Entry {
+	candidates := map[string]solutions.Entry{}
+
+	writeUps, err := api.FetchCompetitionWriteUps(c, competition)
+	if err != nil {
+		log.Printf("[warn] Write-ups API failed: %v", err)
+	}
+	for _, w := range writeUps {
+		candidates[urlutil.CanonicalizeURL(w.URL)] = solutions.Entry{Placement: w.Placement, Team: w.TeamName, Title: w.Title}
+	}
+
+	forumID, err := api.FetchCompetitionForumID(c, competition)
+	if err != nil {
+		log.Printf("[warn] Competition API failed: %v", err)
+	} else {
+		topics, err := api.FetchTopicListByForumID(c, forumID, "most_votes", "", harvestTopicLimit, api.TopicFilter{})
+		if err != nil {
+			log.Printf("[warn] Topic list API failed: %v", err)
+		}
+		for _, t := range topics {
+			link := urlutil.CanonicalizeURL(t.Link)
+			if _, ok := candidates[link]; ok || !solutions.IsSolutionTitle(t.Title) {
+				continue
+			}
+			candidates[link] = solutions.Entry{Title: t.Title}
+		}
+	}
+
+	if len(candidates) == 0 {
+		urls, err := api.SearchDiscussions(c, "place solution", competition, 0)
+		if err != nil {
+			log.Printf("[warn] Search API failed: %v", err)
+		}
+		for _, u := range urls {
+			candidates[urlutil.CanonicalizeURL(u)] = solutions.Entry{}
+		}
+	}
+	return candidates
+}
+
+// candidateFor returns the candidate a fetched thread came from. The fetched
+// link can differ from the listed one, e.g. a write-up URL that resolves to
+// its topic, so it falls back to matching the topic ID.
+func candidateFor(candidates map[string]solutions.Entry, link string) solutions.Entry {
+	link = urlutil.CanonicalizeURL(link)
+	if entry, ok := candidates[link]; ok {
+		return entry
+	}
+	id, ok := urlutil.ExtractTopicID(link)
+	if !ok {
+		return solutions.Entry{}
+	}
+	for candidate, entry := range candidates {
+		if cid, ok := urlutil.ExtractTopicID(candidate); ok && cid == id {
+			return entry
+		}
+	}
+	return solutions.Entry{}
+}
@@ -13,8 +13,20 @@ const (
 	apiMessagesURL    = "https://www.kaggle.com/api/i/discussions.DiscussionsService/GetForumMessagesInTopic"
 	apiCompetitionURL = "https://www.kaggle.com/api/i/competitions.CompetitionService/GetCompetition"
 	apiTopicListURL   = "https://www.kaggle.com/api/i/discussions.DiscussionsService/GetTopicListByForumId"
+	apiDatasetURL     = "https://www.kaggle.com/api/i/datasets.DatasetService/GetDatasetByUrl"
+	apiForumURL       = "https://www.kaggle.com/api/i/discussions.DiscussionsService/GetForum"
+	apiModelURL       = "https://www.kaggle.com/api/i/models.ModelService/GetModel"
+	apiWriteUpsURL    = "https://www.kaggle.com/api/i/competitions.WriteUpService/ListWriteUps"
+	apiSearchURL      = "https://www.kaggle.com/api/i/search.SearchWebService/FullSearchWeb"
 )
 
+const searchPageSize = 20
+
+// maxSearchPages bounds SearchDiscussions. The search index spans all of
+// Kaggle, so with a competition filter and no limit it would otherwise page
+// through every topic that mentions the query.
+const maxSearchPages = 25
+
 func FetchTopicData(c *client.Client, topicID int) (*TopicResponse, error) {
 	params := url.Values{"forumTopicId": {fmt.Sprint(topicID)}}
 	var resp TopicResponse
@@ -50,8 +62,82 @@ func FetchCompetitionForumID(c *client.Client, competition string) (int, error)
 	return *resp.ForumID, nil
 }
 
-func FetchTopicListByForumID(c *client.Client, forumID int, sortKey, timeKey string, limit int) ([]string, error) {
-	var allURLs []string
+// FetchCompetitionWriteUps lists the solution write-ups published for a
+// competition. Links are canonical absolute URLs.
+func FetchCompetitionWriteUps(c *client.Client, competition string) ([]WriteUp, error) {
+	params := url.Values{"competitionName": {competition}}
+	var resp WriteUpsResponse
+	if err := c.FetchJSON(apiWriteUpsURL, params, &resp); err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse("https://www.kaggle.com")
+	var out []WriteUp
+	for _, w := range resp.WriteUps {
+		raw := urlutil.FirstNonEmpty(w.TopicURL, w.URL)
+		if raw == "" {
+			continue
+		}
+		ref, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		w.URL = urlutil.CanonicalizeURL(base.ResolveReference(ref).String())
+		out = append(out, w)
+	}
+	c.LogInfo("Write-ups API ok competition=%s count=%d", competition, len(out))
+	return out, nil
+}
+
+// FetchDatasetForumID resolves the discussion forum of a dataset.
+func FetchDatasetForumID(c *client.Client, owner, slug string) (int, error) {
+	params := url.Values{"ownerSlug": {owner}, "datasetSlug": {slug}}
+	var resp ForumRefResponse
+	if err := c.FetchJSON(apiDatasetURL, params, &resp); err != nil {
+		return 0, err
+	}
+	id := resp.forumID()
+	if id == 0 {
+		return 0, fmt.Errorf("forumId missing for dataset=%s/%s", owner, slug)
+	}
+	c.LogInfo("Dataset API ok dataset=%s/%s forumId=%d", owner, slug, id)
+	return id, nil
+}
+
+// FetchModelForumID resolves the discussion forum of a model.
+func FetchModelForumID(c *client.Client, owner, slug string) (int, error) {
+	params := url.Values{"ownerSlug": {owner}, "modelSlug": {slug}}
+	var resp ForumRefResponse
+	if err := c.FetchJSON(apiModelURL, params, &resp); err != nil {
+		return 0, err
+	}
+	id := resp.forumID()
+	if id == 0 {
+		return 0, fmt.Errorf("forumId missing for model=%s/%s", owner, slug)
+	}
+	c.LogInfo("Model API ok model=%s/%s forumId=%d", owner, slug, id)
+	return id, nil
+}
+
+// FetchForumIDBySlug resolves a site-wide forum such as "general".
+func FetchForumIDBySlug(c *client.Client, slug string) (int, error) {
+	params := url.Values{"forumSlug": {slug}}
+	var resp ForumRefResponse
+	if err := c.FetchJSON(apiForumURL, params, &resp); err != nil {
+		return 0, err
+	}
+	id := resp.forumID()
+	if id == 0 {
+		return 0, fmt.Errorf("forumId missing for forum=%s", slug)
+	}
+	c.LogInfo("Forum API ok forum=%s forumId=%d", slug, id)
+	return id, nil
+}
+
+// FetchTopicListByForumID pages through a forum listing and returns the topics
+// accepted by filter, up to limit (0 means no limit).
+func FetchTopicListByForumID(c *client.Client, forumID int, sortKey, timeKey string, limit int, filter TopicFilter) ([]TopicSummary, error) {
+	var topics []TopicSummary
+	seen := 0
 	total := -1
 
 	for page := 1; ; page++ {
@@ -68,7 +154,7 @@ func FetchTopicListByForumID(c *client.Client, forumID int, sortKey, timeKey str
 
 		var resp TopicListResponse
 		if err := c.FetchJSON(apiTopicListURL, params, &resp); err != nil {
-			return allURLs, err
+			return topics, err
 		}
 		c.LogInfo("Topic list API ok forum_id=%d page=%d count=%d", forumID, page, resp.Count)
 
@@ -79,29 +165,87 @@ func FetchTopicListByForumID(c *client.Client, forumID int, sortKey, timeKey str
 			break
 		}
 
-		for _, t := range resp.Topics {
-			raw := t.TopicURL
-			if raw == "" {
-				raw = t.URL
+		for _, item := range resp.Topics {
+			seen++
+			t, ok := newTopicSummary(item)
+			if !ok {
+				continue
 			}
-			if raw == "" {
+			if !t.Matches(filter) {
+				c.LogInfo("Filtered out topic %s votes=%d comments=%d", t.Link, t.Votes, t.Comments)
+				continue
+			}
+			topics = append(topics, t)
+		}
+
+		if limit > 0 && len(topics) >= limit {
+			topics = topics[:limit]
+			break
+		}
+
+		if total >= 0 && seen >= total {
+			break
+		}
+	}
+	return topics, nil
+}
+
+// SearchDiscussions returns topic URLs matching query, reading at most
+// maxSearchPages pages. When competition is set only topics from that
+// competition's forum are kept.
+func SearchDiscussions(c *client.Client, query, competition string, limit int) ([]string, error) {
+	var allURLs []string
+	seen := map[string]struct{}{}
+
+	for page := 1; page <= maxSearchPages; page++ {
+		var resp SearchResponse
+		if err := c.PostJSONDecode(apiSearchURL, map[string]any{
+			"query":          query,
+			"page":           page,
+			"resultsPerPage": searchPageSize,
+			"showPrivate":    false,
+			"filters": map[string]any{
+				"query":         query,
+				"documentTypes": []string{"TOPIC"},
+			},
+		}, &resp); err != nil {
+			return allURLs, err
+		}
+		c.LogInfo("Search API ok query=%q page=%d count=%d", query, page, len(resp.Documents))
+		if len(resp.Documents) == 0 {
+			break
+		}
+
+		for _, d := range resp.Documents {
+			if d.URL == "" || !urlutil.IsDiscussionURL(d.URL) {
+				continue
+			}
+			if competition != "" && !urlutil.InCompetitionForum(d.URL, competition) {
 				continue
 			}
 			abs, _ := url.Parse("https://www.kaggle.com")
-			ref, err := url.Parse(raw)
-			if err == nil {
-				allURLs = append(allURLs, urlutil.CanonicalizeURL(abs.ResolveReference(ref).String()))
+			ref, err := url.Parse(d.URL)
+			if err != nil {
+				continue
+			}
+			link := urlutil.CanonicalizeURL(abs.ResolveReference(ref).String())
+			if _, ok := seen[link]; ok {
+				continue
 			}
+			seen[link] = struct{}{}
+			allURLs = append(allURLs, link)
 		}
 
 		if limit > 0 && len(allURLs) >= limit {
 			allURLs = allURLs[:limit]
 			break
 		}
-
-		if total >= 0 && len(allURLs) >= total {
+		if len(resp.Documents) < searchPageSize {
 			break
 		}
+		if page == maxSearchPages {
+			c.LogInfo("Search API stopped at %d pages query=%q", page, query)
+		}
 	}
 	return allURLs, nil
 }
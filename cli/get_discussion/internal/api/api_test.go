@@ -3,6 +3,9 @@ package api
 import (
 	"encoding/json"
 	"testing"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/schema"
 )
 
 func TestTopicResponseUnmarshal(t *testing.T) {
@@ -43,3 +46,86 @@ func TestMessagesResponseUnmarshal(t *testing.T) {
 		t.Fatalf("nested author missing: %+v", resp.Comments[1].User)
 	}
 }
+
+func TestTopicSummaryFilter(t *testing.T) {
+	payload := []byte(`{"count":3,"topics":[
+		{"id":1,"title":"A","topicUrl":"/discussion/1","authorUserName":"alice","votes":12,"commentCount":4,"postDate":"2024-03-01T00:00:00Z","isSticky":true},
+		{"id":2,"title":"B","url":"/discussion/2","authorUserName":"bob","votes":30,"totalMessages":9,"postDate":"2024-02-01T00:00:00Z","lastCommentPostDate":"2024-03-05T10:00:00.5Z"},
+		{"id":3,"title":"C","url":"/discussion/3","authorUserName":"bob","votes":1,"postDate":"2024-03-02T00:00:00Z"}]}`)
+	var resp TopicListResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	var topics []TopicSummary
+	for _, item := range resp.Topics {
+		s, ok := newTopicSummary(item)
+		if !ok {
+			t.Fatalf("summary rejected: %+v", item)
+		}
+		topics = append(topics, s)
+	}
+	if topics[1].Comments != 8 || topics[1].Link != "https://www.kaggle.com/discussion/2" {
+		t.Fatalf("unexpected summary: %+v", topics[1])
+	}
+
+	since, _ := ParseFilterTime("2024-03-01")
+	f := TopicFilter{MinVotes: 5, Since: since, ExcludePinned: true, Author: "BOB"}
+	var kept []int
+	for _, s := range topics {
+		if s.Matches(f) {
+			kept = append(kept, s.ID)
+		}
+	}
+	if len(kept) != 1 || kept[0] != 2 {
+		t.Fatalf("unexpected filter result: %v", kept)
+	}
+	if (TopicFilter{}).Active() || !f.Active() {
+		t.Fatalf("unexpected Active result")
+	}
+}
+
+func TestParseFilterUntilIsInclusive(t *testing.T) {
+	until, err := ParseFilterUntil("2024-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := TopicFilter{Until: until}
+	late := TopicSummary{LastActivity: time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC)}
+	next := TopicSummary{LastActivity: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)}
+	if !late.Matches(f) || next.Matches(f) {
+		t.Fatalf("until should cover the whole day: %v", until)
+	}
+	exact, _ := ParseFilterUntil("2024-03-01T12:00:00Z")
+	if late.Matches(TopicFilter{Until: exact}) {
+		t.Fatalf("timestamps are exact bounds")
+	}
+}
+
+func TestVoteCountUnmarshal(t *testing.T) {
+	var comments []ForumComment
+	data := `[{"id":1,"votes":4},{"id":2,"votes":{"totalVotes":7}},{"id":3,"votes":null},{"id":4}]`
+	if err := json.Unmarshal([]byte(data), &comments); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	for i, want := range []VoteCount{4, 7, 0, 0} {
+		if comments[i].Votes != want {
+			t.Fatalf("comment %d: votes %d, want %d", i, comments[i].Votes, want)
+		}
+	}
+}
+
+func TestModelsAcceptAlternativeFields(t *testing.T) {
+	for _, c := range []struct {
+		doc  string
+		dest any
+	}{
+		{`{"comments":[{"id":1,"rawMarkdown":"x","content":"x","authorUserName":"a","votes":{"totalVotes":2}}]}`, &MessagesResponse{}},
+		{`{"forum":{"id":5}}`, &ForumRefResponse{}},
+		{`{"messages":[{"id":1,"forumTopicId":2,"forumTopicName":"t","topicUrl":"/t/2","isTopicStarter":true}]}`, &UserMessagesResponse{}},
+		{`{"writeUps":[{"title":"1st","topicUrl":"/t/3","teamName":"x"}]}`, &WriteUpsResponse{}},
+	} {
+		if drifts, err := schema.Check([]byte(c.doc), c.dest); err != nil || len(drifts) != 0 {
+			t.Errorf("%s: unexpected drifts: %+v %v", c.doc, drifts, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+)
+
+// Public Kaggle API endpoints; these require an authenticated client.
+const (
+	apiV1Base              = "https://www.kaggle.com/api/v1"
+	apiSubmissionURLFmt    = apiV1Base + "/competitions/%s/submissions/url/%d/%d"
+	apiSubmitFmt           = apiV1Base + "/competitions/submissions/submit/%s"
+	apiSubmissionsListFmt  = apiV1Base + "/competitions/submissions/list/%s"
+	apiLeaderboardViewFmt  = apiV1Base + "/competitions/%s/leaderboard/view"
+	apiCompetitionsListURL = apiV1Base + "/competitions/list"
+)
+
+// CreateSubmissionUpload requests a pre-signed upload URL for a submission file.
+func CreateSubmissionUpload(c *client.Client, competition, path string, size, lastModified int64) (*SubmissionUploadResponse, error) {
+	endpoint := fmt.Sprintf(apiSubmissionURLFmt, url.PathEscape(competition), size, lastModified)
+	var resp SubmissionUploadResponse
+	form := url.Values{"fileName": {filepath.Base(path)}}
+	if err := c.PostFormDecode(endpoint, form, &resp); err != nil {
+		return nil, err
+	}
+	if resp.CreateURL == "" || resp.Token == "" {
+		return nil, fmt.Errorf("upload url missing for competition=%s", competition)
+	}
+	c.LogInfo("Submission upload url ok competition=%s", competition)
+	return &resp, nil
+}
+
+// SubmitCompetition registers an uploaded file as a submission with message.
+func SubmitCompetition(c *client.Client, competition, token, message string) (*SubmitResponse, error) {
+	endpoint := fmt.Sprintf(apiSubmitFmt, url.PathEscape(competition))
+	form := url.Values{
+		"blobFileTokens":        {token},
+		"submissionDescription": {message},
+	}
+	var resp SubmitResponse
+	if err := c.PostFormDecode(endpoint, form, &resp); err != nil {
+		return nil, err
+	}
+	c.LogInfo("Submit ok competition=%s ref=%d", competition, resp.Ref)
+	return &resp, nil
+}
+
+// maxSubmissionPages bounds ListSubmissions. Kaggle caps daily submissions,
+// so even long competitions stay well below it; polls only need the first
+// page anyway, see ListRecentSubmissions.
+const maxSubmissionPages = 20
+
+// ListSubmissions returns the team's submissions, most recent first, reading
+// at most maxSubmissionPages pages.
+func ListSubmissions(c *client.Client, competition string) ([]Submission, error) {
+	var all []Submission
+	for page := 1; page <= maxSubmissionPages; page++ {
+		resp, err := fetchSubmissionsPage(c, competition, page)
+		if err != nil {
+			return all, err
+		}
+		if len(resp) == 0 {
+			break
+		}
+		all = append(all, resp...)
+		if page == maxSubmissionPages {
+			c.LogInfo("Submissions API stopped at %d pages competition=%s", page, competition)
+		}
+	}
+	return all, nil
+}
+
+// ListRecentSubmissions returns the first page of the team's submissions,
+// most recent first. It holds the latest submission and those of today.
+func ListRecentSubmissions(c *client.Client, competition string) ([]Submission, error) {
+	return fetchSubmissionsPage(c, competition, 1)
+}
+
+func fetchSubmissionsPage(c *client.Client, competition string, page int) ([]Submission, error) {
+	endpoint := fmt.Sprintf(apiSubmissionsListFmt, url.PathEscape(competition))
+	var resp []Submission
+	if err := c.FetchJSON(endpoint, url.Values{"page": {fmt.Sprint(page)}}, &resp); err != nil {
+		return nil, err
+	}
+	c.LogInfo("Submissions API ok competition=%s page=%d count=%d", competition, page, len(resp))
+	return resp, nil
+}
+
+// FetchLeaderboard returns the public leaderboard, best first.
+func FetchLeaderboard(c *client.Client, competition string) ([]LeaderboardEntry, error) {
+	endpoint := fmt.Sprintf(apiLeaderboardViewFmt, url.PathEscape(competition))
+	var resp LeaderboardResponse
+	if err := c.FetchJSON(endpoint, nil, &resp); err != nil {
+		return nil, err
+	}
+	c.LogInfo("Leaderboard API ok competition=%s count=%d", competition, len(resp.Submissions))
+	return resp.Submissions, nil
+}
+
+// FetchCompetitionInfo looks up a competition by slug through the listing search.
+func FetchCompetitionInfo(c *client.Client, competition string) (*CompetitionInfo, error) {
+	var resp []CompetitionInfo
+	params := url.Values{"search": {competition}}
+	if err := c.FetchJSON(apiCompetitionsListURL, params, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp {
+		if competitionSlug(resp[i].Ref) == competition {
+			return &resp[i], nil
+		}
+	}
+	return nil, fmt.Errorf("competition not found: %s", competition)
+}
+
+// competitionSlug reduces a competition ref (slug or full URL) to its slug.
+func competitionSlug(ref string) string {
+	ref = strings.TrimRight(ref, "/")
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		return ref[i+1:]
+	}
+	return ref
+}
@@ -1,5 +1,7 @@
 package api
 
+import "encoding/json"
+
 type TopicResponse struct {
 	ForumTopic struct {
 		Name                  string `json:"name"`
@@ -20,25 +22,160 @@ type ForumComment struct {
 	ID                    int              `json:"id"`
 	RawMarkdown           string           `json:"rawMarkdown"`
 	Content               string           `json:"content"`
-	AuthorUserDisplayName string           `json:"authorUserDisplayName"`
-	AuthorUserName        string           `json:"authorUserName"`
-	User                  ForumCommentUser `json:"user"`
+	AuthorUserDisplayName string           `json:"authorUserDisplayName" schema:"alt=authorUserName|user"`
+	AuthorUserName        string           `json:"authorUserName" schema:"alt=authorUserDisplayName|user"`
+	User                  ForumCommentUser `json:"user" schema:"alt=authorUserName|authorUserDisplayName"`
+	Votes                 VoteCount        `json:"votes"`
+}
+
+// VoteCount is a vote total sent either as a number or, in page state, as an
+// object such as {"totalVotes": 3}.
+type VoteCount int
+
+func (v *VoteCount) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*v = VoteCount(n)
+		return nil
+	}
+	var obj struct {
+		TotalVotes *int `json:"totalVotes"`
+		Score      *int `json:"score"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	switch {
+	case obj.TotalVotes != nil:
+		*v = VoteCount(*obj.TotalVotes)
+	case obj.Score != nil:
+		*v = VoteCount(*obj.Score)
+	}
+	return nil
 }
 
 type ForumCommentUser struct {
-	DisplayName string `json:"displayName"`
+	DisplayName string `json:"displayName" schema:"alt=name"`
 	UserName    string `json:"userName"`
-	Name        string `json:"name"`
+	Name        string `json:"name" schema:"alt=displayName"`
 }
 
 type CompetitionResponse struct {
 	ForumID *int `json:"forumId"`
 }
 
+// ForumRefResponse covers payloads that carry a forum reference either as a
+// top-level forumId, a nested forum object, or (for forums) the forum's own id.
+type ForumRefResponse struct {
+	ID      int  `json:"id" schema:"alt=forumId|forum"`
+	ForumID *int `json:"forumId" schema:"alt=forum|id"`
+	Forum   struct {
+		ID int `json:"id"`
+	} `json:"forum" schema:"alt=forumId|id"`
+}
+
+func (r ForumRefResponse) forumID() int {
+	switch {
+	case r.ForumID != nil:
+		return *r.ForumID
+	case r.Forum.ID != 0:
+		return r.Forum.ID
+	}
+	return r.ID
+}
+
 type TopicListResponse struct {
-	Count  int `json:"count"`
-	Topics []struct {
-		TopicURL string `json:"topicUrl"`
-		URL      string `json:"url"`
-	} `json:"topics"`
+	Count  int             `json:"count"`
+	Topics []TopicListItem `json:"topics"`
+}
+
+type TopicListItem struct {
+	ID                    int    `json:"id"`
+	Title                 string `json:"title"`
+	TopicURL              string `json:"topicUrl" schema:"alt=url"`
+	URL                   string `json:"url" schema:"alt=topicUrl"`
+	AuthorUserDisplayName string `json:"authorUserDisplayName"`
+	AuthorUserName        string `json:"authorUserName"`
+	Votes                 int    `json:"votes"`
+	CommentCount          int    `json:"commentCount" schema:"alt=totalMessages"`
+	TotalMessages         int    `json:"totalMessages" schema:"alt=commentCount"`
+	PostDate              string `json:"postDate"`
+	LastCommentPostDate   string `json:"lastCommentPostDate"`
+	IsSticky              bool   `json:"isSticky" schema:"optional"`
+	IsPinned              bool   `json:"isPinned" schema:"optional"`
+}
+
+type SubmissionUploadResponse struct {
+	CreateURL string `json:"createUrl"`
+	Token     string `json:"token"`
+}
+
+type SubmitResponse struct {
+	Ref     int    `json:"ref"`
+	Message string `json:"message"`
+}
+
+type Submission struct {
+	Ref              int    `json:"ref"`
+	FileName         string `json:"fileName"`
+	Date             string `json:"date"`
+	Description      string `json:"description"`
+	Status           string `json:"status"`
+	PublicScore      string `json:"publicScore"`
+	PrivateScore     string `json:"privateScore"`
+	ErrorDescription string `json:"errorDescription"`
+	TeamName         string `json:"teamName"`
+	SubmittedBy      string `json:"submittedBy"`
+}
+
+type LeaderboardResponse struct {
+	Submissions []LeaderboardEntry `json:"submissions"`
+}
+
+type LeaderboardEntry struct {
+	TeamID         int    `json:"teamId"`
+	TeamName       string `json:"teamName"`
+	SubmissionDate string `json:"submissionDate"`
+	Score          string `json:"score"`
+}
+
+type CompetitionInfo struct {
+	Ref                 string `json:"ref"`
+	Title               string `json:"title"`
+	MaxDailySubmissions int    `json:"maxDailySubmissions"`
+	Deadline            string `json:"deadline"`
+}
+
+type SearchResponse struct {
+	Documents []struct {
+		ID           int    `json:"id"`
+		DocumentType string `json:"documentType"`
+		Title        string `json:"title"`
+		URL          string `json:"url"`
+	} `json:"documents"`
+	TotalDocuments int `json:"totalDocuments"`
+}
+
+type UserMessagesResponse struct {
+	Messages []struct {
+		ID             int    `json:"id"`
+		ForumTopicID   int    `json:"forumTopicId"`
+		ForumTopicName string `json:"forumTopicName"`
+		ForumTopicURL  string `json:"forumTopicUrl" schema:"alt=topicUrl"`
+		TopicURL       string `json:"topicUrl" schema:"alt=forumTopicUrl"`
+		IsTopicStarter bool   `json:"isTopicStarter"`
+	} `json:"messages"`
+	TotalCount int `json:"totalCount" schema:"optional"`
+}
+
+type WriteUpsResponse struct {
+	WriteUps []WriteUp `json:"writeUps"`
+}
+
+type WriteUp struct {
+	Title     string `json:"title"`
+	URL       string `json:"url" schema:"alt=topicUrl"`
+	TopicURL  string `json:"topicUrl" schema:"alt=url"`
+	TeamName  string `json:"teamName"`
+	Placement int    `json:"leaderboardRank" schema:"optional"`
 }
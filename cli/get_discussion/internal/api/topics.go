@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// TopicSummary is the listing-level view of a topic, available before the
+// topic itself is fetched.
+type TopicSummary struct {
+	ID           int
+	Title        string
+	Link         string
+	Author       string
+	AuthorName   string
+	Votes        int
+	Comments     int
+	Created      time.Time
+	LastActivity time.Time
+	Pinned       bool
+}
+
+// TopicFilter is defined in pkg/source, where ListRequest carries it.
+type TopicFilter = source.TopicFilter
+
+// Matches reports whether t passes every criterion of f. Since and Until are
+// compared against the last activity, falling back to creation time.
+func (t TopicSummary) Matches(f TopicFilter) bool {
+	if t.Votes < f.MinVotes || t.Comments < f.MinComments {
+		return false
+	}
+	if f.ExcludePinned && t.Pinned {
+		return false
+	}
+	if f.Author != "" && !strings.EqualFold(f.Author, t.Author) && !strings.EqualFold(f.Author, t.AuthorName) {
+		return false
+	}
+	at := t.LastActivity
+	if at.IsZero() {
+		at = t.Created
+	}
+	if !f.Since.IsZero() && (at.IsZero() || at.Before(f.Since)) {
+		return false
+	}
+	if !f.Until.IsZero() && (at.IsZero() || at.After(f.Until)) {
+		return false
+	}
+	return true
+}
+
+// ParseFilterTime accepts a date (2006-01-02) or an RFC 3339 timestamp.
+func ParseFilterTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// ParseFilterUntil is ParseFilterTime for upper bounds: a date means the end
+// of that day, so --until 2024-03-01 keeps topics active on March 1st.
+func ParseFilterUntil(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// SummaryLinks returns the canonical links of topics in order.
+func SummaryLinks(topics []TopicSummary) []string {
+	out := make([]string, 0, len(topics))
+	for _, t := range topics {
+		out = append(out, t.Link)
+	}
+	return out
+}
+
+func newTopicSummary(item TopicListItem) (TopicSummary, bool) {
+	raw := urlutil.FirstNonEmpty(item.TopicURL, item.URL)
+	if raw == "" {
+		return TopicSummary{}, false
+	}
+	base, _ := url.Parse("https://www.kaggle.com")
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return TopicSummary{}, false
+	}
+	comments := item.CommentCount
+	if comments == 0 && item.TotalMessages > 0 {
+		// totalMessages counts the opening post as well.
+		comments = item.TotalMessages - 1
+	}
+	return TopicSummary{
+		ID:           item.ID,
+		Title:        item.Title,
+		Link:         urlutil.CanonicalizeURL(base.ResolveReference(ref).String()),
+		Author:       urlutil.FirstNonEmpty(item.AuthorUserDisplayName, item.AuthorUserName),
+		AuthorName:   item.AuthorUserName,
+		Votes:        item.Votes,
+		Comments:     comments,
+		Created:      parseAPITime(item.PostDate),
+		LastActivity: parseAPITime(item.LastCommentPostDate),
+		Pinned:       item.IsSticky || item.IsPinned,
+	}, true
+}
+
+func parseAPITime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/url"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+const (
+	apiUserMessagesURL = "https://www.kaggle.com/api/i/discussions.DiscussionsService/GetUserForumMessages"
+	userPageSize       = 20
+	// maxUserPages bounds FetchUserPosts, like maxSearchPages, should the
+	// API ignore the page parameter and keep returning full pages.
+	maxUserPages = 100
+)
+
+// UserPost is a topic or comment written by a user, linked to its parent topic.
+type UserPost struct {
+	MessageID   int
+	TopicID     int
+	TopicTitle  string
+	TopicLink   string
+	IsTopicPost bool
+}
+
+// FetchUserPosts lists the forum messages written by userName, newest first.
+// limit caps the number of distinct parent topics (0 means no limit). At
+// most maxUserPages pages are read, and paging stops at a page without new
+// messages.
+func FetchUserPosts(c *client.Client, userName string, limit int) ([]UserPost, error) {
+	var posts []UserPost
+	topics := map[string]struct{}{}
+	seen := map[int]struct{}{}
+
+	for page := 1; page <= maxUserPages; page++ {
+		var resp UserMessagesResponse
+		if err := c.PostJSONDecode(apiUserMessagesURL, map[string]any{
+			"userName": userName,
+			"page":     page,
+			"pageSize": userPageSize,
+		}, &resp); err != nil {
+			return posts, err
+		}
+		c.LogInfo("User messages API ok user=%s page=%d count=%d", userName, page, len(resp.Messages))
+		if len(resp.Messages) == 0 {
+			break
+		}
+
+		fresh := 0
+		for _, m := range resp.Messages {
+			if _, dup := seen[m.ID]; dup {
+				continue
+			}
+			seen[m.ID] = struct{}{}
+			fresh++
+			raw := urlutil.FirstNonEmpty(m.ForumTopicURL, m.TopicURL)
+			if raw == "" {
+				continue
+			}
+			base, _ := url.Parse("https://www.kaggle.com")
+			ref, err := url.Parse(raw)
+			if err != nil {
+				continue
+			}
+			link := urlutil.CanonicalizeURL(base.ResolveReference(ref).String())
+			if _, ok := topics[link]; !ok {
+				if limit > 0 && len(topics) >= limit {
+					return posts, nil
+				}
+				topics[link] = struct{}{}
+			}
+			posts = append(posts, UserPost{
+				MessageID:   m.ID,
+				TopicID:     m.ForumTopicID,
+				TopicTitle:  m.ForumTopicName,
+				TopicLink:   link,
+				IsTopicPost: m.IsTopicStarter,
+			})
+		}
+
+		if fresh == 0 {
+			c.LogInfo("User messages API repeated page=%d user=%s, stopping", page, userName)
+			break
+		}
+		if len(resp.Messages) < userPageSize {
+			break
+		}
+		if page == maxUserPages {
+			c.LogInfo("User messages API stopped at %d pages user=%s", page, userName)
+		}
+	}
+	return posts, nil
+}
+
+// TopicLinks returns the distinct parent topic links of posts in order.
+func TopicLinks(posts []UserPost) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, p := range posts {
+		if _, ok := seen[p.TopicLink]; ok {
+			continue
+		}
+		seen[p.TopicLink] = struct{}{}
+		out = append(out, p.TopicLink)
+	}
+	return out
+}
@@ -8,8 +8,12 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/schema"
 )
 
 const userAgent = "Mozilla/5.0 (compatible; KaggleDiscussionDownloader/1.0)"
@@ -24,6 +28,30 @@ type Client struct {
 	http    *http.Client
 	cookies map[string]string
 	verbose bool
+	creds   Credentials
+	schema  *schema.Recorder
+}
+
+// Credentials authenticate requests against the public Kaggle API.
+// Token takes precedence over Username/Key when both are set.
+type Credentials struct {
+	Username string
+	Key      string
+	Token    string
+}
+
+// CredentialsFromEnv reads KAGGLE_USERNAME, KAGGLE_KEY and KAGGLE_API_TOKEN.
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		Username: os.Getenv("KAGGLE_USERNAME"),
+		Key:      os.Getenv("KAGGLE_KEY"),
+		Token:    os.Getenv("KAGGLE_API_TOKEN"),
+	}
+}
+
+// Valid reports whether the credentials can authenticate a request.
+func (cr Credentials) Valid() bool {
+	return cr.Token != "" || (cr.Username != "" && cr.Key != "")
 }
 
 func NewClient(verbose bool) *Client {
@@ -35,6 +63,32 @@ func NewClient(verbose bool) *Client {
 	}
 }
 
+// NewAuthenticatedClient returns a Client that sends creds with every request.
+func NewAuthenticatedClient(verbose bool, creds Credentials) *Client {
+	c := NewClient(verbose)
+	c.creds = creds
+	return c
+}
+
+// SetSchemaRecorder enables strict decoding: every JSON response is checked
+// against its destination type and the drift is recorded in r.
+func (c *Client) SetSchemaRecorder(r *schema.Recorder) {
+	c.schema = r
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent)
+	if xsrf, ok := c.cookies["XSRF-TOKEN"]; ok && xsrf != "" {
+		req.Header.Set("X-XSRF-TOKEN", xsrf)
+	}
+	switch {
+	case c.creds.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.creds.Token)
+	case c.creds.Username != "" && c.creds.Key != "":
+		req.SetBasicAuth(c.creds.Username, c.creds.Key)
+	}
+}
+
 func (c *Client) Get(rawURL string, params url.Values) (*http.Response, error) {
 	if len(params) > 0 {
 		rawURL = rawURL + "?" + params.Encode()
@@ -43,10 +97,7 @@ func (c *Client) Get(rawURL string, params url.Values) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", userAgent)
-	if xsrf, ok := c.cookies["XSRF-TOKEN"]; ok && xsrf != "" {
-		req.Header.Set("X-XSRF-TOKEN", xsrf)
-	}
+	c.setHeaders(req)
 	return c.http.Do(req)
 }
 
@@ -59,11 +110,8 @@ func (c *Client) PostJSON(rawURL string, body any) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", userAgent)
+	c.setHeaders(req)
 	req.Header.Set("Content-Type", "application/json")
-	if xsrf, ok := c.cookies["XSRF-TOKEN"]; ok && xsrf != "" {
-		req.Header.Set("X-XSRF-TOKEN", xsrf)
-	}
 	return c.http.Do(req)
 }
 
@@ -98,6 +146,21 @@ func (c *Client) FetchJSON(rawURL string, params url.Values, dest any) error {
 	if err != nil {
 		return err
 	}
+	return c.decode(rawURL, data, dest)
+}
+
+func (c *Client) decodeBody(rawURL string, body io.Reader, dest any) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return c.decode(rawURL, data, dest)
+}
+
+func (c *Client) decode(rawURL string, data []byte, dest any) error {
+	if c.schema != nil {
+		c.schema.Check(rawURL, data, dest)
+	}
 	return json.Unmarshal(data, dest)
 }
 
@@ -122,11 +185,74 @@ func (c *Client) PostJSONDecode(rawURL string, body any, dest any) error {
 		if resp.StatusCode >= 400 {
 			return fmt.Errorf("HTTP %d for %s", resp.StatusCode, rawURL)
 		}
-		return json.NewDecoder(resp.Body).Decode(dest)
+		return c.decodeBody(rawURL, resp.Body, dest)
+	}
+	return lastErr
+}
+
+// PostFormDecode posts form-encoded values and decodes the JSON response into dest.
+func (c *Client) PostFormDecode(rawURL string, form url.Values, dest any) error {
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, rawURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		c.setHeaders(req)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := c.http.Do(req)
+		if err != nil {
+			lastErr = err
+			c.maybeSleep(nil, attempt)
+			continue
+		}
+		if shouldRetry(resp.StatusCode) {
+			lastErr = fmt.Errorf("HTTP %d for %s", resp.StatusCode, rawURL)
+			delay := c.maybeSleep(resp, attempt)
+			_ = resp.Body.Close()
+			if delay > 0 {
+				continue
+			}
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("HTTP %d for %s", resp.StatusCode, rawURL)
+		}
+		return c.decodeBody(rawURL, resp.Body, dest)
 	}
 	return lastErr
 }
 
+// PutFile uploads the file at path to a pre-signed upload URL.
+// The URL is not sent any credentials since it already carries its own.
+func (c *Client) PutFile(rawURL, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, rawURL, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %d for upload", resp.StatusCode)
+	}
+	return nil
+}
+
 func (c *Client) LogInfo(format string, args ...any) {
 	if c.verbose {
 		log.Printf("[info] "+format, args...)
@@ -2,27 +2,26 @@ package discussion
 
 import (
 	"fmt"
-	"log"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
 )
 
-// Discussion holds all metadata and content for a single Kaggle discussion.
-type Discussion struct {
-	Title         string
-	Link          string
-	Author        string
-	Comments      string
-	PublishedDate string
-	ContentMD     string
-}
+// Discussion and Message are defined in pkg/source so that sources outside
+// this module can produce them.
+type (
+	Discussion = source.Discussion
+	Message    = source.Message
+)
 
-func BuildDiscussionFromAPI(c *client.Client, rawURL string, topicID int) (*Discussion, error) {
+// fetchRawTopic downloads the topic and message payloads for topicID.
+func fetchRawTopic(c *client.Client, rawURL string, topicID int) (*RawTopic, error) {
 	// Warm up cookies.
 	_, _ = c.FetchBody(rawURL, nil)
 
@@ -30,8 +29,7 @@ func BuildDiscussionFromAPI(c *client.Client, rawURL string, topicID int) (*Disc
 	if err != nil {
 		return nil, err
 	}
-	t := topicResp.ForumTopic
-	if t.Name == "" {
+	if topicResp.ForumTopic.Name == "" {
 		return nil, fmt.Errorf("empty forumTopic for topic_id=%d", topicID)
 	}
 
@@ -39,8 +37,26 @@ func BuildDiscussionFromAPI(c *client.Client, rawURL string, topicID int) (*Disc
 	if err != nil {
 		return nil, err
 	}
+	return &RawTopic{
+		URL:       rawURL,
+		TopicID:   topicID,
+		FetchedAt: time.Now().UTC(),
+		Topic:     *topicResp,
+		Messages:  *msgResp,
+	}, nil
+}
 
-	contentMD := buildDiscussionMarkdown(msgResp, t.FirstMessageID)
+// buildDiscussionFromRaw renders API payloads, fresh or archived, into a Discussion.
+func buildDiscussionFromRaw(raw *RawTopic) (*Discussion, error) {
+	rawURL := raw.URL
+	t := raw.Topic.ForumTopic
+	if t.Name == "" {
+		return nil, fmt.Errorf("empty forumTopic for topic_id=%d", raw.TopicID)
+	}
+	msgResp := &raw.Messages
+
+	messages := buildMessages(msgResp, t.FirstMessageID)
+	contentMD := renderMessages(messages)
 
 	link := t.URL
 	if link == "" {
@@ -70,53 +86,108 @@ func BuildDiscussionFromAPI(c *client.Client, rawURL string, topicID int) (*Disc
 		Comments:      comments,
 		PublishedDate: t.PostDate,
 		ContentMD:     strings.TrimSpace(contentMD),
+		Messages:      messages,
+		FetchedAt:     raw.FetchedAt,
 	}, nil
 }
 
 func buildDiscussionMarkdown(msgResp *api.MessagesResponse, firstMessageID int) string {
-	if msgResp == nil || len(msgResp.Comments) == 0 {
-		return ""
+	return renderMessages(buildMessages(msgResp, firstMessageID))
+}
+
+// buildMessages returns the non-empty messages of a thread with the opening
+// post first. Without a matching firstMessageID the first message is used.
+func buildMessages(msgResp *api.MessagesResponse, firstMessageID int) []Message {
+	if msgResp == nil {
+		return nil
 	}
 
-	var mainMessage string
-	var replies []string
+	var main *Message
+	var replies []Message
 
 	for i, m := range msgResp.Comments {
-		body := strings.TrimSpace(urlutil.FirstNonEmpty(m.RawMarkdown, m.Content))
+		body := messageBody(m)
 		if body == "" {
 			continue
 		}
-
-		if m.ID == firstMessageID || (firstMessageID == 0 && i == 0) {
-			if mainMessage == "" {
-				mainMessage = body
-				continue
-			}
+		msg := Message{
+			ID:         m.ID,
+			Author:     commentAuthor(m),
+			AuthorName: urlutil.FirstNonEmpty(m.AuthorUserName, m.User.UserName),
+			Body:       body,
+			Votes:      int(m.Votes),
 		}
-
-		author := commentAuthor(m)
-		if author == "" {
-			author = "Unknown"
+		if main == nil && (m.ID == firstMessageID || (firstMessageID == 0 && i == 0)) {
+			msg.IsMain = true
+			main = &msg
+			continue
 		}
-		replies = append(replies, fmt.Sprintf("## Comment by %s\n\n%s", author, body))
+		replies = append(replies, msg)
 	}
 
-	if mainMessage == "" {
-		mainMessage = strings.TrimSpace(urlutil.FirstNonEmpty(
-			msgResp.Comments[0].RawMarkdown,
-			msgResp.Comments[0].Content,
-		))
-		if len(replies) > 0 {
-			replies = replies[1:]
+	if main == nil {
+		if len(replies) == 0 {
+			return nil
 		}
+		replies[0].IsMain = true
+		return replies
 	}
-	if mainMessage == "" {
-		return ""
+	return append([]Message{*main}, replies...)
+}
+
+var htmlTagRe = regexp.MustCompile(`<[A-Za-z][^<>]*>`)
+
+// messageBody returns the Markdown of a comment. Without raw Markdown the
+// rendered HTML content is converted, so its code is not rewritten as prose.
+func messageBody(m api.ForumComment) string {
+	if raw := strings.TrimSpace(m.RawMarkdown); raw != "" {
+		return normalizeKaggleMarkdown(raw)
 	}
-	if len(replies) == 0 {
-		return mainMessage
+	content := strings.TrimSpace(m.Content)
+	if htmlTagRe.MatchString(content) {
+		return htmlToMarkdown([]byte(content))
 	}
-	return mainMessage + "\n\n---\n\n" + strings.Join(replies, "\n\n---\n\n")
+	return normalizeKaggleMarkdown(content)
+}
+
+// SectionSeparator separates the opening post and the comments of a thread.
+const SectionSeparator = "\n\n---\n\n"
+
+// CommentHeading starts the section of every comment.
+const CommentHeading = "## Comment by "
+
+// renderMessages joins the opening post and its replies into Markdown.
+func renderMessages(messages []Message) string {
+	parts := make([]string, len(messages))
+	for i, m := range messages {
+		parts[i] = RenderSection(m, i == 0)
+	}
+	return strings.Join(parts, SectionSeparator)
+}
+
+// RenderSection renders one message of a thread: the opening post as its
+// body, a comment under a heading naming its author.
+func RenderSection(m Message, first bool) string {
+	if first {
+		return m.Body
+	}
+	author := m.Author
+	if author == "" {
+		author = "Unknown"
+	}
+	return fmt.Sprintf("%s%s\n\n%s", CommentHeading, author, m.Body)
+}
+
+// MarkUserMessages returns the IDs of messages in d written by userName or
+// listed in knownIDs, in thread order.
+func MarkUserMessages(d *Discussion, userName string, knownIDs map[int]bool) []int {
+	var ids []int
+	for _, m := range d.Messages {
+		if knownIDs[m.ID] || (m.AuthorName != "" && strings.EqualFold(m.AuthorName, userName)) {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
 }
 
 func commentAuthor(m api.ForumComment) string {
@@ -128,40 +199,3 @@ func commentAuthor(m api.ForumComment) string {
 		m.User.Name,
 	)
 }
-
-// IterDiscussions yields Discussion values for each URL, with API -> HTML fallback.
-func IterDiscussions(urls []string, c *client.Client, delay time.Duration) <-chan *Discussion {
-	ch := make(chan *Discussion)
-	go func() {
-		defer close(ch)
-		for _, rawURL := range urls {
-			topicID, hasID := urlutil.ExtractTopicID(rawURL)
-			var d *Discussion
-			var err error
-
-			if hasID {
-				d, err = BuildDiscussionFromAPI(c, rawURL, topicID)
-				if err != nil {
-					log.Printf("[warn] API failed for %s: %v — falling back to HTML", rawURL, err)
-					d, err = BuildDiscussionFromHTML(c, rawURL)
-				}
-			} else {
-				log.Printf("[warn] No topic ID detected in URL %s — using HTML parser", rawURL)
-				d, err = BuildDiscussionFromHTML(c, rawURL)
-			}
-
-			if err != nil {
-				log.Printf("[warn] Skipping %s: %v", rawURL, err)
-				continue
-			}
-			if strings.TrimSpace(d.ContentMD) == "" {
-				log.Printf("[warn] Empty content for %s", rawURL)
-			}
-			ch <- d
-			if delay > 0 {
-				time.Sleep(delay)
-			}
-		}
-	}()
-	return ch
-}
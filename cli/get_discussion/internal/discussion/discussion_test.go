@@ -0,0 +1,43 @@
+package discussion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+)
+
+func TestBuildDiscussionMarkdown(t *testing.T) {
+	resp := &api.MessagesResponse{Comments: []api.ForumComment{
+		{ID: 2, RawMarkdown: "Reply", AuthorUserName: "bob"},
+		{ID: 1, RawMarkdown: "Main", AuthorUserName: "alice"},
+		{ID: 3, Content: "Other", User: api.ForumCommentUser{UserName: "carol"}},
+	}}
+	md := buildDiscussionMarkdown(resp, 1)
+	if !strings.HasPrefix(md, "Main\n\n---\n\n## Comment by bob\n\nReply") {
+		t.Fatalf("unexpected markdown: %q", md)
+	}
+	if !strings.HasSuffix(md, "## Comment by carol\n\nOther") {
+		t.Fatalf("unexpected markdown: %q", md)
+	}
+}
+
+func TestMessageBodyConvertsHTMLContent(t *testing.T) {
+	m := api.ForumComment{Content: "<p>Thanks @alice</p><pre><code>df = read('/datasets/a/b')  # @bob</code></pre>"}
+	want := "Thanks [@alice](https://www.kaggle.com/alice)\n\n```\ndf = read('/datasets/a/b')  # @bob\n```"
+	if got := messageBody(m); got != want {
+		t.Fatalf("unexpected body:\n%s\n--- want ---\n%s", got, want)
+	}
+}
+
+func TestMarkUserMessages(t *testing.T) {
+	d := &Discussion{Messages: buildMessages(&api.MessagesResponse{Comments: []api.ForumComment{
+		{ID: 1, RawMarkdown: "Main", AuthorUserName: "alice"},
+		{ID: 2, RawMarkdown: "Reply", AuthorUserName: "Bob"},
+		{ID: 3, RawMarkdown: "Reply", AuthorUserName: "carol"},
+	}}, 1)}
+	ids := MarkUserMessages(d, "bob", map[int]bool{3: true})
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
@@ -0,0 +1,340 @@
+package discussion
+
+import (
+	"html"
+	"strings"
+)
+
+// nodeType distinguishes element nodes from text nodes.
+type nodeType int
+
+const (
+	elementNode nodeType = iota
+	textNode
+)
+
+// node is a minimal DOM node built by parseHTML. Text is already entity-decoded.
+type node struct {
+	typ      nodeType
+	tag      string
+	attrs    map[string]string
+	text     string
+	parent   *node
+	children []*node
+}
+
+func (n *node) attr(name string) string {
+	return n.attrs[name]
+}
+
+// hasClass reports whether the class attribute contains name.
+func (n *node) hasClass(name string) bool {
+	for _, c := range strings.Fields(n.attrs["class"]) {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// textContent returns the concatenated text of n and its descendants.
+func (n *node) textContent() string {
+	if n.typ == textNode {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.textContent())
+	}
+	return b.String()
+}
+
+// walk calls fn for n and its descendants in document order until fn returns false.
+func (n *node) walk(fn func(*node) bool) bool {
+	if !fn(n) {
+		return false
+	}
+	for _, c := range n.children {
+		if !c.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// voidElements never have children or closing tags.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true,
+	"track": true, "wbr": true,
+}
+
+// rawTextElements hold unparsed text up to their closing tag.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+}
+
+// closesParagraph lists elements whose start tag implicitly ends an open <p>.
+var closesParagraph = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "div": true,
+	"dl": true, "fieldset": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "ul": true,
+}
+
+// implicitEnd maps a start tag to the open siblings it closes, stopping at
+// the listed scope boundaries.
+var implicitEnd = map[string]struct {
+	closes []string
+	scope  []string
+}{
+	"li":     {[]string{"li"}, []string{"ul", "ol"}},
+	"dt":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"dd":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"tr":     {[]string{"tr", "td", "th"}, []string{"table", "thead", "tbody", "tfoot"}},
+	"td":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"thead":  {[]string{"tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+	"tbody":  {[]string{"thead", "tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+	"tfoot":  {[]string{"thead", "tbody", "tr", "td", "th"}, []string{"table"}},
+	"option": {[]string{"option"}, []string{"select", "datalist"}},
+}
+
+// parseHTML tokenizes s and builds a forgiving DOM tree. Unknown end tags are
+// ignored and unclosed elements are closed at the end of input, which is
+// enough for the markup Kaggle serves.
+func parseHTML(s string) *node {
+	root := &node{typ: elementNode, tag: "#document"}
+	cur := root
+
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		if last := len(cur.children) - 1; last >= 0 && cur.children[last].typ == textNode {
+			cur.children[last].text += text
+			return
+		}
+		cur.children = append(cur.children, &node{typ: textNode, text: text, parent: cur})
+	}
+	closeTo := func(target *node) {
+		cur = target.parent
+	}
+	// findOpen returns the open element named in tags, searching upwards
+	// and stopping at scope boundaries. With outermost it keeps searching so
+	// that e.g. a new <tr> inside a <td> closes the row, not just the cell.
+	findOpen := func(tags []string, scope []string, outermost bool) *node {
+		var found *node
+	search:
+		for n := cur; n != nil && n != root; n = n.parent {
+			for _, s := range scope {
+				if n.tag == s {
+					break search
+				}
+			}
+			for _, t := range tags {
+				if n.tag == t {
+					if !outermost {
+						return n
+					}
+					found = n
+				}
+			}
+		}
+		return found
+	}
+
+	i := 0
+	for i < len(s) {
+		lt := strings.IndexByte(s[i:], '<')
+		if lt < 0 {
+			appendText(html.UnescapeString(s[i:]))
+			break
+		}
+		if lt > 0 {
+			appendText(html.UnescapeString(s[i : i+lt]))
+			i += lt
+		}
+
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += 4 + end + 3
+			}
+			continue
+		case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += end + 1
+			}
+			continue
+		}
+
+		tok, n, ok := readTag(rest)
+		if !ok {
+			appendText("<")
+			i++
+			continue
+		}
+		i += n
+
+		if tok.end {
+			if open := findOpen([]string{tok.name}, nil, false); open != nil {
+				closeTo(open)
+			}
+			continue
+		}
+
+		if closesParagraph[tok.name] {
+			if p := findOpen([]string{"p"}, []string{"button", "table", "li", "td", "th", "blockquote", "div"}, false); p != nil {
+				closeTo(p)
+			}
+		}
+		if rule, ok := implicitEnd[tok.name]; ok {
+			if open := findOpen(rule.closes, rule.scope, true); open != nil {
+				closeTo(open)
+			}
+		}
+
+		el := &node{typ: elementNode, tag: tok.name, attrs: tok.attrs, parent: cur}
+		cur.children = append(cur.children, el)
+
+		if rawTextElements[tok.name] && !tok.selfClosing {
+			closing := "</" + tok.name
+			end := indexFold(s[i:], closing)
+			if end < 0 {
+				end = len(s) - i
+			}
+			text := s[i : i+end]
+			if tok.name == "textarea" || tok.name == "title" {
+				text = html.UnescapeString(text)
+			}
+			if text != "" {
+				el.children = append(el.children, &node{typ: textNode, text: text, parent: el})
+			}
+			i += end
+			if gt := strings.IndexByte(s[i:], '>'); gt >= 0 {
+				i += gt + 1
+			} else {
+				i = len(s)
+			}
+			continue
+		}
+		if !voidElements[tok.name] && !tok.selfClosing {
+			cur = el
+		}
+	}
+	return root
+}
+
+// tagToken is a start or end tag read by readTag.
+type tagToken struct {
+	name        string
+	attrs       map[string]string
+	end         bool
+	selfClosing bool
+}
+
+// readTag parses a tag at the start of s and returns it with its length.
+// ok is false when s does not start with a well-formed tag.
+func readTag(s string) (tagToken, int, bool) {
+	var tok tagToken
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		tok.end = true
+		i++
+	}
+	start := i
+	for i < len(s) && isNameChar(s[i]) {
+		i++
+	}
+	if i == start || !isLetter(s[start]) {
+		return tok, 0, false
+	}
+	tok.name = strings.ToLower(s[start:i])
+	tok.attrs = map[string]string{}
+
+	for i < len(s) {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return tok, 0, false
+		}
+		switch s[i] {
+		case '>':
+			return tok, i + 1, true
+		case '/':
+			tok.selfClosing = true
+			i++
+			continue
+		}
+
+		nameStart := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[nameStart:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					return tok, 0, false
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valStart := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[valStart:i]
+			}
+		}
+		if name != "" {
+			if _, dup := tok.attrs[name]; !dup {
+				tok.attrs[name] = html.UnescapeString(value)
+			}
+		}
+	}
+	return tok, 0, false
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isLetter(c) || (c >= '0' && c <= '9') || c == '-' || c == ':'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// indexFold is a case-insensitive strings.Index for ASCII needles.
+func indexFold(s, substr string) int {
+	n := len(substr)
+	for i := 0; i+n <= len(s); i++ {
+		if strings.EqualFold(s[i:i+n], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,12 +1,10 @@
 package discussion
 
 import (
-	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
 
-	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
 )
 
@@ -18,7 +16,7 @@ func ExtractDiscussionLinksFromHTML(body []byte, base string) []string {
 	hrefRegex := regexp.MustCompile(`(?i)href=["']([^"']+)["']`)
 	for _, m := range hrefRegex.FindAllSubmatch(body, -1) {
 		href := string(m[1])
-		if !strings.Contains(href, "/discussions/") && !strings.Contains(href, "/discussion/") {
+		if !urlutil.IsDiscussionURL(href) {
 			continue
 		}
 		baseURL, _ := url.Parse(base)
@@ -53,49 +51,25 @@ func stripTags(s string) string {
 	return regexp.MustCompile(`<[^>]+>`).ReplaceAllString(s, "")
 }
 
-// htmlToMarkdown does a simplistic conversion: strips tags, collapses whitespace.
-func htmlToMarkdown(body []byte) string {
-	s := string(body)
-	// Strip scripts/styles.
-	s = regexp.MustCompile(`(?is)<(script|style|noscript)[^>]*>.*?</(script|style|noscript)>`).ReplaceAllString(s, "")
-	// Headings.
-	for i := 6; i >= 1; i-- {
-		tag := fmt.Sprintf("h%d", i)
-		prefix := strings.Repeat("#", i) + " "
-		s = regexp.MustCompile(fmt.Sprintf(`(?is)<%s[^>]*>(.*?)</%s>`, tag, tag)).
-			ReplaceAllStringFunc(s, func(m string) string {
-				inner := regexp.MustCompile(fmt.Sprintf(`(?is)<%s[^>]*>(.*?)</%s>`, tag, tag)).FindStringSubmatch(m)
-				if inner != nil {
-					return "\n" + prefix + stripTags(inner[1]) + "\n"
-				}
-				return m
-			})
-	}
-	// Paragraphs and line breaks.
-	s = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>`).ReplaceAllString(s, "\n")
-	// Links.
-	s = regexp.MustCompile(`(?is)<a[^>]+href=["']([^"']+)["'][^>]*>(.*?)</a>`).
-		ReplaceAllString(s, "[$2]($1)")
-	// Bold/italic.
-	s = regexp.MustCompile(`(?is)<(strong|b)>(.*?)</(strong|b)>`).ReplaceAllString(s, "**$2**")
-	s = regexp.MustCompile(`(?is)<(em|i)>(.*?)</(em|i)>`).ReplaceAllString(s, "_$2_")
-	// Strip remaining tags.
-	s = stripTags(s)
-	// Collapse excessive blank lines.
-	s = regexp.MustCompile(`\n{3,}`).ReplaceAllString(s, "\n\n")
-	return strings.TrimSpace(s)
-}
-
-func BuildDiscussionFromHTML(c *client.Client, rawURL string) (*Discussion, error) {
-	body, err := c.FetchBody(rawURL, nil)
-	if err != nil {
-		return nil, err
+// discussionFromHTML builds a discussion from a page. It prefers the JSON
+// state Kaggle embeds in the page, which carries the same data as the API,
+// and falls back to converting the page's main content to Markdown. It also
+// returns the confidence that the content is the discussion itself: 1 for
+// embedded state, the extractor's score otherwise.
+func discussionFromHTML(body []byte, rawURL string) (*Discussion, float64) {
+	root := parseHTML(string(body))
+	if d, ok := discussionFromPageState(root, rawURL); ok {
+		if d.ContentMD != "" {
+			return d, 1
+		}
+		content := extractMainContent(root)
+		d.ContentMD = content.markdown()
+		return d, content.confidence
 	}
-	title := extractTitleFromHTML(body)
-	contentMD := htmlToMarkdown(body)
+	content := extractMainContent(root)
 	return &Discussion{
-		Title:     title,
+		Title:     extractTitleFromHTML(body),
 		Link:      urlutil.CanonicalizeURL(rawURL),
-		ContentMD: contentMD,
-	}, nil
+		ContentMD: content.markdown(),
+	}, content.confidence
 }
@@ -0,0 +1,137 @@
+package discussion
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+var (
+	// verbatimRe matches spans that are never rewritten: inline code, math,
+	// Markdown links and images, raw HTML tags and URLs. Links and tags are
+	// only touched to absolutize their targets.
+	verbatimRe = regexp.MustCompile("" +
+		"``[^`]+``|`[^`\n]+`" +
+		`|\$\$[\s\S]+?\$\$|\\\[[\s\S]+?\\\]|\\\([\s\S]+?\\\)` +
+		`|\$[^\s$](?:[^$\n]*?[^\s$\\])?\$` +
+		`|(!?\[[^\]\n]*\]\()([^)\s]+)((?:\s+"[^"\n]*")?\))` +
+		`|<[A-Za-z][^<>]*>` +
+		`|https?://[^\s<>()\[\]]+`)
+	tagURLRe = regexp.MustCompile(`(?i)(\s(?:href|src)\s*=\s*["']?)(/[^"'\s>]*)`)
+
+	mentionRe  = regexp.MustCompile(`(^|[^\w@./\[])@([A-Za-z0-9][A-Za-z0-9_-]*[A-Za-z0-9])`)
+	shortRefRe = regexp.MustCompile(`(^|[\s(])((?:www\.)?kaggle\.com/[^\s<>()\[\]]+|/(?:code|datasets|competitions|models|discussions?)/[^\s<>()\[\]]+)`)
+	// pathRefRe is the shape a bare path needs to be read as a Kaggle
+	// reference: owner/slug for code, datasets and models, a slug for
+	// competitions and a topic ID for discussions. File paths such as
+	// /datasets/raw/train.csv do not qualify.
+	pathRefRe  = regexp.MustCompile(`^/(?:(?:code|datasets|models)/[A-Za-z0-9_-]+/[A-Za-z0-9_-]+|competitions/[A-Za-z0-9-]+|discussions?/(?:[a-z-]+/)?\d+)(?:[/?#]\S*)?$`)
+	listItemRe = regexp.MustCompile(`^ {0,3}(?:[-*+]|\d{1,9}[.)])(?:[ \t]|$)`)
+)
+
+// isIndented reports whether line is indented enough to be code.
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+// normalizeKaggleMarkdown rewrites Kaggle-specific Markdown so it renders
+// outside Kaggle: @mentions become profile links, short dataset, notebook and
+// competition references become absolute links and relative link targets are
+// resolved against kaggle.com. Fenced and indented code and $...$ / $$...$$
+// math stay verbatim. md must be Markdown; HTML goes through htmlToMarkdown.
+func normalizeKaggleMarkdown(md string) string {
+	var b strings.Builder
+	inFence := ""
+	// An indented line after a blank line is code, unless it continues a
+	// list item.
+	inIndented, inList, prevBlank := false, false, true
+	var prose []string
+	flush := func() {
+		if len(prose) > 0 {
+			b.WriteString(rewriteProse(strings.Join(prose, "\n")))
+			b.WriteString("\n")
+			prose = prose[:0]
+		}
+	}
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inIndented && !isIndented(line) && trimmed != "" {
+			inIndented = false
+		}
+		blank := prevBlank
+		prevBlank = trimmed == ""
+		switch {
+		case inIndented:
+		case inFence == "" && isIndented(line) && blank && !inList:
+			flush()
+			inIndented = true
+		case inFence != "":
+			if strings.HasPrefix(trimmed, inFence) && strings.Trim(trimmed, inFence[:1]) == "" {
+				inFence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			inFence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+		default:
+			if listItemRe.MatchString(line) {
+				inList = true
+			} else if trimmed != "" && !isIndented(line) {
+				inList = false
+			}
+			prose = append(prose, line)
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// rewriteProse applies the rewrites outside the verbatim spans of s.
+func rewriteProse(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range verbatimRe.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(expandReferences(s[last:m[0]]))
+		span := s[m[0]:m[1]]
+		switch {
+		case m[2] >= 0:
+			span = s[m[2]:m[3]] + urlutil.AbsoluteURL(s[m[4]:m[5]]) + s[m[6]:m[7]]
+		case strings.HasPrefix(span, "<"):
+			span = tagURLRe.ReplaceAllStringFunc(span, func(attr string) string {
+				sub := tagURLRe.FindStringSubmatch(attr)
+				return sub[1] + urlutil.AbsoluteURL(sub[2])
+			})
+		}
+		b.WriteString(span)
+		last = m[1]
+	}
+	b.WriteString(expandReferences(s[last:]))
+	return b.String()
+}
+
+// expandReferences links @mentions and short Kaggle references in plain text.
+func expandReferences(s string) string {
+	s = mentionRe.ReplaceAllStringFunc(s, func(match string) string {
+		sub := mentionRe.FindStringSubmatch(match)
+		return sub[1] + "[@" + sub[2] + "](" + urlutil.ProfileURL(sub[2]) + ")"
+	})
+	return shortRefRe.ReplaceAllStringFunc(s, func(match string) string {
+		sub := shortRefRe.FindStringSubmatch(match)
+		ref := strings.TrimRight(sub[2], ".,;:!?'\"")
+		rest := sub[2][len(ref):]
+		var target string
+		if strings.HasPrefix(ref, "/") {
+			if !pathRefRe.MatchString(ref) {
+				return match
+			}
+			target = urlutil.AbsoluteURL(ref)
+		} else {
+			target = "https://" + strings.TrimPrefix(ref, "www.")
+			target = strings.Replace(target, "https://kaggle.com", "https://www.kaggle.com", 1)
+		}
+		return sub[1] + "[" + ref + "](" + target + ")" + rest
+	})
+}
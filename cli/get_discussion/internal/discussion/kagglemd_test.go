@@ -0,0 +1,31 @@
+package discussion
+
+import "testing"
+
+func TestNormalizeKaggleMarkdown(t *testing.T) {
+	in := "Thanks @alice! Loss $a_i * b_i$ and\n\n$$\n\\sum_{i} @x_i\n$$\n\n" +
+		"See [nb](/code/bob/eda), ![img](/static/p.png) and <a href=\"/datasets/c/d\">data</a>.\n" +
+		"Short: kaggle.com/competitions/titanic, /models/google/gemma.\n" +
+		"Mail me@example.com or `@property` or https://www.kaggle.com/code/x/y.\n\n" +
+		"```python\n@dataclass\nopen('/code/x')\n```"
+	want := "Thanks [@alice](https://www.kaggle.com/alice)! Loss $a_i * b_i$ and\n\n$$\n\\sum_{i} @x_i\n$$\n\n" +
+		"See [nb](https://www.kaggle.com/code/bob/eda), ![img](https://www.kaggle.com/static/p.png) and <a href=\"https://www.kaggle.com/datasets/c/d\">data</a>.\n" +
+		"Short: [kaggle.com/competitions/titanic](https://www.kaggle.com/competitions/titanic), [/models/google/gemma](https://www.kaggle.com/models/google/gemma).\n" +
+		"Mail me@example.com or `@property` or https://www.kaggle.com/code/x/y.\n\n" +
+		"```python\n@dataclass\nopen('/code/x')\n```"
+	if got := normalizeKaggleMarkdown(in); got != want {
+		t.Fatalf("unexpected markdown:\n%s\n--- want ---\n%s", got, want)
+	}
+}
+
+func TestNormalizeKaggleMarkdownLeavesCodeAndPaths(t *testing.T) {
+	in := "Read /datasets/raw/train.csv first, then /datasets/alice/titanic-extra.\n\n" +
+		"    df = pd.read_csv('/datasets/alice/titanic/train.csv')  # @alice\n\n" +
+		"- item\n\n    continued by @bob\n\nDone."
+	want := "Read /datasets/raw/train.csv first, then [/datasets/alice/titanic-extra](https://www.kaggle.com/datasets/alice/titanic-extra).\n\n" +
+		"    df = pd.read_csv('/datasets/alice/titanic/train.csv')  # @alice\n\n" +
+		"- item\n\n    continued by [@bob](https://www.kaggle.com/bob)\n\nDone."
+	if got := normalizeKaggleMarkdown(in); got != want {
+		t.Fatalf("unexpected markdown:\n%s\n--- want ---\n%s", got, want)
+	}
+}
@@ -0,0 +1,610 @@
+package discussion
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// blockElements start a new Markdown block; everything else is rendered inline.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true,
+	"dd": true, "details": true, "div": true, "dl": true, "dt": true, "fieldset": true,
+	"figcaption": true, "figure": true, "footer": true, "form": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "html": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "summary": true, "table": true,
+	"ul": true, "#document": true,
+}
+
+// skippedElements are dropped together with their content.
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "button": true, "input": true, "select": true,
+	"textarea": true, "title": true, "meta": true, "link": true,
+}
+
+var (
+	spaceRunRe  = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankRunRe  = regexp.MustCompile(`\n{3,}`)
+	languageRe  = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([A-Za-z0-9_+#.-]+)`)
+	textAlignRe = regexp.MustCompile(`(?i)text-align\s*:\s*(left|center|right)`)
+)
+
+// hardBreak is the GitHub-flavored Markdown line break emitted for <br>.
+const hardBreak = "\\\n"
+
+// htmlToMarkdown converts an HTML document to GitHub-flavored Markdown. It
+// handles headings, paragraphs, emphasis, links, images, nested ordered and
+// unordered lists, blockquotes, fenced code blocks, tables and math. Text is
+// entity-decoded and its Markdown specials are escaped outside code, math
+// and bare URLs, then Kaggle references are expanded by
+// normalizeKaggleMarkdown.
+func htmlToMarkdown(body []byte) string {
+	return normalizeKaggleMarkdown(renderMarkdown(parseHTML(string(body))))
+}
+
+// renderMarkdown renders n and its descendants as Markdown blocks.
+func renderMarkdown(n *node) string {
+	out := blankRunRe.ReplaceAllString(renderBlocks(n), "\n\n")
+	return strings.TrimSpace(out)
+}
+
+// mdBlock is one rendered block; list blocks stay tight inside list items.
+type mdBlock struct {
+	text   string
+	isList bool
+}
+
+// renderBlocks renders the children of n as a sequence of blocks separated by
+// blank lines. Runs of inline content become paragraphs.
+func renderBlocks(n *node) string {
+	return joinBlocks(collectBlocks(n), "\n\n")
+}
+
+func collectBlocks(n *node) []mdBlock {
+	var blocks []mdBlock
+	var inline strings.Builder
+	inMath := false
+
+	flush := func() {
+		if p := cleanInline(inline.String()); p != "" {
+			blocks = append(blocks, mdBlock{text: escapeBlockStart(p)})
+		}
+		inline.Reset()
+	}
+
+	for _, c := range n.children {
+		if c.typ == elementNode && isSkipped(c) {
+			continue
+		}
+		if tex, ok := displayMath(c); ok {
+			flush()
+			blocks = append(blocks, mdBlock{text: tex})
+			continue
+		}
+		if c.typ == textNode || !blockElements[c.tag] {
+			inline.WriteString(renderInlineSibling(c, &inMath))
+			continue
+		}
+		flush()
+		inMath = false
+		if b := renderBlock(c); b.text != "" {
+			blocks = append(blocks, b)
+		}
+	}
+	flush()
+	return blocks
+}
+
+func joinBlocks(blocks []mdBlock, sep string) string {
+	var b strings.Builder
+	for i, blk := range blocks {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(blk.text)
+	}
+	return b.String()
+}
+
+func renderBlock(n *node) mdBlock {
+	switch n.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.tag[1:])
+		text := strings.ReplaceAll(cleanInline(renderInlineChildren(n)), hardBreak, " ")
+		if text == "" {
+			return mdBlock{}
+		}
+		return mdBlock{text: strings.Repeat("#", level) + " " + text}
+	case "hr":
+		return mdBlock{text: "---"}
+	case "pre":
+		return mdBlock{text: renderCodeBlock(n)}
+	case "ul", "ol":
+		return mdBlock{text: renderList(n), isList: true}
+	case "blockquote":
+		inner := renderBlocks(n)
+		if inner == "" {
+			return mdBlock{}
+		}
+		return mdBlock{text: prefixLines(inner, "> ", ">")}
+	case "table":
+		return mdBlock{text: renderTable(n)}
+	}
+	return mdBlock{text: renderBlocks(n)}
+}
+
+// renderInline renders an inline node. Whitespace is collapsed later by
+// cleanInline. Text nodes are rendered by renderInlineSibling, which knows
+// whether they sit in math.
+func renderInline(n *node) string {
+	if n.typ == textNode {
+		return escapeText(n.text, false)
+	}
+	if tex, ok := mathSource(n); ok {
+		return tex
+	}
+	if isSkipped(n) {
+		return ""
+	}
+	switch n.tag {
+	case "br":
+		return hardBreak
+	case "img":
+		src := n.attr("src")
+		if src == "" {
+			return ""
+		}
+		return fmt.Sprintf("![%s](%s)", collapseSpace(n.attr("alt")), src)
+	case "code", "kbd", "samp", "tt":
+		return inlineCode(n.textContent())
+	case "strong", "b":
+		return wrapInline(renderInlineChildren(n), "**")
+	case "em", "i", "cite":
+		return wrapInline(renderInlineChildren(n), "*")
+	case "del", "s", "strike":
+		return wrapInline(renderInlineChildren(n), "~~")
+	case "a":
+		text := cleanInline(renderInlineChildren(n))
+		href := n.attr("href")
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return text
+		}
+		if text == "" {
+			text = href
+		}
+		return fmt.Sprintf("[%s](%s)", text, href)
+	}
+	if blockElements[n.tag] {
+		// Block content inside inline context, e.g. a <div> in a link.
+		return " " + renderInlineChildren(n) + " "
+	}
+	return renderInlineChildren(n)
+}
+
+func renderInlineChildren(n *node) string {
+	var b strings.Builder
+	inMath := false
+	for _, c := range n.children {
+		b.WriteString(renderInlineSibling(c, &inMath))
+	}
+	return b.String()
+}
+
+// renderInlineSibling renders one of a run of inline siblings. Text between
+// unescaped dollar signs is TeX that the site's Markdown converter may have
+// split into <em> runs, e.g. $x<em>i + y</em>j$; inside such math, elements
+// are put back as underscores. inMath carries the state across siblings.
+func renderInlineSibling(c *node, inMath *bool) string {
+	if *inMath && c.typ == elementNode {
+		return rawTeX(c)
+	}
+	if c.typ == textNode {
+		text := escapeText(c.text, *inMath)
+		if countDollars(c.text)%2 == 1 {
+			*inMath = !*inMath
+		}
+		return text
+	}
+	return renderInline(c)
+}
+
+// escapeText backslash-escapes the characters of s that Markdown would read
+// as emphasis, links, code or HTML: '*', '[', ']' and '`', '<' where it
+// could open a tag or autolink, and '_' where it is not inside a word. TeX between unescaped dollar signs and bare URLs are left alone;
+// inMath tells whether s starts inside math.
+func escapeText(s string, inMath bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			b.WriteString(s[i : i+2])
+			i++
+			continue
+		case c == '$':
+			inMath = !inMath
+		case inMath:
+		case strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://"):
+			end := strings.IndexAny(s[i:], " \t\r\n")
+			if end < 0 {
+				end = len(s) - i
+			}
+			b.WriteString(s[i : i+end])
+			i += end - 1
+			continue
+		case c == '*' || c == '[' || c == ']' || c == '`':
+			b.WriteByte('\\')
+		case c == '<' && i+1 < len(s) && opensTag(s[i+1]):
+			b.WriteByte('\\')
+		case c == '_' && !(i > 0 && isWordByte(s[i-1]) && i+1 < len(s) && isWordByte(s[i+1])):
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// opensTag reports whether c after '<' could start HTML or an autolink.
+func opensTag(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '/' || c == '!' || c == '?'
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// blockStartRe matches paragraph openings Markdown would read as a heading,
+// list item or blockquote.
+var blockStartRe = regexp.MustCompile(`^(?:(#{1,6})|([-+>])|(\d{1,9})([.)]))(?:[ \t]|$)`)
+
+// escapeBlockStart escapes the opening of paragraph p when it would start a
+// heading, list item or blockquote, e.g. "1. Not a list" or "# not a heading".
+func escapeBlockStart(p string) string {
+	m := blockStartRe.FindStringSubmatchIndex(p)
+	switch {
+	case m == nil:
+		return p
+	case m[6] >= 0:
+		return p[:m[8]] + "\\" + p[m[8]:]
+	}
+	return "\\" + p
+}
+
+// rawTeX restores the TeX source of a node inside inline math.
+func rawTeX(n *node) string {
+	if n.typ == textNode {
+		return n.text
+	}
+	var inner strings.Builder
+	for _, c := range n.children {
+		inner.WriteString(rawTeX(c))
+	}
+	switch n.tag {
+	case "em", "i":
+		return "_" + inner.String() + "_"
+	case "strong", "b":
+		return "__" + inner.String() + "__"
+	case "br":
+		return " "
+	}
+	return inner.String()
+}
+
+// countDollars counts the dollar signs of s that are not escaped.
+func countDollars(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '$' {
+			n++
+		}
+	}
+	return n
+}
+
+// isSkipped reports whether an element is dropped with its content. Math
+// scripts are kept, while MathJax's rendered output is dropped in favour of
+// the TeX source it was generated from.
+func isSkipped(n *node) bool {
+	if isMathScript(n) {
+		return false
+	}
+	for _, c := range strings.Fields(n.attr("class")) {
+		if strings.HasPrefix(c, "MathJax") {
+			return true
+		}
+	}
+	return skippedElements[n.tag]
+}
+
+func isMathScript(n *node) bool {
+	return n.tag == "script" && strings.HasPrefix(strings.ToLower(n.attr("type")), "math/tex")
+}
+
+// mathSource returns the TeX of a MathJax script or KaTeX element wrapped in
+// $...$, or $$...$$ for display math.
+func mathSource(n *node) (string, bool) {
+	if n.typ != elementNode {
+		return "", false
+	}
+	var tex string
+	display := false
+	switch {
+	case isMathScript(n):
+		tex = n.textContent()
+		display = strings.Contains(strings.ToLower(n.attr("type")), "mode=display")
+	case n.hasClass("katex-display"), n.hasClass("katex"):
+		n.walk(func(c *node) bool {
+			if c.tag == "annotation" && c.attr("encoding") == "application/x-tex" {
+				tex = c.textContent()
+				return false
+			}
+			return true
+		})
+		display = n.hasClass("katex-display")
+	default:
+		return "", false
+	}
+	tex = strings.TrimSpace(tex)
+	if tex == "" {
+		return "", false
+	}
+	if display {
+		return "$$" + tex + "$$", true
+	}
+	return "$" + tex + "$", true
+}
+
+// displayMath renders display math found at block level on its own lines.
+func displayMath(n *node) (string, bool) {
+	tex, ok := mathSource(n)
+	if !ok || !strings.HasPrefix(tex, "$$") {
+		return "", false
+	}
+	return "$$\n" + tex[2:len(tex)-2] + "\n$$", true
+}
+
+// wrapInline wraps text in a delimiter, keeping surrounding spaces outside so
+// "<b> x </b>" becomes " **x** " rather than invalid "** x **".
+func wrapInline(text, delim string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+	return lead + delim + trimmed + delim + trail
+}
+
+// inlineCode wraps s in a backtick fence longer than any run inside it.
+func inlineCode(s string) string {
+	s = collapseSpace(s)
+	if s == "" {
+		return ""
+	}
+	fence := strings.Repeat("`", longestRun(s, '`')+1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// cleanInline collapses whitespace in a paragraph while keeping hard breaks.
+func cleanInline(s string) string {
+	parts := strings.Split(s, hardBreak)
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(collapseSpace(p))
+	}
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	for len(parts) > 0 && parts[0] == "" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, hardBreak)
+}
+
+func collapseSpace(s string) string {
+	return spaceRunRe.ReplaceAllString(s, " ")
+}
+
+// renderCodeBlock renders <pre> as a fenced block, taking the language from a
+// language-*/lang-* class on the <pre> or its <code> child.
+func renderCodeBlock(n *node) string {
+	lang := codeLanguage(n)
+	n.walk(func(c *node) bool {
+		if lang == "" && c.tag == "code" {
+			lang = codeLanguage(c)
+		}
+		return lang == ""
+	})
+
+	var b strings.Builder
+	var collect func(*node)
+	collect = func(c *node) {
+		if c.typ == textNode {
+			b.WriteString(c.text)
+			return
+		}
+		if c.tag == "br" {
+			b.WriteString("\n")
+			return
+		}
+		for _, cc := range c.children {
+			collect(cc)
+		}
+	}
+	collect(n)
+
+	code := strings.TrimPrefix(b.String(), "\n")
+	code = strings.TrimRight(code, " \t\r\n")
+	fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func codeLanguage(n *node) string {
+	if m := languageRe.FindStringSubmatch(n.attr("class")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func longestRun(s string, c byte) int {
+	best, cur := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			cur++
+			best = max(best, cur)
+		} else {
+			cur = 0
+		}
+	}
+	return best
+}
+
+// renderList renders <ul>/<ol> items, indenting continuation lines and nested
+// lists under the item marker.
+func renderList(n *node) string {
+	ordered := n.tag == "ol"
+	num := 1
+	if start, err := strconv.Atoi(n.attr("start")); err == nil && ordered {
+		num = start
+	}
+
+	var items []string
+	for _, c := range n.children {
+		if c.typ != elementNode || c.tag != "li" {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		items = append(items, marker+indentLines(renderListItem(c), len(marker)))
+	}
+	return strings.Join(items, "\n")
+}
+
+// renderListItem keeps nested lists tight against the preceding text and
+// separates other blocks with blank lines.
+func renderListItem(n *node) string {
+	blocks := collectBlocks(n)
+	var b strings.Builder
+	for i, blk := range blocks {
+		if i > 0 {
+			if blk.isList {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(blk.text)
+	}
+	return b.String()
+}
+
+// indentLines indents every line but the first by width spaces.
+func indentLines(s string, width int) string {
+	pad := strings.Repeat(" ", width)
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = pad + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func prefixLines(s, prefix, blank string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l == "" {
+			lines[i] = blank
+		} else {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTable renders a GFM pipe table. The first row becomes the header;
+// column alignment comes from the align attribute or a text-align style.
+func renderTable(n *node) string {
+	var rows [][]*node
+	var collectRows func(*node)
+	collectRows = func(c *node) {
+		for _, cc := range c.children {
+			if cc.typ != elementNode {
+				continue
+			}
+			switch cc.tag {
+			case "tr":
+				var cells []*node
+				for _, cell := range cc.children {
+					if cell.typ == elementNode && (cell.tag == "td" || cell.tag == "th") {
+						cells = append(cells, cell)
+					}
+				}
+				rows = append(rows, cells)
+			case "thead", "tbody", "tfoot":
+				collectRows(cc)
+			}
+		}
+	}
+	collectRows(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	if cols == 0 {
+		return ""
+	}
+
+	line := func(cells []*node) string {
+		parts := make([]string, cols)
+		for i := range parts {
+			if i < len(cells) {
+				text := cleanInline(renderInlineChildren(cells[i]))
+				text = strings.ReplaceAll(text, hardBreak, "<br>")
+				parts[i] = strings.ReplaceAll(text, "|", `\|`)
+			}
+		}
+		return "| " + strings.Join(parts, " | ") + " |"
+	}
+
+	aligns := make([]string, cols)
+	for i := range aligns {
+		aligns[i] = "---"
+		if i < len(rows[0]) {
+			align := strings.ToLower(rows[0][i].attr("align"))
+			if m := textAlignRe.FindStringSubmatch(rows[0][i].attr("style")); m != nil {
+				align = strings.ToLower(m[1])
+			}
+			switch align {
+			case "left":
+				aligns[i] = ":---"
+			case "center":
+				aligns[i] = ":---:"
+			case "right":
+				aligns[i] = "---:"
+			}
+		}
+	}
+
+	out := []string{line(rows[0]), "| " + strings.Join(aligns, " | ") + " |"}
+	for _, r := range rows[1:] {
+		out = append(out, line(r))
+	}
+	return strings.Join(out, "\n")
+}
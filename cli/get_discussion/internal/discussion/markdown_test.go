@@ -0,0 +1,54 @@
+package discussion
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden .md files in testdata")
+
+// TestHTMLToMarkdownGolden converts every testdata/*.html page and compares
+// the result with the .md file of the same name.
+func TestHTMLToMarkdownGolden(t *testing.T) {
+	pages, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil || len(pages) == 0 {
+		t.Fatalf("no golden pages: %v", err)
+	}
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			got := htmlToMarkdown(input) + "\n"
+			golden := strings.TrimSuffix(page, ".html") + ".md"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatalf("write failed: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden failed: %v", err)
+			}
+			if got != string(want) {
+				t.Fatalf("markdown mismatch for %s\n--- got ---\n%s\n--- want ---\n%s", page, got, want)
+			}
+		})
+	}
+}
+
+func TestParseHTMLImplicitClose(t *testing.T) {
+	root := parseHTML(`<ul><li>a<li>b</ul><p>x<div>y</div>`)
+	ul := root.children[0]
+	if ul.tag != "ul" || len(ul.children) != 2 {
+		t.Fatalf("unexpected list: %+v", ul.children)
+	}
+	if len(root.children) != 3 || root.children[2].tag != "div" {
+		t.Fatalf("div should close the paragraph: %d children", len(root.children))
+	}
+}
@@ -0,0 +1,256 @@
+package discussion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// kaggleStatePush prefixes the bootstrapping data Kaggle inlines in pages:
+// Kaggle.State.push({...});
+const kaggleStatePush = "Kaggle.State.push("
+
+// extractPageState returns every JSON value embedded in the page's scripts:
+// Kaggle.State.push payloads plus application/json and application/ld+json
+// script bodies.
+func extractPageState(root *node) []any {
+	var values []any
+	root.walk(func(n *node) bool {
+		if n.typ != elementNode || n.tag != "script" {
+			return true
+		}
+		text := n.textContent()
+		typ := strings.ToLower(n.attr("type"))
+		if typ == "application/json" || typ == "application/ld+json" {
+			if v, ok := decodeJSONPrefix(strings.TrimSpace(text)); ok {
+				values = append(values, v)
+			}
+			return true
+		}
+		for rest := text; ; {
+			i := strings.Index(rest, kaggleStatePush)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(kaggleStatePush):]
+			if v, ok := decodeJSONPrefix(rest); ok {
+				values = append(values, v)
+			}
+		}
+		return true
+	})
+	return values
+}
+
+// decodeJSONPrefix decodes the first JSON value in s, ignoring what follows.
+func decodeJSONPrefix(s string) (any, bool) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// pageState collects the discussion payloads found in embedded JSON.
+type pageState struct {
+	topic    map[string]any
+	comments []any
+	posting  map[string]any
+}
+
+func findPageState(values []any) pageState {
+	var st pageState
+	var visit func(v any)
+	visit = func(v any) {
+		switch val := v.(type) {
+		case map[string]any:
+			if t, ok := val["forumTopic"].(map[string]any); ok && st.topic == nil {
+				if _, ok := t["name"]; ok {
+					st.topic = t
+				}
+			}
+			if c, ok := val["comments"].([]any); ok && isCommentList(c) && len(c) > len(st.comments) {
+				st.comments = c
+			}
+			if isForumPosting(val) && st.posting == nil {
+				st.posting = val
+			}
+			for _, child := range val {
+				visit(child)
+			}
+		case []any:
+			for _, child := range val {
+				visit(child)
+			}
+		}
+	}
+	for _, v := range values {
+		visit(v)
+	}
+	return st
+}
+
+func isCommentList(list []any) bool {
+	if len(list) == 0 {
+		return false
+	}
+	m, ok := list[0].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, hasID := m["id"]
+	_, hasMD := m["rawMarkdown"]
+	_, hasContent := m["content"]
+	return hasID && (hasMD || hasContent)
+}
+
+func isForumPosting(m map[string]any) bool {
+	t, _ := m["@type"].(string)
+	return t == "DiscussionForumPosting" || t == "SocialMediaPosting"
+}
+
+// flattenComments lifts nested "replies" into one list in thread order.
+func flattenComments(list []any) []any {
+	var out []any
+	for _, item := range list {
+		out = append(out, item)
+		if m, ok := item.(map[string]any); ok {
+			if replies, ok := m["replies"].([]any); ok {
+				out = append(out, flattenComments(replies)...)
+			}
+		}
+	}
+	return out
+}
+
+// discussionFromPageState builds a Discussion from the JSON state embedded in
+// a discussion page, with the same fields as the API path. ok is false when
+// the page carries no usable state.
+func discussionFromPageState(root *node, rawURL string) (*Discussion, bool) {
+	st := findPageState(extractPageState(root))
+
+	if st.topic != nil {
+		raw := &RawTopic{URL: rawURL}
+		if err := remarshal(map[string]any{"forumTopic": st.topic}, &raw.Topic); err != nil {
+			return nil, false
+		}
+		if st.comments != nil {
+			if err := remarshal(map[string]any{"comments": flattenComments(st.comments)}, &raw.Messages); err != nil {
+				return nil, false
+			}
+		}
+		raw.TopicID, _ = urlutil.ExtractTopicID(rawURL)
+		d, err := buildDiscussionFromRaw(raw)
+		if err != nil {
+			return nil, false
+		}
+		if d.ContentMD == "" && st.posting != nil {
+			fromPosting := discussionFromPosting(st.posting, rawURL)
+			d.ContentMD, d.Messages = fromPosting.ContentMD, fromPosting.Messages
+		}
+		return d, true
+	}
+	if st.posting != nil {
+		return discussionFromPosting(st.posting, rawURL), true
+	}
+	return nil, false
+}
+
+// discussionFromPosting maps a schema.org DiscussionForumPosting to a Discussion.
+func discussionFromPosting(p map[string]any, rawURL string) *Discussion {
+	main := Message{
+		ID:     postingID(p),
+		Author: personName(p["author"]),
+		Body:   normalizeKaggleMarkdown(strings.TrimSpace(urlutil.FirstNonEmpty(str(p["articleBody"]), str(p["text"])))),
+		IsMain: true,
+	}
+	messages := []Message{main}
+	if main.Body == "" {
+		messages = nil
+	}
+	if list, ok := p["comment"].([]any); ok {
+		for _, item := range list {
+			c, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			body := normalizeKaggleMarkdown(strings.TrimSpace(str(c["text"])))
+			if body == "" {
+				continue
+			}
+			messages = append(messages, Message{
+				ID:     postingID(c),
+				Author: personName(c["author"]),
+				Body:   body,
+			})
+		}
+	}
+
+	comments := str(p["commentCount"])
+	if comments == "" {
+		if stats, ok := p["interactionStatistic"].(map[string]any); ok {
+			comments = str(stats["userInteractionCount"])
+		}
+	}
+	link := urlutil.FirstNonEmpty(str(p["url"]), rawURL)
+	return &Discussion{
+		Title:         urlutil.FirstNonEmpty(str(p["headline"]), str(p["name"]), "untitled_discussion"),
+		Link:          urlutil.CanonicalizeURL(link),
+		Author:        main.Author,
+		Comments:      comments,
+		PublishedDate: str(p["datePublished"]),
+		ContentMD:     renderMessages(messages),
+		Messages:      messages,
+	}
+}
+
+func personName(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case map[string]any:
+		return urlutil.FirstNonEmpty(str(val["name"]), str(val["alternateName"]))
+	case []any:
+		if len(val) > 0 {
+			return personName(val[0])
+		}
+	}
+	return ""
+}
+
+// postingID reads a numeric id from "identifier" or the fragment of "@id"/"url".
+func postingID(m map[string]any) int {
+	var id int
+	for _, key := range []string{"identifier", "@id", "url"} {
+		s := str(m[key])
+		if i := strings.LastIndexAny(s, "#/"); i >= 0 {
+			s = s[i+1:]
+		}
+		if _, err := fmt.Sscanf(s, "%d", &id); err == nil {
+			return id
+		}
+	}
+	return 0
+}
+
+func str(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	}
+	return ""
+}
+
+func remarshal(in any, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
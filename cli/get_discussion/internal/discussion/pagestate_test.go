@@ -0,0 +1,47 @@
+package discussion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readState(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "state", name))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return data
+}
+
+func TestDiscussionFromKaggleState(t *testing.T) {
+	d, _ := discussionFromHTML(readState(t, "kaggle_state.html"), "https://www.kaggle.com/competitions/playground-series-s6e2/discussion/671783")
+	if d.Title != "Predicting Heart Disease" || d.Author != "Walter Reade" || d.Comments != "3" || d.PublishedDate != "2026-02-01T00:00:00Z" {
+		t.Fatalf("unexpected metadata: %+v", d)
+	}
+	if len(d.Messages) != 3 || d.Messages[1].ID != 102 || d.Messages[1].AuthorName != "nested" {
+		t.Fatalf("unexpected messages: %+v", d.Messages)
+	}
+	want := "Welcome to the **Playground**!\n\n---\n\n## Comment by Nested\n\nNested reply\n\n---\n\n## Comment by Someone\n\nThanks &amp; good luck"
+	if d.ContentMD != want {
+		t.Fatalf("unexpected content:\n%s", d.ContentMD)
+	}
+}
+
+func TestDiscussionFromLDJSON(t *testing.T) {
+	d, _ := discussionFromHTML(readState(t, "ld_json.html"), "https://www.kaggle.com/competitions/x/discussion/555")
+	if d.Title != "CV strategy?" || d.Author != "Alice" || d.Comments != "1" {
+		t.Fatalf("unexpected metadata: %+v", d)
+	}
+	if len(d.Messages) != 2 || d.Messages[1].ID != 556 || d.Messages[1].Author != "Bob" {
+		t.Fatalf("unexpected messages: %+v", d.Messages)
+	}
+}
+
+func TestDiscussionWithoutState(t *testing.T) {
+	d, _ := discussionFromHTML([]byte(`<html><body><h1>Plain</h1><p>Text</p></body></html>`), "https://www.kaggle.com/discussion/1")
+	if d.Title != "Plain" || d.ContentMD != "# Plain\n\nText" || d.Author != "" {
+		t.Fatalf("unexpected fallback: %+v", d)
+	}
+}
@@ -0,0 +1,181 @@
+package discussion
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Readability-style heuristics, tuned for Kaggle discussion pages: the post and
+// each comment are rendered into "markdown-converter__text" blocks while the
+// page chrome sits in nav, header, footer and sidebar containers.
+var (
+	// unlikelyRe matches whole words of classAndID, so "site-header__nav"
+	// and "topNav" are chrome while "unavailable" and "shared" are not.
+	unlikelyRe = regexp.MustCompile(`(?i)\b(?:nav|navbar|navigation|menu|menubar|header|footer|sidebar|cookies?|consent|banner|related|recommend(?:ed|ations?)?|share|sharing|social|breadcrumbs?|promo|promoted|advert|adverts|advertisement|login|log in|sign ?up|sign ?in|toolbar|popup|modal|newsletter)\b`)
+	// likelyRe matches whole singular words too: "markdown-converter__text"
+	// is the post, while "related-discussions" and "contextMenu" are not.
+	likelyRe = regexp.MustCompile(`(?i)\b(?:markdown|content|article|post|comment|discussion|message|topic|reply|text)\b`)
+)
+
+// chromeElements are dropped before scoring regardless of their class.
+var chromeElements = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true, "form": true, "dialog": true,
+}
+
+// scoredElements contribute their text to the score of their ancestors.
+var scoredElements = map[string]bool{
+	"p": true, "pre": true, "li": true, "td": true, "blockquote": true,
+	"h2": true, "h3": true, "h4": true,
+}
+
+const (
+	minConfidentLength = 400.0
+	// coverageRatio is the share of the page's paragraph score the chosen
+	// container must hold; the deepest such container wins.
+	coverageRatio = 0.85
+)
+
+// contentExtract is the main content of a page and how sure we are about it.
+type contentExtract struct {
+	node       *node
+	confidence float64
+}
+
+// markdown renders the kept content.
+func (e contentExtract) markdown() string {
+	return normalizeKaggleMarkdown(renderMarkdown(&node{typ: elementNode, tag: "div", children: []*node{e.node}}))
+}
+
+// extractMainContent keeps the discussion body and comment blocks of a page,
+// dropping site chrome, cookie banners and related-content lists. Every
+// paragraph-like element scores by length and commas, discounted by its link
+// density, and adds that score to all its ancestors. The deepest container
+// holding nearly all of the page's score is the content. The confidence in
+// [0, 1] grows with the amount of kept text and shrinks with its link density.
+func extractMainContent(root *node) contentExtract {
+	pruneChrome(root)
+
+	totals := map[*node]float64{}
+	root.walk(func(n *node) bool {
+		if n.typ != elementNode || !scoredElements[n.tag] {
+			return true
+		}
+		text := strings.TrimSpace(collapseSpace(n.textContent()))
+		if len(text) < 25 {
+			return true
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		score *= (1 - linkDensity(n)) * classWeight(n.parent)
+		for p := n; p != nil; p = p.parent {
+			totals[p] += score
+		}
+		return true
+	})
+
+	grand := totals[root]
+	if grand == 0 {
+		return contentExtract{node: root, confidence: 0}
+	}
+
+	top, topDepth := root, 0
+	var visit func(n *node, depth int)
+	visit = func(n *node, depth int) {
+		if totals[n] < grand*coverageRatio {
+			return
+		}
+		if depth > topDepth {
+			top, topDepth = n, depth
+		}
+		for _, c := range n.children {
+			visit(c, depth+1)
+		}
+	}
+	visit(root, 0)
+
+	textLen := len(strings.TrimSpace(collapseSpace(top.textContent())))
+	density := linkDensity(top)
+	confidence := 0.6*math.Min(1, float64(textLen)/minConfidentLength) + 0.4*(1-density)
+	if likelyRe.MatchString(classAndID(top)) {
+		confidence += 0.1
+	}
+	return contentExtract{node: top, confidence: math.Max(0, math.Min(1, confidence))}
+}
+
+// pruneChrome removes chrome elements and containers whose class or id marks
+// them as navigation, banners or related-content lists.
+func pruneChrome(n *node) {
+	kept := n.children[:0]
+	for _, c := range n.children {
+		if c.typ == elementNode {
+			if chromeElements[c.tag] || skippedElements[c.tag] {
+				continue
+			}
+			attrs := classAndID(c)
+			if attrs != "" && unlikelyRe.MatchString(attrs) && !likelyRe.MatchString(attrs) {
+				continue
+			}
+			pruneChrome(c)
+		}
+		kept = append(kept, c)
+	}
+	n.children = kept
+}
+
+// classAndID returns the words of the class and id of n, lowercased and
+// space-separated: "site-header__nav topNav" becomes
+// "site header nav top nav".
+func classAndID(n *node) string {
+	raw := n.attr("class") + " " + n.attr("id")
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c >= 'A' && c <= 'Z':
+			if i > 0 && raw[i-1] >= 'a' && raw[i-1] <= 'z' {
+				b.WriteByte(' ')
+			}
+			b.WriteByte(c + 'a' - 'A')
+		case c == '-' || c == '_' || c == ' ' || c == '\t' || c == '\n':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func classWeight(n *node) float64 {
+	if n == nil {
+		return 1
+	}
+	attrs := classAndID(n)
+	switch {
+	case attrs == "":
+		return 1
+	case likelyRe.MatchString(attrs):
+		return 1.25
+	case unlikelyRe.MatchString(attrs):
+		return 0.5
+	}
+	return 1
+}
+
+func linkDensity(n *node) float64 {
+	total := len(strings.TrimSpace(collapseSpace(n.textContent())))
+	if total == 0 {
+		return 0
+	}
+	return math.Min(1, float64(linkTextLength(n))/float64(total))
+}
+
+func linkTextLength(n *node) int {
+	total := 0
+	n.walk(func(c *node) bool {
+		if c.typ == elementNode && c.tag == "a" {
+			total += len(strings.TrimSpace(collapseSpace(c.textContent())))
+		}
+		return true
+	})
+	return total
+}
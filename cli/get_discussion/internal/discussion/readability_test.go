@@ -0,0 +1,83 @@
+package discussion
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractMainContent(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "readability", "topic_with_chrome.html"))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	ex := extractMainContent(parseHTML(string(data)))
+	md := ex.markdown()
+	for _, want := range []string{"# Leak in the test set?", "row order of the test set", "Good catch.", "Confirmed on our side"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("missing %q in:\n%s", want, md)
+		}
+	}
+	for _, chrome := range []string{"Competitions", "cookies", "Related discussions", "Privacy"} {
+		if strings.Contains(md, chrome) {
+			t.Fatalf("chrome %q kept in:\n%s", chrome, md)
+		}
+	}
+	if ex.confidence < 0.8 {
+		t.Fatalf("unexpected low confidence: %.2f", ex.confidence)
+	}
+}
+
+func TestExtractMainContentLowConfidence(t *testing.T) {
+	page := `<html><body><div class="menu"><a href="/a">A</a></div><div><p><a href="/x">A link-only paragraph that is long enough</a></p></div></body></html>`
+	ex := extractMainContent(parseHTML(page))
+	if ex.confidence >= 0.5 {
+		t.Fatalf("expected low confidence, got %.2f", ex.confidence)
+	}
+}
+
+func TestUnlikelyMatchesWholeWords(t *testing.T) {
+	for attrs, want := range map[string]bool{
+		`class="site-header-react__nav"`: true,
+		`id="topNav"`:                    true,
+		`class="sign-up-modal"`:          true,
+		`class="unavailable"`:            false,
+		`class="shared-post"`:            false,
+		`class="menuitem-count"`:         false,
+	} {
+		n := parseHTML("<div " + attrs + "></div>").children[0]
+		if got := unlikelyRe.MatchString(classAndID(n)); got != want {
+			t.Errorf("%s: unlikely=%v, want %v (words %q)", attrs, got, want, classAndID(n))
+		}
+	}
+}
+
+func TestRelatedListDoesNotOutscorePost(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<html><body><main><div class="markdown-converter__text"><p>Our post: the validation split leaks, so shuffle the folds before training.</p></div>`)
+	b.WriteString(`<ul class="related-discussions">`)
+	for i := 0; i < 20; i++ {
+		b.WriteString(`<li>Another thread about feature engineering, tuning, ensembling and leaderboard shake-ups</li>`)
+	}
+	b.WriteString(`</ul></main></body></html>`)
+	md := extractMainContent(parseHTML(b.String())).markdown()
+	if !strings.Contains(md, "validation split leaks") || strings.Contains(md, "Another thread") {
+		t.Fatalf("related list kept or post lost:\n%s", md)
+	}
+}
+
+func TestLikelyMatchesWholeWords(t *testing.T) {
+	for attrs, want := range map[string]bool{
+		`class="markdown-converter__text"`: true,
+		`class="topic-content"`:            true,
+		`class="related-discussions"`:      false,
+		`class="postcode-widget"`:          false,
+		`id="contextMenu"`:                 false,
+	} {
+		n := parseHTML("<div " + attrs + "></div>").children[0]
+		if got := likelyRe.MatchString(classAndID(n)); got != want {
+			t.Errorf("%s: likely=%v, want %v (words %q)", attrs, got, want, classAndID(n))
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package discussion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/fsutil"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// DiscussionSource and ListRequest are defined in pkg/source; the sources
+// below are its built-in implementations.
+type (
+	DiscussionSource = source.DiscussionSource
+	ListRequest      = source.ListRequest
+)
+
+// RawTopic is the unrendered API payload of a topic as stored by APISource
+// and read back by ArchiveSource.
+type RawTopic struct {
+	URL       string               `json:"url"`
+	TopicID   int                  `json:"topic_id"`
+	FetchedAt time.Time            `json:"fetched_at"`
+	Topic     api.TopicResponse    `json:"topic"`
+	Messages  api.MessagesResponse `json:"messages"`
+}
+
+// APISource uses Kaggle's internal discussion API. When RawDir is set every
+// fetched payload is also written there for ArchiveSource.
+type APISource struct {
+	Client *client.Client
+	RawDir string
+	// Fsync flushes each raw payload to disk.
+	Fsync bool
+}
+
+func (s *APISource) Name() string { return "api" }
+
+func (s *APISource) ListTopics(req ListRequest) ([]string, error) {
+	if req.ForumID == 0 {
+		return nil, fmt.Errorf("no forum id: %w", source.ErrUnsupported)
+	}
+	topics, err := api.FetchTopicListByForumID(s.Client, req.ForumID, req.SortKey, req.TimeKey, req.Limit, req.Filter)
+	if err == nil && len(topics) == 0 && req.Filter.Active() {
+		return nil, source.ErrFilteredOut
+	}
+	return api.SummaryLinks(topics), err
+}
+
+func (s *APISource) FetchDiscussion(rawURL string) (*Discussion, error) {
+	topicID, ok := urlutil.ExtractTopicID(rawURL)
+	if !ok {
+		return nil, fmt.Errorf("no topic ID in URL: %w", source.ErrUnsupported)
+	}
+	raw, err := fetchRawTopic(s.Client, rawURL, topicID)
+	if err != nil {
+		return nil, err
+	}
+	if s.RawDir != "" {
+		if err := writeRawTopic(s.RawDir, raw, s.Fsync); err != nil {
+			return nil, fmt.Errorf("archive raw payload: %w", err)
+		}
+	}
+	return buildDiscussionFromRaw(raw)
+}
+
+// warnConfidence is the content confidence below which HTMLSource warns.
+const warnConfidence = 0.5
+
+// HTMLSource scrapes discussion pages and listing pages. Pages whose main
+// content is extracted with a confidence below MinConfidence are rejected.
+type HTMLSource struct {
+	Client        *client.Client
+	MinConfidence float64
+}
+
+func (s *HTMLSource) Name() string { return "html" }
+
+func (s *HTMLSource) ListTopics(req ListRequest) ([]string, error) {
+	if len(req.ListingURLs) == 0 {
+		return nil, fmt.Errorf("no listing url: %w", source.ErrUnsupported)
+	}
+	var lastErr error
+	for i, listingURL := range req.ListingURLs {
+		if i > 0 {
+			s.Client.LogInfo("Retrying with listing url=%s", listingURL)
+		}
+		body, err := s.Client.FetchBody(listingURL, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		urls := ExtractDiscussionLinksFromHTML(body, listingURL)
+		if req.Limit > 0 && len(urls) > req.Limit {
+			urls = urls[:req.Limit]
+		}
+		if len(urls) > 0 {
+			return urls, nil
+		}
+		s.Client.LogInfo("No discussion links found on listing url=%s", listingURL)
+	}
+	return nil, lastErr
+}
+
+func (s *HTMLSource) FetchDiscussion(rawURL string) (*Discussion, error) {
+	body, err := s.Client.FetchBody(rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	d, confidence := discussionFromHTML(body, rawURL)
+	if confidence < s.MinConfidence {
+		return nil, fmt.Errorf("content confidence %.2f below %.2f", confidence, s.MinConfidence)
+	}
+	if confidence < warnConfidence {
+		log.Printf("[warn] Low content confidence %.2f for %s", confidence, rawURL)
+		d.Warnings = append(d.Warnings, fmt.Sprintf("low content confidence %.2f", confidence))
+	}
+	return d, nil
+}
+
+// ArchiveSource re-renders discussions from raw payloads saved by APISource.
+type ArchiveSource struct {
+	Dir string
+}
+
+func (s *ArchiveSource) Name() string { return "archive" }
+
+// ListTopics returns the archived topics of the forum selected by
+// req.TopicPath, in topic ID order and up to req.Limit. Without a forum
+// selection the whole archive is listed so it can be re-rendered. Archived
+// payloads do not record their forum ID, so a request that selects a forum
+// by ID alone is unsupported.
+func (s *ArchiveSource) ListTopics(req ListRequest) ([]string, error) {
+	if req.TopicPath == "" && req.ForumID != 0 {
+		return nil, fmt.Errorf("forum id without topic path: %w", source.ErrUnsupported)
+	}
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimSuffix(name, ".json")); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	var urls []string
+	for _, id := range ids {
+		raw, err := readRawTopic(s.Dir, id)
+		if err != nil {
+			continue
+		}
+		if req.TopicPath != "" && !strings.Contains(raw.URL, req.TopicPath) && !strings.Contains(raw.Topic.ForumTopic.URL, req.TopicPath) {
+			continue
+		}
+		urls = append(urls, urlutil.CanonicalizeURL(raw.URL))
+		if req.Limit > 0 && len(urls) >= req.Limit {
+			break
+		}
+	}
+	return urls, nil
+}
+
+func (s *ArchiveSource) FetchDiscussion(rawURL string) (*Discussion, error) {
+	topicID, ok := urlutil.ExtractTopicID(rawURL)
+	if !ok {
+		return nil, fmt.Errorf("no topic ID in URL: %w", source.ErrUnsupported)
+	}
+	raw, err := readRawTopic(s.Dir, topicID)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("topic %d not archived: %w", topicID, source.ErrUnsupported)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buildDiscussionFromRaw(raw)
+}
+
+func rawTopicPath(dir string, topicID int) string {
+	return filepath.Join(dir, strconv.Itoa(topicID)+".json")
+}
+
+func writeRawTopic(dir string, raw *RawTopic, sync bool) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(rawTopicPath(dir, raw.TopicID), data, 0o644, sync)
+}
+
+func readRawTopic(dir string, topicID int) (*RawTopic, error) {
+	data, err := os.ReadFile(rawTopicPath(dir, topicID))
+	if err != nil {
+		return nil, err
+	}
+	var raw RawTopic
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return &raw, nil
+}
+
+// DefaultSources is the classic chain: the API, then HTML scraping.
+func DefaultSources(c *client.Client) []DiscussionSource {
+	return []DiscussionSource{&APISource{Client: c}, &HTMLSource{Client: c}}
+}
+
+// SourceOptions configures the sources built by ParseSources.
+type SourceOptions struct {
+	// RawDir is where the API archives payloads and the archive source reads them.
+	RawDir string
+	// MinConfidence is passed to HTMLSource.
+	MinConfidence float64
+	// Fsync is passed to APISource.
+	Fsync bool
+}
+
+// ParseSources builds sources from a comma-separated order such as
+// "api,html" or "archive,api".
+func ParseSources(spec string, c *client.Client, opts SourceOptions) ([]DiscussionSource, error) {
+	rawDir := opts.RawDir
+	var sources []DiscussionSource
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "":
+			continue
+		case "api":
+			sources = append(sources, &APISource{Client: c, RawDir: rawDir, Fsync: opts.Fsync})
+		case "html":
+			sources = append(sources, &HTMLSource{Client: c, MinConfidence: opts.MinConfidence})
+		case "archive":
+			if rawDir == "" {
+				return nil, fmt.Errorf("archive source requires a raw directory")
+			}
+			sources = append(sources, &ArchiveSource{Dir: rawDir})
+		default:
+			return nil, fmt.Errorf("unknown source: %s", name)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources in %q", spec)
+	}
+	return sources, nil
+}
@@ -0,0 +1,75 @@
+package discussion
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
+)
+
+func TestArchiveSourceRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	raw := &RawTopic{URL: "https://www.kaggle.com/discussion/42?x=1", TopicID: 42, FetchedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	raw.Topic.ForumTopic.Name = "Archived"
+	raw.Topic.ForumTopic.URL = "/discussion/42"
+	raw.Messages = api.MessagesResponse{Comments: []api.ForumComment{{ID: 1, RawMarkdown: "Body"}}}
+	if err := writeRawTopic(dir, raw, false); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	src := &ArchiveSource{Dir: dir}
+	urls, err := src.ListTopics(ListRequest{})
+	if err != nil || len(urls) != 1 || urls[0] != "https://www.kaggle.com/discussion/42" {
+		t.Fatalf("unexpected listing: %v err=%v", urls, err)
+	}
+	d, err := src.FetchDiscussion(urls[0])
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if d.Title != "Archived" || d.ContentMD != "Body" || d.Link != "https://www.kaggle.com/discussion/42" || !d.FetchedAt.Equal(raw.FetchedAt) {
+		t.Fatalf("unexpected discussion: %+v", d)
+	}
+	if _, err := src.FetchDiscussion("https://www.kaggle.com/discussion/7"); !errors.Is(err, source.ErrUnsupported) {
+		t.Fatalf("expected unsupported for missing topic, got %v", err)
+	}
+}
+
+func TestArchiveSourceListsRequestedForum(t *testing.T) {
+	dir := t.TempDir()
+	for id, link := range map[int]string{
+		1: "https://www.kaggle.com/competitions/titanic/discussion/1",
+		2: "https://www.kaggle.com/competitions/other/discussion/2",
+		3: "https://www.kaggle.com/competitions/titanic/discussion/3",
+		4: "https://www.kaggle.com/competitions/titanic/discussion/4",
+	} {
+		if err := writeRawTopic(dir, &RawTopic{URL: link, TopicID: id}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := &ArchiveSource{Dir: dir}
+	urls, err := src.ListTopics(ListRequest{TopicPath: "/competitions/titanic/discussion/", Limit: 2})
+	if err != nil || len(urls) != 2 || urls[0] != "https://www.kaggle.com/competitions/titanic/discussion/1" || urls[1] != "https://www.kaggle.com/competitions/titanic/discussion/3" {
+		t.Fatalf("unexpected listing: %v err=%v", urls, err)
+	}
+	if urls, _ := src.ListTopics(ListRequest{}); len(urls) != 4 {
+		t.Fatalf("unscoped listing should cover the archive: %v", urls)
+	}
+	if _, err := src.ListTopics(ListRequest{ForumID: 9}); !errors.Is(err, source.ErrUnsupported) {
+		t.Fatalf("expected unsupported for a forum id alone, got %v", err)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	sources, err := ParseSources("archive, api,html", nil, SourceOptions{RawDir: "raw"})
+	if err != nil || len(sources) != 3 || sources[0].Name() != "archive" {
+		t.Fatalf("unexpected sources: %v err=%v", sources, err)
+	}
+	if _, err := ParseSources("archive", nil, SourceOptions{}); err == nil {
+		t.Fatalf("expected error without raw dir")
+	}
+	if _, err := ParseSources("ftp", nil, SourceOptions{}); err == nil {
+		t.Fatalf("expected error for unknown source")
+	}
+}
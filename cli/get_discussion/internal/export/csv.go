@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// summaryHeader are the columns of summary.csv. The link identifies a row
+// when the summary is written again.
+var summaryHeader = []string{"title", "author", "votes", "comments", "date", "path", "link"}
+
+// summaryWriter collects one row per thread in summary.csv, keeping the rows
+// of threads not written in this run.
+type summaryWriter struct {
+	path  string
+	dir   string
+	sync  bool
+	rows  map[string][]string
+	order []string
+}
+
+func (w *summaryWriter) Write(d *discussion.Discussion, path string) error {
+	link := urlutil.CanonicalizeURL(d.Link)
+	date := d.PublishedDate
+	if t, ok := storage.ParseDate(date); ok {
+		date = t.Format("2006-01-02")
+	}
+	row := []string{d.Title, d.Author, optionalInt(votes(d)), optionalInt(count(d.Comments)), date, relPath(w.dir, path), link}
+	if w.rows == nil {
+		w.rows = map[string][]string{}
+	}
+	if _, ok := w.rows[link]; !ok {
+		w.order = append(w.order, link)
+	}
+	w.rows[link] = row
+	return nil
+}
+
+func (w *summaryWriter) Path(string) string { return w.path }
+
+func (w *summaryWriter) Close() error {
+	if len(w.order) == 0 {
+		return nil
+	}
+	rows := [][]string{summaryHeader}
+	written := map[string]bool{}
+	if data, err := os.ReadFile(w.path); err == nil {
+		old, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return err
+		}
+		rows = append(rows, w.merge(old, written)...)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for _, link := range w.order {
+		if !written[link] {
+			rows = append(rows, w.rows[link])
+		}
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	for _, row := range rows {
+		safe := make([]string, len(row))
+		for i, cell := range row {
+			safe[i] = safeCell(cell)
+		}
+		cw.Write(safe)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(w.path, buf.Bytes(), 0o644, w.sync)
+}
+
+// merge returns the rows of an existing summary in the current columns,
+// replacing the rows of threads written in this run and marking them in
+// written.
+func (w *summaryWriter) merge(old [][]string, written map[string]bool) [][]string {
+	if len(old) == 0 {
+		return nil
+	}
+	column := map[string]int{}
+	for i, name := range old[0] {
+		column[name] = i
+	}
+	var rows [][]string
+	for _, rec := range old[1:] {
+		row := make([]string, len(summaryHeader))
+		for i, name := range summaryHeader {
+			if j, ok := column[name]; ok && j < len(rec) {
+				row[i] = rec[j]
+			}
+		}
+		link := row[len(row)-1]
+		if next, ok := w.rows[link]; ok {
+			if written[link] {
+				continue
+			}
+			written[link] = true
+			row = next
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// safeCell prefixes cells that spreadsheets would run as a formula, such as a
+// title starting with "=", with an apostrophe. Numbers are left as they are.
+func safeCell(s string) string {
+	if s == "" || !strings.ContainsRune("=+-@", rune(s[0])) {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	return "'" + s
+}
+
+func optionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
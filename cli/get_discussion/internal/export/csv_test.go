@@ -0,0 +1,71 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSummaryWriterMerges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, SummaryFile)
+	os.WriteFile(path, []byte("title,link,path\nKept,https://www.kaggle.com/discussion/7,kept.md\nOld,https://www.kaggle.com/competitions/x/discussion/42,old.md\n"), 0o644)
+
+	w := &summaryWriter{path: path, dir: dir}
+	if err := w.Write(sample(), filepath.Join(dir, "sub", "new.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "title,author,votes,comments,date,path,link\n" +
+		"Kept,,,,,kept.md,https://www.kaggle.com/discussion/7\n" +
+		"\"Validation, CV and LB\",alice,12,1024,2024-03-01,sub/new.md,https://www.kaggle.com/competitions/x/discussion/42\n"
+	if string(data) != want {
+		t.Errorf("summary:\n%s\nwant:\n%s", data, want)
+	}
+
+	// Nothing written, nothing touched.
+	os.Remove(path)
+	if err := (&summaryWriter{path: path, dir: dir}).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("empty run wrote a summary")
+	}
+}
+
+func TestSummaryWriterEscapesFormulas(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, SummaryFile)
+	d := sample()
+	d.Title = `=HYPERLINK("http://evil","click")`
+	d.Author = "@bob"
+	d.Messages[0].Votes = -3
+	w := &summaryWriter{path: path, dir: dir}
+	if err := w.Write(d, filepath.Join(dir, "t.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "title,author,votes,comments,date,path,link\n" +
+		`"'=HYPERLINK(""http://evil"",""click"")",'@bob,-3,1024,2024-03-01,t.md,https://www.kaggle.com/competitions/x/discussion/42` + "\n"
+	if string(data) != want {
+		t.Errorf("summary:\n%s\nwant:\n%s", data, want)
+	}
+
+	// Rows read back are not prefixed twice.
+	w = &summaryWriter{path: path, dir: dir}
+	if err := w.Write(d, filepath.Join(dir, "t.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != want {
+		t.Errorf("summary after a second run:\n%s", again)
+	}
+}
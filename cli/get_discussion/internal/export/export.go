@@ -0,0 +1,155 @@
+// Package export writes discussions in formats other than the Markdown files
+// of the storage package: JSON, JSON Lines, HTML and a CSV summary.
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// Writer saves discussions in one format.
+type Writer interface {
+	// Write saves d, whose Markdown file is (or would be) at path.
+	// Per-thread formats write next to it with their own extension.
+	Write(d *discussion.Discussion, path string) error
+	// Path returns the file Write saves the thread at path to.
+	Path(path string) string
+	// Close writes the files that collect every thread.
+	Close() error
+}
+
+// Files that collect every thread of an output directory.
+const (
+	JSONLFile   = "discussions.jsonl"
+	SummaryFile = "summary.csv"
+)
+
+// ParseFormats builds the writers of a comma-separated format list such as
+// "md,json,html". Markdown is written by the storage package, so "md" only
+// sets markdown. The collecting formats write to outputDir, and with sync set
+// every file is flushed to disk.
+func ParseFormats(spec, outputDir string, sync bool) (markdown bool, writers []Writer, err error) {
+	seen := map[string]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case "md", "markdown":
+			markdown = true
+		case "json":
+			writers = append(writers, &jsonWriter{dir: outputDir, sync: sync})
+		case "jsonl":
+			writers = append(writers, &jsonlWriter{path: filepath.Join(outputDir, JSONLFile), dir: outputDir, sync: sync})
+		case "html":
+			writers = append(writers, &htmlWriter{sync: sync})
+		case "csv":
+			writers = append(writers, &summaryWriter{path: filepath.Join(outputDir, SummaryFile), dir: outputDir, sync: sync})
+		default:
+			return false, nil, fmt.Errorf("unknown format: %s (want md, json, jsonl, html or csv)", name)
+		}
+	}
+	if !markdown && len(writers) == 0 {
+		return false, nil, fmt.Errorf("no formats in %q", spec)
+	}
+	return markdown, writers, nil
+}
+
+// Document is the JSON form of a discussion, shared by the json and jsonl
+// formats.
+type Document struct {
+	TopicID       int            `json:"topic_id,omitempty"`
+	Title         string         `json:"title"`
+	Link          string         `json:"link"`
+	Author        string         `json:"author,omitempty"`
+	Votes         *int           `json:"votes,omitempty"`
+	Comments      *int           `json:"comments,omitempty"`
+	PublishedDate string         `json:"published_date,omitempty"`
+	Source        string         `json:"source,omitempty"`
+	FetchedAt     *time.Time     `json:"fetched_at,omitempty"`
+	Warnings      []string       `json:"warnings,omitempty"`
+	Extra         map[string]any `json:"extra,omitempty"`
+	// Path is the Markdown file of the thread relative to the output
+	// directory, slash-separated.
+	Path string `json:"path,omitempty"`
+	// Messages is empty for discussions from the HTML fallback; ContentMD
+	// always holds the rendered thread.
+	Messages  []Message `json:"messages"`
+	ContentMD string    `json:"content_md"`
+}
+
+// Message is one post of a Document.
+type Message struct {
+	ID         int    `json:"id"`
+	Author     string `json:"author"`
+	AuthorName string `json:"author_name,omitempty"`
+	Votes      int    `json:"votes"`
+	IsMain     bool   `json:"is_main,omitempty"`
+	Body       string `json:"body"`
+}
+
+// NewDocument converts d; rel is its Markdown path relative to the output
+// directory.
+func NewDocument(d *discussion.Discussion, rel string) Document {
+	doc := Document{
+		Title:         d.Title,
+		Link:          urlutil.CanonicalizeURL(d.Link),
+		Author:        d.Author,
+		Votes:         votes(d),
+		Comments:      count(d.Comments),
+		PublishedDate: d.PublishedDate,
+		Source:        d.Source,
+		Warnings:      d.Warnings,
+		Extra:         d.Extra,
+		Path:          filepath.ToSlash(rel),
+		Messages:      make([]Message, len(d.Messages)),
+		ContentMD:     strings.TrimSpace(d.ContentMD),
+	}
+	doc.TopicID, _ = urlutil.ExtractTopicID(d.Link)
+	if !d.FetchedAt.IsZero() {
+		t := d.FetchedAt.UTC()
+		doc.FetchedAt = &t
+	}
+	for i, m := range d.Messages {
+		doc.Messages[i] = Message{ID: m.ID, Author: m.Author, AuthorName: m.AuthorName, Votes: m.Votes, IsMain: m.IsMain, Body: m.Body}
+	}
+	return doc
+}
+
+// votes returns the votes of the opening post, which Kaggle shows as the
+// votes of the topic.
+func votes(d *discussion.Discussion) *int {
+	for _, m := range d.Messages {
+		if m.IsMain {
+			n := m.Votes
+			return &n
+		}
+	}
+	return nil
+}
+
+// count reads a comment count such as "1,024".
+func count(s string) *int {
+	n, err := strconv.Atoi(strings.ReplaceAll(strings.TrimSpace(s), ",", ""))
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+// relPath returns path relative to dir, slash-separated.
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
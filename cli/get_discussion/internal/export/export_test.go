@@ -0,0 +1,61 @@
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func sample() *discussion.Discussion {
+	return &discussion.Discussion{
+		Title:         "Validation, CV and LB",
+		Link:          "https://www.kaggle.com/competitions/x/discussion/42?sort=votes",
+		Author:        "alice",
+		Comments:      "1,024",
+		PublishedDate: "2024-03-01T10:00:00Z",
+		ContentMD:     "Use **group** k-fold.\n\n---\n\n## Comment by bob\n\nThanks!",
+		Source:        "api",
+		FetchedAt:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Messages: []discussion.Message{
+			{ID: 1, Author: "alice", AuthorName: "alice1", Body: "Use **group** k-fold.", IsMain: true, Votes: 12},
+			{ID: 2, Author: "bob", Body: "Thanks!", Votes: 1},
+		},
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	markdown, writers, err := ParseFormats("md, JSON,jsonl,html,csv,json", "out", false)
+	if err != nil || !markdown || len(writers) != 4 {
+		t.Fatalf("got markdown=%v writers=%d err=%v", markdown, len(writers), err)
+	}
+	if markdown, writers, err := ParseFormats("jsonl", "out", false); err != nil || markdown || len(writers) != 1 {
+		t.Errorf("jsonl only: markdown=%v writers=%d err=%v", markdown, len(writers), err)
+	}
+	for _, spec := range []string{"pdf", "", " , "} {
+		if _, _, err := ParseFormats(spec, "out", false); err == nil {
+			t.Errorf("ParseFormats(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	doc := NewDocument(sample(), "validation_cv_and_lb_42.md")
+	if doc.TopicID != 42 || doc.Link != "https://www.kaggle.com/competitions/x/discussion/42" {
+		t.Errorf("unexpected identity: %d %s", doc.TopicID, doc.Link)
+	}
+	if doc.Votes == nil || *doc.Votes != 12 || doc.Comments == nil || *doc.Comments != 1024 {
+		t.Errorf("unexpected counts: votes=%v comments=%v", doc.Votes, doc.Comments)
+	}
+	if len(doc.Messages) != 2 || doc.Messages[0].AuthorName != "alice1" || !doc.Messages[0].IsMain || doc.Messages[1].Votes != 1 {
+		t.Errorf("unexpected messages: %+v", doc.Messages)
+	}
+	if doc.FetchedAt == nil || doc.Source != "api" || doc.Path != "validation_cv_and_lb_42.md" {
+		t.Errorf("unexpected provenance: %+v", doc)
+	}
+
+	html := &discussion.Discussion{Title: "t", Link: "https://www.kaggle.com/discussion/1", Comments: "n/a", ContentMD: "Body"}
+	if doc := NewDocument(html, "t.md"); doc.Votes != nil || doc.Comments != nil || doc.Messages == nil || doc.FetchedAt != nil {
+		t.Errorf("unexpected document for an HTML discussion: %+v", doc)
+	}
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+)
+
+// htmlWriter saves each thread as a standalone page in <slug>.html.
+type htmlWriter struct {
+	sync bool
+}
+
+func (w *htmlWriter) Write(d *discussion.Discussion, path string) error {
+	data, err := renderPage(d)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(w.Path(path), data, 0o644, w.sync)
+}
+
+func (w *htmlWriter) Path(path string) string { return strings.TrimSuffix(path, ".md") + ".html" }
+
+func (w *htmlWriter) Close() error { return nil }
+
+type page struct {
+	Title, Author, Date, Link string
+	Votes                     *int
+	Sections                  []pageSection
+}
+
+type pageSection struct {
+	ID     int
+	Author string
+	Votes  int
+	Main   bool
+	Body   template.HTML
+}
+
+// renderPage renders d as a standalone HTML page: the opening post, then
+// one section per comment. Discussions without structured messages are
+// rendered from their Markdown as a whole.
+func renderPage(d *discussion.Discussion) ([]byte, error) {
+	p := page{Title: d.Title, Author: d.Author, Link: d.Link, Votes: votes(d)}
+	if t, ok := storage.ParseDate(d.PublishedDate); ok {
+		p.Date = t.Format("2006-01-02")
+	}
+	for _, m := range d.Messages {
+		author := m.Author
+		if author == "" {
+			author = "Unknown"
+		}
+		p.Sections = append(p.Sections, pageSection{ID: m.ID, Author: author, Votes: m.Votes, Main: m.IsMain, Body: template.HTML(renderMarkdown(m.Body))})
+	}
+	if len(p.Sections) == 0 {
+		p.Sections = []pageSection{{Main: true, Body: template.HTML(renderMarkdown(d.ContentMD))}}
+	}
+	var buf bytes.Buffer
+	err := pageTemplate.Execute(&buf, p)
+	return buf.Bytes(), err
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 48rem; margin: 2rem auto; padding: 0 1rem; font: 16px/1.6 system-ui, sans-serif; color: #222; }
+pre { background: #f6f8fa; padding: .75rem; overflow: auto; }
+code { font-family: ui-monospace, monospace; font-size: .9em; }
+blockquote { margin: 0; padding-left: 1rem; border-left: 3px solid #ddd; color: #555; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: .25rem .5rem; }
+img { max-width: 100%; }
+.meta { color: #666; font-size: .9em; }
+section.comment { border-top: 1px solid #eee; margin-top: 1.5rem; }
+</style>
+</head>
+<body>
+<article>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">{{with .Author}}{{.}} · {{end}}{{with .Date}}{{.}} · {{end}}{{with .Votes}}{{.}} votes · {{end}}<a href="{{.Link}}">{{.Link}}</a></p>
+</header>
+{{range .Sections}}{{if .Main}}<section class="post"{{with .ID}} id="message-{{.}}"{{end}}>
+{{else}}<section class="comment"{{with .ID}} id="message-{{.}}"{{end}}>
+<h2>Comment by {{.Author}} <span class="meta">{{.Votes}} votes</span></h2>
+{{end}}{{.Body}}</section>
+{{end}}</article>
+</body>
+</html>
+`))
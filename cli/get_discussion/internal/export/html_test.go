@@ -0,0 +1,42 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func TestHTMLWriter(t *testing.T) {
+	dir := t.TempDir()
+	d := sample()
+	d.Title = "CV <vs> LB"
+	if err := (&htmlWriter{}).Write(d, filepath.Join(dir, "2024", "thread.md")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "2024", "thread.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		"<title>CV &lt;vs&gt; LB</title>",
+		"alice · 2024-03-01 · 12 votes · ",
+		`<section class="post" id="message-1">`,
+		"Use <strong>group</strong> k-fold.",
+		`<section class="comment" id="message-2">`,
+		`<h2>Comment by bob <span class="meta">1 votes</span></h2>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q:\n%s", want, page)
+		}
+	}
+
+	fallback := &discussion.Discussion{Title: "t", Link: "https://www.kaggle.com/discussion/1", ContentMD: "Only *markdown*"}
+	data, err = renderPage(fallback)
+	if err != nil || !strings.Contains(string(data), "<p>Only <em>markdown</em></p>") {
+		t.Errorf("fallback page: %s (%v)", data, err)
+	}
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+)
+
+// jsonWriter saves each thread as an indented Document in <slug>.json.
+type jsonWriter struct {
+	dir  string
+	sync bool
+}
+
+func (w *jsonWriter) Write(d *discussion.Discussion, path string) error {
+	data, err := json.MarshalIndent(NewDocument(d, relPath(w.dir, path)), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(w.Path(path), append(data, '\n'), 0o644, w.sync)
+}
+
+func (w *jsonWriter) Path(path string) string { return strings.TrimSuffix(path, ".md") + ".json" }
+
+func (w *jsonWriter) Close() error { return nil }
+
+// jsonlWriter collects every thread as one Document per line of
+// discussions.jsonl. Lines of threads not written in this run are kept, and
+// threads written again replace their line.
+type jsonlWriter struct {
+	path  string
+	dir   string
+	sync  bool
+	lines map[string][]byte
+	order []string
+}
+
+func (w *jsonlWriter) Write(d *discussion.Discussion, path string) error {
+	doc := NewDocument(d, relPath(w.dir, path))
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if w.lines == nil {
+		w.lines = map[string][]byte{}
+	}
+	if _, ok := w.lines[doc.Link]; !ok {
+		w.order = append(w.order, doc.Link)
+	}
+	w.lines[doc.Link] = line
+	return nil
+}
+
+func (w *jsonlWriter) Path(string) string { return w.path }
+
+func (w *jsonlWriter) Close() error {
+	if len(w.order) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	written := map[string]bool{}
+	if f, err := os.Open(w.path); err == nil {
+		sc := bufio.NewScanner(f)
+		sc.Buffer(nil, 64<<20)
+		for sc.Scan() {
+			line := bytes.TrimSpace(sc.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var key struct {
+				Link string `json:"link"`
+			}
+			json.Unmarshal(line, &key)
+			if next, ok := w.lines[key.Link]; ok {
+				if written[key.Link] {
+					continue
+				}
+				written[key.Link] = true
+				line = next
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		err := sc.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for _, link := range w.order {
+		if !written[link] {
+			buf.Write(w.lines[link])
+			buf.WriteByte('\n')
+		}
+	}
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(w.path, buf.Bytes(), 0o644, w.sync)
+}
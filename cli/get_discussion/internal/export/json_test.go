@@ -0,0 +1,52 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := &jsonWriter{dir: dir}
+	path := filepath.Join(dir, "2024", "thread.md")
+	if err := w.Write(sample(), path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "2024", "thread.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil || doc.Path != "2024/thread.md" || len(doc.Messages) != 2 {
+		t.Fatalf("unexpected document: %+v (%v)", doc, err)
+	}
+}
+
+func TestJSONLWriterMerges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, JSONLFile)
+	os.WriteFile(path, []byte(`{"link":"https://www.kaggle.com/discussion/7","title":"kept","custom":true}`+"\n"+
+		`{"link":"https://www.kaggle.com/competitions/x/discussion/42","title":"old"}`+"\n"), 0o644)
+
+	w := &jsonlWriter{path: path, dir: dir}
+	d := sample()
+	w.Write(d, filepath.Join(dir, "a.md"))
+	other := sample()
+	other.Link = "https://www.kaggle.com/discussion/8"
+	w.Write(other, filepath.Join(dir, "b.md"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q", lines)
+	}
+	if !strings.Contains(lines[0], `"custom":true`) || !strings.Contains(lines[1], `"title":"Validation, CV and LB"`) || !strings.Contains(lines[2], "discussion/8") {
+		t.Errorf("unexpected lines:\n%s", data)
+	}
+}
@@ -0,0 +1,594 @@
+package export
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// renderMarkdown converts the Markdown of a post to HTML. It covers what
+// Kaggle posts use: headings, paragraphs, lists, block quotes, fenced and
+// indented code, tables, rules, emphasis, code spans, links and images.
+// Raw HTML is escaped, and $...$ and $$...$$ math is kept verbatim for
+// MathJax or KaTeX.
+func renderMarkdown(md string) string {
+	md = strings.ReplaceAll(strings.ReplaceAll(md, "\r\n", "\n"), "\x00", "")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(md, "\n"), false)
+	return b.String()
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRe     = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRe    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	bulletRe   = regexp.MustCompile(`^( {0,3})([-*+])([ \t]+|$)`)
+	orderedRe  = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])([ \t]+|$)`)
+	tableSepRe = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// renderBlocks writes the blocks of lines. In tight lists paragraphs are
+// written without <p>.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+		case fenceRe.MatchString(line):
+			i = renderFence(b, lines, i)
+		case strings.HasPrefix(trimmed, "$$"):
+			i = renderMathBlock(b, lines, i)
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+		case ruleRe.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			i = renderQuote(b, lines, i)
+		case isListItem(line):
+			i = renderList(b, lines, i)
+		case isTableStart(lines, i):
+			i = renderTable(b, lines, i)
+		case indentOf(line) >= 4:
+			i = renderIndentedCode(b, lines, i)
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	m := fenceRe.FindStringSubmatch(lines[i])
+	marker, lang := m[1], m[2]
+	var code []string
+	j := i + 1
+	for ; j < len(lines); j++ {
+		t := strings.TrimSpace(lines[j])
+		if strings.HasPrefix(t, marker) && strings.Trim(t, marker[:1]) == "" {
+			j++
+			break
+		}
+		code = append(code, lines[j])
+	}
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	b.WriteString(">" + html.EscapeString(strings.Join(code, "\n")))
+	if len(code) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return j
+}
+
+func renderMathBlock(b *strings.Builder, lines []string, i int) int {
+	first := strings.TrimSpace(lines[i])
+	j := i + 1
+	if len(first) < 4 || !strings.HasSuffix(first, "$$") {
+		for j < len(lines) && !strings.Contains(lines[j], "$$") {
+			j++
+		}
+		if j < len(lines) {
+			j++
+		}
+	}
+	b.WriteString(`<div class="math">` + html.EscapeString(strings.Join(lines[i:j], "\n")) + "</div>\n")
+	return j
+}
+
+func renderQuote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		t := strings.TrimLeft(lines[i], " ")
+		if !strings.HasPrefix(t, ">") {
+			break
+		}
+		t = strings.TrimPrefix(t, ">")
+		inner = append(inner, strings.TrimPrefix(t, " "))
+	}
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, inner, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// listMarker parses a list item marker, returning the column where the
+// item's content starts and the content of the line.
+func listMarker(line string) (ordered bool, start, content int, rest string, ok bool) {
+	if m := bulletRe.FindStringSubmatch(line); m != nil && !ruleRe.MatchString(line) {
+		content, rest = itemContent(line, len(m[1])+1, m[3])
+		return false, 0, content, rest, true
+	}
+	if m := orderedRe.FindStringSubmatch(line); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		content, rest = itemContent(line, len(m[1])+len(m[2])+1, m[4])
+		return true, n, content, rest, true
+	}
+	return false, 0, 0, "", false
+}
+
+// itemContent returns the column where an item's content starts, after a
+// marker ending at byte end and the space after it, and the content. More
+// than four spaces start indented code, so only one of them counts.
+func itemContent(line string, end int, space string) (int, string) {
+	if space == "" || len(space) > 4 {
+		return end + 1, strings.TrimPrefix(line[end:], " ")
+	}
+	return end + len(space), line[end+len(space):]
+}
+
+func isListItem(line string) bool {
+	_, _, _, _, ok := listMarker(line)
+	return ok
+}
+
+func renderList(b *strings.Builder, lines []string, i int) int {
+	ordered, start, _, _, _ := listMarker(lines[i])
+	var items [][]string
+	tight := true
+	for i < len(lines) {
+		o, _, content, first, ok := listMarker(lines[i])
+		if !ok || o != ordered {
+			break
+		}
+		item := []string{first}
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			switch {
+			case strings.TrimSpace(line) == "":
+				item = append(item, "")
+				i++
+				continue
+			case indentOf(line) >= content:
+				item = append(item, stripIndent(line, content))
+				i++
+				continue
+			case item[len(item)-1] != "" && !isListItem(line) && !startsBlock(line):
+				// Lazy continuation of the item's paragraph.
+				item = append(item, strings.TrimSpace(line))
+				i++
+				continue
+			}
+			break
+		}
+		for len(item) > 0 && item[len(item)-1] == "" {
+			item = item[:len(item)-1]
+			if i < len(lines) && isListItem(lines[i]) {
+				tight = false
+			}
+		}
+		for _, l := range item {
+			if l == "" {
+				tight = false
+			}
+		}
+		items = append(items, item)
+		if i < len(lines) && strings.TrimSpace(lines[i-1]) == "" && !isListItem(lines[i]) {
+			break
+		}
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered && start != 1 {
+		b.WriteString(` start="` + strconv.Itoa(start) + `"`)
+	}
+	b.WriteString(">\n")
+	for _, item := range items {
+		b.WriteString("<li>")
+		var inner strings.Builder
+		renderBlocks(&inner, item, tight)
+		b.WriteString(strings.TrimSuffix(inner.String(), "\n"))
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// startsBlock reports whether line opens a block that ends a paragraph.
+func startsBlock(line string) bool {
+	t := strings.TrimSpace(line)
+	return fenceRe.MatchString(line) || headingRe.MatchString(line) || ruleRe.MatchString(line) ||
+		strings.HasPrefix(t, ">") || strings.HasPrefix(t, "$$") || bulletRe.MatchString(line)
+}
+
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") {
+		return false
+	}
+	sep := lines[i+1]
+	return tableSepRe.MatchString(sep) && strings.Contains(sep, "-") &&
+		(strings.Contains(sep, "|") || strings.HasPrefix(strings.TrimSpace(lines[i]), "|"))
+}
+
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	header := tableCells(lines[i])
+	var align []string
+	for _, c := range tableCells(lines[i+1]) {
+		left, right := strings.HasPrefix(c, ":"), strings.HasSuffix(c, ":")
+		switch {
+		case left && right:
+			align = append(align, "center")
+		case right:
+			align = append(align, "right")
+		case left:
+			align = append(align, "left")
+		default:
+			align = append(align, "")
+		}
+	}
+	row := func(tag string, cells []string) {
+		b.WriteString("<tr>")
+		for j := range header {
+			cell := ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			b.WriteString("<" + tag)
+			if j < len(align) && align[j] != "" {
+				b.WriteString(` style="text-align:` + align[j] + `"`)
+			}
+			b.WriteString(">" + renderInline(cell) + "</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	row("th", header)
+	b.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		row("td", tableCells(lines[i]))
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// tableCells splits a table row at pipes that are not escaped.
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func renderIndentedCode(b *strings.Builder, lines []string, i int) int {
+	var code []string
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" && indentOf(lines[i]) < 4 {
+			break
+		}
+		code = append(code, stripIndent(lines[i], 4))
+	}
+	for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+		code = code[:len(code)-1]
+	}
+	b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "\n</code></pre>\n")
+	return i
+}
+
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var parts []string
+	start := i
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || (i > start && (startsBlock(line) || isTableStart(lines, i))) {
+			break
+		}
+		text := strings.TrimSpace(line)
+		if strings.HasSuffix(line, "  ") || strings.HasSuffix(text, `\`) {
+			text = strings.TrimSuffix(text, `\`) + "\x01"
+		}
+		parts = append(parts, text)
+	}
+	text := strings.TrimSuffix(strings.Join(parts, "\n"), "\x01")
+	out := strings.ReplaceAll(renderInline(text), "\x01", "<br>")
+	if tight {
+		b.WriteString(out + "\n")
+		return i
+	}
+	b.WriteString("<p>" + out + "</p>\n")
+	return i
+}
+
+// indentOf returns the indentation width of line, counting tabs as 4.
+func indentOf(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4 - n%4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// stripIndent removes up to n columns of indentation.
+func stripIndent(line string, n int) string {
+	col := 0
+	for i, c := range line {
+		if col >= n || (c != ' ' && c != '\t') {
+			return line[i:]
+		}
+		if c == '\t' {
+			col += 4 - col%4
+		} else {
+			col++
+		}
+	}
+	return ""
+}
+
+var (
+	strongRe      = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	strongUnderRe = regexp.MustCompile(`(^|\W)__(\S(?:.*?\S)?)__(\W|$)`)
+	emRe          = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	emUnderRe     = regexp.MustCompile(`(^|\W)_(\S(?:.*?\S)?)_(\W|$)`)
+	delRe         = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	heldRe        = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// renderInline converts the inline Markdown of one block. Code spans, math,
+// links and escapes are taken out first so that emphasis is not applied
+// inside them.
+func renderInline(s string) string {
+	var held []string
+	hold := func(h string) string {
+		held = append(held, h)
+		return "\x00" + strconv.Itoa(len(held)-1) + "\x00"
+	}
+
+	var text strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~$<>\"'", s[i+1]) >= 0:
+			text.WriteString(hold(html.EscapeString(s[i+1 : i+2])))
+			i += 2
+			continue
+		case c == '`':
+			n := 1
+			for i+n < len(s) && s[i+n] == '`' {
+				n++
+			}
+			if end := closingRun(s, i+n, s[i:i+n]); end >= 0 {
+				code := s[i+n : end]
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				text.WriteString(hold("<code>" + html.EscapeString(code) + "</code>"))
+				i = end + n
+				continue
+			}
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+		case c == '$':
+			delim := "$"
+			if strings.HasPrefix(s[i:], "$$") {
+				delim = "$$"
+			}
+			if end := strings.Index(s[i+len(delim):], delim); end > 0 {
+				end += i + len(delim)
+				if inner := s[i+len(delim) : end]; delim == "$$" || (inner[0] != ' ' && inner[len(inner)-1] != ' ') {
+					text.WriteString(hold(html.EscapeString(s[i : end+len(delim)])))
+					i = end + len(delim)
+					continue
+				}
+			}
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if label, dest, end, ok := parseLink(s, i+1); ok {
+				img := `<img src="` + html.EscapeString(safeURL(dest)) + `" alt="` + html.EscapeString(label) + `">`
+				text.WriteString(hold(img))
+				i = end
+				continue
+			}
+		case c == '[':
+			if label, dest, end, ok := parseLink(s, i); ok {
+				inner := renderInline(label)
+				if u := safeURL(dest); u != "" {
+					inner = `<a href="` + html.EscapeString(u) + `">` + inner + "</a>"
+				}
+				text.WriteString(hold(inner))
+				i = end
+				continue
+			}
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				if u := s[i+1 : i+end]; isAutolink(u) {
+					text.WriteString(hold(`<a href="` + html.EscapeString(u) + `">` + html.EscapeString(u) + "</a>"))
+					i += end + 1
+					continue
+				}
+			}
+		case c == 'h' && (i == 0 || !isWordByte(s[i-1])) && (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")):
+			u := bareURL(s[i:])
+			text.WriteString(hold(`<a href="` + html.EscapeString(u) + `">` + html.EscapeString(u) + "</a>"))
+			i += len(u)
+			continue
+		}
+		text.WriteByte(c)
+		i++
+	}
+
+	out := html.EscapeString(text.String())
+	out = strongRe.ReplaceAllString(out, "<strong>$1</strong>")
+	out = strongUnderRe.ReplaceAllString(out, "$1<strong>$2</strong>$3")
+	out = emRe.ReplaceAllString(out, "<em>$1</em>")
+	out = emUnderRe.ReplaceAllString(out, "$1<em>$2</em>$3")
+	out = delRe.ReplaceAllString(out, "<del>$1</del>")
+	for strings.Contains(out, "\x00") {
+		out = heldRe.ReplaceAllStringFunc(out, func(m string) string {
+			n, _ := strconv.Atoi(m[1 : len(m)-1])
+			return held[n]
+		})
+	}
+	return out
+}
+
+// closingRun returns the index of the next occurrence of run in s from
+// start that is not part of a longer run of the same character, or -1.
+func closingRun(s string, start int, run string) int {
+	for i := start; i < len(s); {
+		j := strings.Index(s[i:], run)
+		if j < 0 {
+			return -1
+		}
+		j += i
+		end := j + len(run)
+		if end < len(s) && s[end] == run[0] {
+			for end < len(s) && s[end] == run[0] {
+				end++
+			}
+			i = end
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// parseLink parses "[label](dest)" or "[label](dest "title")" starting at
+// the "[" at i, returning the index after it.
+func parseLink(s string, i int) (label, dest string, end int, ok bool) {
+	depth := 0
+	j := i
+	for ; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if j >= len(s)-1 || s[j+1] != '(' {
+		return "", "", 0, false
+	}
+	label = s[i+1 : j]
+	depth = 0
+	k := j + 1
+	for ; k < len(s); k++ {
+		switch s[k] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if k >= len(s) {
+		return "", "", 0, false
+	}
+	dest = strings.TrimSpace(s[j+2 : k])
+	if strings.HasPrefix(dest, "<") {
+		if e := strings.IndexByte(dest, '>'); e > 0 {
+			dest = dest[1:e]
+		}
+	} else if f := strings.Fields(dest); len(f) > 0 {
+		dest = f[0]
+	}
+	return label, dest, k + 1, true
+}
+
+// safeURL returns u unless its scheme could run script, as "javascript:"
+// can; those yield "".
+func safeURL(u string) string {
+	scheme, _, found := strings.Cut(u, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return u
+	}
+	switch strings.ToLower(strings.TrimSpace(scheme)) {
+	case "http", "https", "mailto":
+		return u
+	}
+	return ""
+}
+
+func isAutolink(u string) bool {
+	return (strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "mailto:")) &&
+		!strings.ContainsAny(u, " \t\n<")
+}
+
+// bareURL returns the URL at the start of s, without trailing punctuation
+// and unbalanced closing parentheses.
+func bareURL(s string) string {
+	end := strings.IndexAny(s, " \t\n<\x00")
+	if end < 0 {
+		end = len(s)
+	}
+	u := s[:end]
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"*_~", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' && strings.Count(u, ")") > strings.Count(u, "("):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+	return u
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
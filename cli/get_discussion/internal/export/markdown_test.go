@@ -0,0 +1,45 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"paragraph", "Hello **bold** and *em* and _em_ and snake_case_name", "<p>Hello <strong>bold</strong> and <em>em</em> and <em>em</em> and snake_case_name</p>\n"},
+		{"escape", "a < b & <script>alert(1)</script>", "<p>a &lt; b &amp; &lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"code span", "use `x*y*z` here", "<p>use <code>x*y*z</code> here</p>\n"},
+		{"math", "loss $a_i * b_i$ and $$x_1$$", "<p>loss $a_i * b_i$ and $$x_1$$</p>\n"},
+		{"link", "[the **docs**](https://kaggle.com/docs \"Docs\")", `<p><a href="https://kaggle.com/docs">the <strong>docs</strong></a></p>` + "\n"},
+		{"unsafe link", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"image", "![plot](https://x/p.png)", `<p><img src="https://x/p.png" alt="plot"></p>` + "\n"},
+		{"bare url", "see https://kaggle.com/c/x.", `<p>see <a href="https://kaggle.com/c/x">https://kaggle.com/c/x</a>.</p>` + "\n"},
+		{"fence", "```python\nif a < b:\n    pass\n```", "<pre><code class=\"language-python\">if a &lt; b:\n    pass\n</code></pre>\n"},
+		{"indented code", "    x = 1\n    y = 2", "<pre><code>x = 1\ny = 2\n</code></pre>\n"},
+		{"rule", "a\n\n---\n\nb", "<p>a</p>\n<hr>\n<p>b</p>\n"},
+		{"quote", "> quoted\n> text", "<blockquote>\n<p>quoted\ntext</p>\n</blockquote>\n"},
+		{"tight list", "- one\n- two\n  - nested", "<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul></li>\n</ul>\n"},
+		{"ordered list", "3. c\n4. d", "<ol start=\"3\">\n<li>c</li>\n<li>d</li>\n</ol>\n"},
+		{"loose list", "- one\n\n- two", "<ul>\n<li><p>one</p></li>\n<li><p>two</p></li>\n</ul>\n"},
+		{"table", "| a | b |\n|:--|--:|\n| 1 | 2 |", "<table>\n<thead>\n<tr><th style=\"text-align:left\">a</th><th style=\"text-align:right\">b</th></tr>\n</thead>\n<tbody>\n<tr><td style=\"text-align:left\">1</td><td style=\"text-align:right\">2</td></tr>\n</tbody>\n</table>\n"},
+		{"hard break", "line one  \nline two", "<p>line one<br>\nline two</p>\n"},
+		{"math block", "$$\n\\sum_i x_i\n$$", "<div class=\"math\">$$\n\\sum_i x_i\n$$</div>\n"},
+	}
+	for _, tt := range tests {
+		if got := renderMarkdown(tt.in); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownNeverPanics(t *testing.T) {
+	for _, in := range []string{"[", "[a](", "![", "`", "$", "$$", "**", "<", "|", "|\n|-", "1.", "-", "> ", "```", "\\", "[a]", "http://"} {
+		if out := renderMarkdown(in); strings.Contains(out, "\x00") {
+			t.Errorf("%q left a placeholder: %q", in, out)
+		}
+	}
+}
@@ -0,0 +1,559 @@
+package frontmatter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse splits doc into its decoded front matter and body. A document
+// without front matter yields an empty Map and the whole document.
+func Parse(doc string) (Map, string, error) {
+	yaml, body, ok := Split(doc)
+	if !ok {
+		return Map{}, doc, nil
+	}
+	m, err := Decode(yaml)
+	return m, body, err
+}
+
+// Decode parses a YAML mapping. Plain scalars are typed with the YAML 1.2
+// core schema: nil, bool, int, float64, plus time.Time for dates and
+// timestamps; sequences decode to []any and mappings to Map.
+func Decode(yaml string) (Map, error) {
+	p := &parser{lines: strings.Split(strings.ReplaceAll(yaml, "\r\n", "\n"), "\n")}
+	indent, text, ok := p.peek()
+	if !ok {
+		return Map{}, nil
+	}
+	if isSeqItem(text) {
+		return Map{}, p.errorf("front matter must be a mapping")
+	}
+	m, err := p.parseMap(indent)
+	if err != nil {
+		return m, err
+	}
+	if _, _, ok := p.peek(); ok {
+		return m, p.errorf("unexpected indentation")
+	}
+	return m, nil
+}
+
+type parser struct {
+	lines []string
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("frontmatter: line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// peek skips blank and comment lines and returns the indentation and text of
+// the next line.
+func (p *parser) peek() (int, string, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		text := strings.TrimLeft(line, " ")
+		if t := strings.TrimSpace(text); t == "" || t[0] == '#' {
+			continue
+		}
+		return len(line) - len(text), text, true
+	}
+	return 0, "", false
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+func (p *parser) parseNode(indent int) (any, error) {
+	_, text, _ := p.peek()
+	if isSeqItem(text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *parser) parseMap(indent int) (Map, error) {
+	var m Map
+	for {
+		ind, text, ok := p.peek()
+		if !ok || ind < indent {
+			return m, nil
+		}
+		if ind > indent || isSeqItem(text) {
+			return m, p.errorf("unexpected indentation")
+		}
+		if strings.HasPrefix(text, "\t") {
+			return m, p.errorf("tabs are not allowed in indentation")
+		}
+		key, rest, ok := splitKey(text)
+		if !ok {
+			return m, p.errorf("expected \"key: value\", got %q", text)
+		}
+		if _, dup := m.Get(key); dup {
+			return m, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		v, err := p.parseValue(indent, rest, true)
+		if err != nil {
+			return m, err
+		}
+		m.Set(key, v)
+	}
+}
+
+func (p *parser) parseSeq(indent int) ([]any, error) {
+	items := []any{}
+	for {
+		ind, text, ok := p.peek()
+		if !ok || ind < indent || (ind == indent && !isSeqItem(text)) {
+			return items, nil
+		}
+		if ind > indent {
+			return items, p.errorf("unexpected indentation")
+		}
+		rest := strings.TrimLeft(text[1:], " \t")
+		if rest != "" && rest[0] != '#' {
+			if _, _, isKey := splitKey(rest); isKey || isSeqItem(rest) {
+				// A collection starting on the dash line: re-read the line
+				// with the dash replaced by indentation.
+				nested := indent + len(text) - len(rest)
+				p.lines[p.pos] = strings.Repeat(" ", nested) + rest
+				v, err := p.parseNode(nested)
+				if err != nil {
+					return items, err
+				}
+				items = append(items, v)
+				continue
+			}
+		}
+		p.pos++
+		v, err := p.parseValue(indent, rest, false)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, v)
+	}
+}
+
+// parseValue parses what follows "key:" or "-" on a line whose indentation
+// is indent, reading further lines for nested collections, block scalars and
+// multi-line scalars. sameIndentSeq allows a mapping value to be a sequence
+// at the key's own indentation.
+func (p *parser) parseValue(indent int, rest string, sameIndentSeq bool) (any, error) {
+	rest = strings.TrimSpace(rest)
+	if rest == "" || rest[0] == '#' {
+		ind, text, ok := p.peek()
+		switch {
+		case ok && ind > indent:
+			return p.parseNode(ind)
+		case ok && ind == indent && sameIndentSeq && isSeqItem(text):
+			return p.parseSeq(ind)
+		}
+		return nil, nil
+	}
+	switch rest[0] {
+	case '|', '>':
+		if validBlockHeader(rest) {
+			return p.blockScalar(indent, rest)
+		}
+	case '"', '\'':
+		// Quoted scalars may continue on the following lines.
+		start, text := p.pos, rest
+		for {
+			v, n, err := parseQuoted(text)
+			if err == nil && isComment(text[n:]) {
+				return v, nil
+			}
+			if err == nil || p.pos >= len(p.lines) {
+				break
+			}
+			text += "\n" + p.lines[p.pos]
+			p.pos++
+		}
+		p.pos = start
+	case '[', '{':
+		f := &flow{s: rest}
+		if v, err := f.value(); err == nil && isComment(f.s[f.i:]) {
+			return v, nil
+		}
+	}
+	// Values that are not valid YAML, such as "[WIP] idea" or "'90s" written
+	// by older versions, are read as text.
+	text := stripComment(rest)
+	for {
+		ind, next, ok := p.peek()
+		if !ok || ind <= indent {
+			break
+		}
+		if _, _, isKey := splitKey(next); isKey {
+			return nil, p.errorf("unexpected indentation")
+		}
+		// A continuation line of a multi-line plain scalar.
+		text += " " + stripComment(next)
+		p.pos++
+	}
+	return resolve(text), nil
+}
+
+var blockHeaderRe = regexp.MustCompile(`^[|>](?:[-+]?[1-9]?|[1-9][-+])$`)
+
+func validBlockHeader(header string) bool {
+	return blockHeaderRe.MatchString(stripComment(header))
+}
+
+// blockScalar reads a literal (|) or folded (>) scalar.
+func (p *parser) blockScalar(indent int, header string) (any, error) {
+	style := header[0]
+	chomp, explicit := byte(0), 0
+	for _, c := range stripComment(header)[1:] {
+		if c == '-' || c == '+' {
+			chomp = byte(c)
+		} else {
+			explicit = int(c - '0')
+		}
+	}
+
+	contentIndent := 0
+	if explicit > 0 {
+		contentIndent = indent + explicit
+	}
+	var lines []string
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		text := strings.TrimLeft(line, " ")
+		ind := len(line) - len(text)
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if contentIndent == 0 {
+			if ind <= indent {
+				break
+			}
+			contentIndent = ind
+		}
+		if ind < contentIndent {
+			break
+		}
+		lines = append(lines, line[contentIndent:])
+	}
+
+	// Trailing blank lines belong to the chomping, not the content.
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	trailing := len(lines) - end
+	lines = lines[:end]
+
+	var out string
+	if style == '|' {
+		out = strings.Join(lines, "\n")
+	} else {
+		var b strings.Builder
+		for i, l := range lines {
+			if i > 0 {
+				prev := lines[i-1]
+				switch {
+				case l == "" || prev == "":
+					b.WriteString("\n")
+				case strings.HasPrefix(l, " ") || strings.HasPrefix(prev, " "):
+					b.WriteString("\n")
+				default:
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(l)
+		}
+		out = b.String()
+		// An empty line between two text lines stands for one newline.
+		out = strings.ReplaceAll(out, "\n\n", "\n")
+	}
+	if len(lines) == 0 {
+		out = ""
+	}
+	switch chomp {
+	case '-':
+	case '+':
+		if len(lines) > 0 {
+			out += "\n"
+		}
+		out += strings.Repeat("\n", trailing)
+	default:
+		if len(lines) > 0 {
+			out += "\n"
+		}
+	}
+	return out, nil
+}
+
+// splitKey splits "key: value" into the key and the value text.
+func splitKey(text string) (key, rest string, ok bool) {
+	if text == "" {
+		return "", "", false
+	}
+	switch text[0] {
+	case '"', '\'':
+		k, n, err := parseQuoted(text)
+		if err != nil {
+			return "", "", false
+		}
+		after := strings.TrimLeft(text[n:], " ")
+		if !strings.HasPrefix(after, ":") || !separatorEnd(after[1:]) {
+			return "", "", false
+		}
+		return k, after[1:], true
+	case '[', '{', '#', '-', '|', '>':
+		if text[0] == '-' && !isSeqItem(text) && text != "-" {
+			break
+		}
+		return "", "", false
+	}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t'):
+			return "", "", false
+		case text[i] == ':' && separatorEnd(text[i+1:]):
+			return strings.TrimRight(text[:i], " \t"), text[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// separatorEnd reports whether a ":" followed by s ends a mapping key.
+func separatorEnd(s string) bool {
+	return s == "" || s[0] == ' ' || s[0] == '\t'
+}
+
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+// stripComment removes a trailing " # comment" from a plain scalar.
+func stripComment(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseQuoted parses the single- or double-quoted scalar at the start of s
+// and returns it with the number of bytes consumed. Line breaks inside fold
+// as in YAML: one break becomes a space, n empty lines become n newlines.
+func parseQuoted(s string) (string, int, error) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); {
+		c := s[i]
+		switch {
+		case c == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i += 2
+		case c == q:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			// Trim the whitespace around the break and fold it.
+			out := strings.TrimRight(b.String(), " \t")
+			b.Reset()
+			b.WriteString(out)
+			i++
+			breaks := 0
+			for i < len(s) {
+				for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+					i++
+				}
+				if i < len(s) && s[i] == '\n' {
+					breaks++
+					i++
+					continue
+				}
+				break
+			}
+			if breaks == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(strings.Repeat("\n", breaks))
+			}
+		case c == '\\' && q == '"':
+			n, err := unescape(&b, s[i:])
+			if err != nil {
+				return "", 0, err
+			}
+			i += n
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted scalar")
+}
+
+var simpleEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+}
+
+// unescape writes the escape sequence at the start of s and returns its
+// length.
+func unescape(b *strings.Builder, s string) (int, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("unterminated escape")
+	}
+	if r, ok := simpleEscapes[s[1]]; ok {
+		b.WriteString(r)
+		return 2, nil
+	}
+	if s[1] == '\n' {
+		// An escaped line break joins the lines without a space.
+		i := 2
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		return i, nil
+	}
+	width := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[1]]
+	if width == 0 {
+		return 0, fmt.Errorf("invalid escape %q", s[:2])
+	}
+	if len(s) < 2+width {
+		return 0, fmt.Errorf("short escape %q", s)
+	}
+	n, err := strconv.ParseUint(s[2:2+width], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, fmt.Errorf("invalid escape %q", s[:2+width])
+	}
+	b.WriteRune(rune(n))
+	return 2 + width, nil
+}
+
+// flow parses a flow collection such as [a, b] or {a: 1}.
+type flow struct {
+	s string
+	i int
+}
+
+func (f *flow) space() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t' || f.s[f.i] == '\n') {
+		f.i++
+	}
+}
+
+func (f *flow) value() (any, error) {
+	f.space()
+	if f.i >= len(f.s) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.seq()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		v, n, err := parseQuoted(f.s[f.i:])
+		f.i += n
+		return v, err
+	}
+	return resolve(f.plain(false)), nil
+}
+
+// plain reads a plain scalar up to a flow indicator, or up to ": " for keys.
+func (f *flow) plain(key bool) string {
+	start := f.i
+	for ; f.i < len(f.s); f.i++ {
+		c := f.s[f.i]
+		if strings.IndexByte(",[]{}", c) >= 0 {
+			break
+		}
+		if key && c == ':' && (f.i+1 == len(f.s) || strings.IndexByte(" \t,[]{}", f.s[f.i+1]) >= 0) {
+			break
+		}
+	}
+	return strings.TrimSpace(f.s[start:f.i])
+}
+
+func (f *flow) seq() ([]any, error) {
+	f.i++
+	items := []any{}
+	for {
+		f.space()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return items, nil
+		}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		if err := f.next(']'); err != nil {
+			return nil, err
+		}
+		if f.s[f.i-1] == ']' {
+			return items, nil
+		}
+	}
+}
+
+func (f *flow) mapping() (Map, error) {
+	f.i++
+	var m Map
+	for {
+		f.space()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return m, nil
+		}
+		var key string
+		if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+			k, n, err := parseQuoted(f.s[f.i:])
+			if err != nil {
+				return m, err
+			}
+			key = k
+			f.i += n
+		} else {
+			key = f.plain(true)
+		}
+		f.space()
+		var v any
+		if f.i < len(f.s) && f.s[f.i] == ':' {
+			f.i++
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] != ',' && f.s[f.i] != '}' {
+				var err error
+				if v, err = f.value(); err != nil {
+					return m, err
+				}
+			}
+		}
+		if _, dup := m.Get(key); dup {
+			return m, fmt.Errorf("duplicate key %q", key)
+		}
+		m.Set(key, v)
+		if err := f.next('}'); err != nil {
+			return m, err
+		}
+		if f.s[f.i-1] == '}' {
+			return m, nil
+		}
+	}
+}
+
+// next consumes the "," or closing bracket after a flow entry.
+func (f *flow) next(end byte) error {
+	f.space()
+	if f.i < len(f.s) && (f.s[f.i] == ',' || f.s[f.i] == end) {
+		f.i++
+		return nil
+	}
+	return fmt.Errorf("expected ',' or %q in flow collection", end)
+}
@@ -0,0 +1,107 @@
+package frontmatter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeHandWritten(t *testing.T) {
+	yaml := `# team metadata
+title: 'It''s a "title"'   # comment
+votes: 0x10
+score: +1.5e2
+posted: 2024-03-01 12:30:00 +09:00
+tags:
+- cv
+- "leak"
+status: ~
+owner:
+  name: carol
+  teams: [a, 'b, c', {lead: yes}]
+comments:
+  - id: 1
+    votes: 3
+  - id: 2
+    votes: -1
+notes: |
+  first line
+    indented
+
+  last
+summary: >-
+  folded
+  text
+
+  kept
+continued: a plain
+  scalar over lines
+escaped: "tab\there \u00e9 \U0001F680 \x41"
+wrapped: "one
+  two
+
+  three"
+`
+	m, err := Decode(yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := func(id, votes int) Map {
+		var c Map
+		c.Set("id", id)
+		c.Set("votes", votes)
+		return c
+	}
+	var lead Map
+	lead.Set("lead", "yes")
+	var owner Map
+	owner.Set("name", "carol")
+	owner.Set("teams", []any{"a", "b, c", lead})
+	want := map[string]any{
+		"title":     `It's a "title"`,
+		"votes":     16,
+		"score":     150.0,
+		"posted":    time.Date(2024, 3, 1, 3, 30, 0, 0, time.UTC),
+		"tags":      []any{"cv", "leak"},
+		"status":    nil,
+		"owner":     owner,
+		"comments":  []any{comment(1, 3), comment(2, -1)},
+		"notes":     "first line\n  indented\n\nlast\n",
+		"summary":   "folded text\nkept",
+		"continued": "a plain scalar over lines",
+		"escaped":   "tab\there é 🚀 A",
+		"wrapped":   "one two\nthree",
+	}
+	if len(m.Keys()) != len(want) {
+		t.Fatalf("unexpected keys: %v", m.Keys())
+	}
+	for k, w := range want {
+		got, _ := m.Get(k)
+		if gt, ok := got.(time.Time); ok {
+			if !gt.Equal(w.(time.Time)) {
+				t.Errorf("%s: got %v", k, gt)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("%s: got %#v, want %#v", k, got, w)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, yaml := range []string{
+		"- a\n- b\n",
+		"a: 1\na: 2\n",
+		"a: 1\n  b: 2\n",
+		"just text\n",
+		"a:\n  b: 1\n - c\n",
+	} {
+		if _, err := Decode(yaml); err == nil {
+			t.Errorf("expected an error for %q", yaml)
+		} else if !strings.HasPrefix(err.Error(), "frontmatter: line ") {
+			t.Errorf("error without a line number: %v", err)
+		}
+	}
+}
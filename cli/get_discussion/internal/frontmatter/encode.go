@@ -0,0 +1,222 @@
+package frontmatter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encode renders m as block-style YAML, one key per line. Strings are
+// written plain when that reads back as the same string and double-quoted
+// otherwise. Values may be nil, strings, bools, integers, floats, times,
+// slices, Maps and map[string]any; other types are written with fmt.Sprint.
+func Encode(m Map) string {
+	var b strings.Builder
+	writeMap(&b, m, 0)
+	return b.String()
+}
+
+func writeMap(b *strings.Builder, m Map, indent int) {
+	for _, k := range m.keys {
+		b.WriteString(strings.Repeat(" ", indent))
+		b.WriteString(quote(k))
+		b.WriteString(":")
+		writeValue(b, normalize(m.values[k]), indent+2)
+	}
+}
+
+func writeSeq(b *strings.Builder, items []any, indent int) {
+	for _, item := range items {
+		item = normalize(item)
+		// Nested collections start on the dash line: "- key: value".
+		var nested strings.Builder
+		switch v := item.(type) {
+		case Map:
+			if v.Len() > 0 {
+				writeMap(&nested, v, indent+2)
+			}
+		case []any:
+			if len(v) > 0 {
+				writeSeq(&nested, v, indent+2)
+			}
+		}
+		if nested.Len() > 0 {
+			b.WriteString(strings.Repeat(" ", indent))
+			b.WriteString("- ")
+			b.WriteString(nested.String()[indent+2:])
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", indent))
+		b.WriteString("-")
+		writeValue(b, item, indent+2)
+	}
+}
+
+// writeValue writes the part of a line after "key:" or "-".
+func writeValue(b *strings.Builder, v any, indent int) {
+	switch val := v.(type) {
+	case Map:
+		if val.Len() == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeMap(b, val, indent)
+	case []any:
+		if len(val) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeSeq(b, val, indent)
+	default:
+		b.WriteString(" ")
+		b.WriteString(scalar(val))
+		b.WriteString("\n")
+	}
+}
+
+// normalize converts the Go values Encode accepts to the types Decode
+// returns: []any for slices and Map for maps.
+func normalize(v any) any {
+	switch val := v.(type) {
+	case nil, string, bool, int, float64, time.Time, Map, []any:
+		return v
+	case *Map:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case map[string]any:
+		var m Map
+		for _, k := range sortedKeys(val) {
+			m.Set(k, val[k])
+		}
+		return m
+	case []string:
+		items := make([]any, len(val))
+		for i, s := range val {
+			items[i] = s
+		}
+		return items
+	case []int:
+		items := make([]any, len(val))
+		for i, n := range val {
+			items[i] = n
+		}
+		return items
+	case int64:
+		return int(val)
+	case int32:
+		return int(val)
+	case float32:
+		return float64(val)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Uint())
+	case reflect.String:
+		return rv.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func scalar(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(val)
+	case int:
+		return strconv.Itoa(val)
+	case float64:
+		return formatFloat(val)
+	case time.Time:
+		return formatTime(val)
+	case string:
+		return quote(val)
+	}
+	return quote(fmt.Sprint(v))
+}
+
+// quote returns s as a plain scalar when that is unambiguous, and as a
+// double-quoted scalar otherwise.
+func quote(s string) string {
+	if plainSafe(s) {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			switch {
+			case r == ' ' || (unicode.IsPrint(r) && r != '\ufeff'):
+				b.WriteRune(r)
+			case r <= 0xffff:
+				fmt.Fprintf(&b, `\u%04x`, r)
+			default:
+				fmt.Fprintf(&b, `\U%08x`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// yaml11Bools are read as booleans by YAML 1.1 parsers, so they are quoted
+// even though they are strings in YAML 1.2.
+var yaml11Bools = map[string]bool{
+	"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true,
+}
+
+// plainSafe reports whether s reads back as the same string when written
+// without quotes, by this package and by common YAML parsers.
+func plainSafe(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return false
+	}
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) ||
+		strings.HasPrefix(s, "...") ||
+		strings.TrimSpace(s) != s ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	if yaml11Bools[strings.ToLower(s)] {
+		return false
+	}
+	if _, ok := resolve(s).(string); !ok {
+		return false
+	}
+	for _, r := range s {
+		if r != ' ' && (!unicode.IsPrint(r) || r == '\ufeff') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+// Package frontmatter reads and writes the YAML front matter of saved
+// discussions. It implements the subset of YAML the files need: block
+// mappings and sequences, flow collections, plain, single- and double-quoted
+// scalars, literal and folded block scalars, and the core schema types
+// (null, bool, int, float) plus timestamps. Anchors, aliases, tags and
+// multiple documents are not supported.
+//
+// Encode and Decode round-trip: decoding an encoded Map yields the same keys,
+// order and values, for any valid UTF-8 strings.
+package frontmatter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delimiter opens and closes a front matter block.
+const Delimiter = "---"
+
+// Map is a YAML mapping that keeps its key order.
+type Map struct {
+	keys   []string
+	values map[string]any
+}
+
+// Set adds key or replaces its value in place.
+func (m *Map) Set(key string, value any) {
+	if m.values == nil {
+		m.values = map[string]any{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of key.
+func (m Map) Get(key string) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Delete removes key.
+func (m *Map) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order.
+func (m Map) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Len returns the number of keys.
+func (m Map) Len() int {
+	return len(m.keys)
+}
+
+// String returns the value of key as text: scalars are formatted the way
+// they are written, lists are joined with ", ", null and missing keys are "".
+func (m Map) String(key string) string {
+	return Text(m.values[key])
+}
+
+// Text formats a decoded value as plain text.
+func Text(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return formatTime(val)
+	case []any:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = Text(item)
+		}
+		return strings.Join(parts, ", ")
+	case float64:
+		return formatFloat(val)
+	}
+	return fmt.Sprint(v)
+}
+
+// Split separates a document into its front matter and the rest. ok is false
+// when the document does not start with a front matter block.
+func Split(doc string) (yaml, body string, ok bool) {
+	doc = strings.TrimPrefix(doc, "\ufeff")
+	first, rest, found := strings.Cut(doc, "\n")
+	if !found || strings.TrimRight(first, " \t\r") != Delimiter {
+		return "", doc, false
+	}
+	var b strings.Builder
+	for rest != "" {
+		line, next, _ := strings.Cut(rest, "\n")
+		if strings.TrimRight(line, " \t\r") == Delimiter {
+			return b.String(), next, true
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		rest = next
+	}
+	return "", doc, false
+}
+
+// Join renders a Map as a front matter block followed by body.
+func Join(m Map, body string) string {
+	return Delimiter + "\n" + Encode(m) + Delimiter + "\n" + body
+}
+
+var (
+	intRe       = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	hexRe       = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	octRe       = regexp.MustCompile(`^0o[0-7]+$`)
+	floatRe     = regexp.MustCompile(`^[-+]?(?:\.[0-9]+|[0-9]+(?:\.[0-9]*)?)(?:[eE][-+]?[0-9]+)?$`)
+	dateRe      = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+	timestampRe = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}(?:[Tt]|[ \t]+)[0-9]{2}:[0-9]{2}:[0-9]{2}(?:\.[0-9]+)?(?:[ \t]*(?:Z|z|[-+][0-9]{2}:[0-9]{2}))?$`)
+)
+
+var timestampLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// resolve types a plain scalar with the YAML 1.2 core schema plus
+// timestamps. Anything else is a string.
+func resolve(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	switch c := s[0]; {
+	case c >= '0' && c <= '9', c == '-', c == '+', c == '.':
+	default:
+		return s
+	}
+	switch {
+	case intRe.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 0); err == nil {
+			return int(n)
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case hexRe.MatchString(s):
+		if n, err := strconv.ParseInt(s[2:], 16, 0); err == nil {
+			return int(n)
+		}
+	case octRe.MatchString(s):
+		if n, err := strconv.ParseInt(s[2:], 8, 0); err == nil {
+			return int(n)
+		}
+	case floatRe.MatchString(s):
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case dateRe.MatchString(s):
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			return t
+		}
+	case timestampRe.MatchString(s):
+		if t, ok := parseTimestamp(s); ok {
+			return t
+		}
+	}
+	return s
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	date, clock := s[:10], strings.TrimLeft(s[11:], " \t")
+	clock = strings.Replace(clock, " ", "", 1)
+	clock = strings.Replace(clock, "\t", "", 1)
+	clock = strings.Replace(clock, "z", "Z", 1)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, date+"T"+clock); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatTime writes midnight UTC as a date and everything else as RFC 3339.
+func formatTime(t time.Time) string {
+	if t.Location() == time.UTC && t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if intRe.MatchString(s) {
+		s += ".0"
+	}
+	return s
+}
+
+// sortedKeys returns the keys of a Go map in order, for encoding.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
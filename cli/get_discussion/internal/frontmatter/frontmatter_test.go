@@ -0,0 +1,117 @@
+package frontmatter
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var adversarialTitles = []string{
+	"", " ", "plain title", "- leading dash", "-", "--", "---", "...", "[WIP] idea", "[a, b]", "{b}", "{a: 1}",
+	"@user thanks", "*bold*", "&anchor", "!tag", "| pipe", "> quote", "%TAG", "`code`", "?", ":", "a:", "a: b",
+	"a:b", "a #b", "a#b", "#hash", "yes", "No", "ON", "off", "y", "n", "null", "Null", "~", "true", "False",
+	"007", "0x1F", "0o17", "1e3", "-1.5", ".5", ".inf", "-.inf", ".nan", "1_000", "2024-01-01",
+	"2024-01-01T10:00:00Z", "12:30", `"double"`, `'single'`, `it's`, `back\slash`, `\n`, "line\nbreak",
+	"trailing space ", " leading space", "tab\there", "cr\rhere", "nul\x00byte", "bell\a", "zero\u200bwidth",
+	"bom\ufeff", "line\u2028sep", "emoji 🚀", "日本語のタイトル", "Ünïcödé", "a\n\nb", "\n", "multi\n  indented",
+}
+
+func TestRoundTripAdversarialStrings(t *testing.T) {
+	for _, s := range adversarialTitles {
+		var m Map
+		m.Set("title", s)
+		m.Set(s, "key")
+		m.Set("list", []any{s, "x"})
+		got, err := Decode(Encode(m))
+		if err != nil {
+			t.Errorf("%q: %v\n%s", s, err, Encode(m))
+			continue
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("%q: round trip changed the map:\n%s\ngot %#v", s, Encode(m), got)
+		}
+	}
+}
+
+func TestRoundTripTypedValues(t *testing.T) {
+	var nested Map
+	nested.Set("id", 42)
+	nested.Set("votes", -3)
+	nested.Set("tags", []any{})
+	var m Map
+	m.Set("comments", 12)
+	m.Set("ratio", 0.25)
+	m.Set("whole", 2.0)
+	m.Set("big", math.Inf(1))
+	m.Set("ok", true)
+	m.Set("none", nil)
+	m.Set("date", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	m.Set("posted", time.Date(2024, 3, 1, 12, 30, 5, 500, time.UTC))
+	m.Set("tags", []string{"cv", "leak", "yes"})
+	m.Set("ids", []int{1, 2})
+	m.Set("comment", nested)
+	m.Set("comments_by_id", []any{nested, []any{1, []any{}}, Map{}})
+	m.Set("empty", map[string]any{})
+
+	got, err := Decode(Encode(m))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, Encode(m))
+	}
+	if !reflect.DeepEqual(got.Keys(), m.Keys()) {
+		t.Fatalf("key order changed: %v", got.Keys())
+	}
+	for _, k := range m.Keys() {
+		want, _ := m.Get(k)
+		v, _ := got.Get(k)
+		if !reflect.DeepEqual(v, normalize(want)) {
+			t.Errorf("%s: got %#v, want %#v\n%s", k, v, want, Encode(m))
+		}
+	}
+}
+
+func TestEncodeQuotesOnlyWhenNeeded(t *testing.T) {
+	var m Map
+	m.Set("title", "Plain title, with comma")
+	m.Set("link", "https://www.kaggle.com/discussions/1#c2")
+	m.Set("yes", "yes")
+	m.Set("code", "007")
+	m.Set("ids", []int{1, 2})
+	want := "title: Plain title, with comma\n" +
+		"link: https://www.kaggle.com/discussions/1#c2\n" +
+		"\"yes\": \"yes\"\n" +
+		"code: \"007\"\n" +
+		"ids:\n  - 1\n  - 2\n"
+	if got := Encode(m); got != want {
+		t.Fatalf("unexpected YAML:\n%s", got)
+	}
+}
+
+func TestSplitAndJoin(t *testing.T) {
+	var m Map
+	m.Set("title", "T")
+	doc := Join(m, "\nBody\n")
+	got, body, err := Parse(doc)
+	if err != nil || got.String("title") != "T" || body != "\nBody\n" {
+		t.Fatalf("unexpected parse: %v %q %v", got, body, err)
+	}
+	if _, body, ok := Split("no front matter\n"); ok || body != "no front matter\n" {
+		t.Fatalf("split should fail without front matter")
+	}
+	if _, _, ok := Split("---\ntitle: unterminated\n"); ok {
+		t.Fatalf("split should fail without a closing delimiter")
+	}
+}
+
+func TestText(t *testing.T) {
+	m, err := Decode("n: 5\nf: 1.0\nd: 2024-01-02\nl: [a, 2]\nz:\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{m.String("n"), m.String("f"), m.String("d"), m.String("l"), m.String("z"), m.String("missing")}
+	want := []string{"5", "1.0", "2024-01-02", "a, 2", "", ""}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+// Package fsutil writes files so that a crash never leaves them half-written.
+// It imports nothing else from this module, so every package can use it.
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// TempPrefix starts the name of the temporary files WriteFileAtomic renames
+// into place. Leftovers of interrupted runs are reported by storage.Verify.
+const TempPrefix = ".tmp-"
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it over path, so readers and crashes only ever see the old or the new
+// content. With sync set the file and its directory are flushed to disk
+// before and after the rename.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode, sync bool) (err error) {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, TempPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if sync {
+		if err = f.Sync(); err != nil {
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	if sync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// syncDir flushes a directory entry change. Platforms that cannot open
+// directories for syncing are ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !os.IsPermission(err) && !errors.Is(err, syscall.EINVAL) {
+		return err
+	}
+	return nil
+}
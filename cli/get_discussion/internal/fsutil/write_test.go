@@ -0,0 +1,34 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte("old"), 0o600)
+
+	for _, sync := range []bool{false, true} {
+		if err := WriteFileAtomic(path, []byte("new"), 0o644, sync); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "new" {
+		t.Fatalf("content = %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o644 {
+		t.Errorf("mode = %v", info.Mode())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "b.md"), []byte("x"), 0o644, false); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
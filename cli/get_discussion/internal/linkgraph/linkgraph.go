@@ -0,0 +1,311 @@
+package linkgraph
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// Kind classifies an outbound link.
+type Kind string
+
+const (
+	KindDiscussion  Kind = "discussion"
+	KindNotebook    Kind = "notebook"
+	KindDataset     Kind = "dataset"
+	KindCompetition Kind = "competition"
+	KindModel       Kind = "model"
+	KindUser        Kind = "user"
+	KindPaper       Kind = "paper"
+	KindCode        Kind = "code"
+	KindOther       Kind = "other"
+)
+
+// Ref is an outbound link in canonical form.
+type Ref struct {
+	Kind Kind   `json:"kind"`
+	URL  string `json:"url"`
+	Text string `json:"text,omitempty"`
+}
+
+// Document is a saved discussion with its outbound links and the saved
+// discussions linking to it.
+type Document struct {
+	Path      string   `json:"path"`
+	Title     string   `json:"title"`
+	Link      string   `json:"link"`
+	Outbound  []Ref    `json:"outbound"`
+	Backlinks []string `json:"backlinks"`
+}
+
+// Count is a link target with the saved discussions referencing it.
+type Count struct {
+	URL     string   `json:"url"`
+	Text    string   `json:"text,omitempty"`
+	Sources []string `json:"sources"`
+}
+
+// Index is the cross-reference graph of a directory of saved discussions.
+type Index struct {
+	Documents    []Document `json:"documents"`
+	TopNotebooks []Count    `json:"top_notebooks"`
+	TopDatasets  []Count    `json:"top_datasets"`
+	Papers       []Count    `json:"papers"`
+}
+
+var (
+	urlRe        = regexp.MustCompile(`https?://[^\s<>"'\)\]]+`)
+	mdLinkRe     = regexp.MustCompile(`\[([^\]\n]*)\]\((https?://[^)\s]+)`)
+	arxivRe      = regexp.MustCompile(`(?i)^/(?:abs|pdf|html)/((?:\d{4}\.\d{4,5})|(?:[a-z-]+(?:\.[A-Z]{2})?/\d{7}))(?:v\d+)?(?:\.pdf)?/?$`)
+	arxivIDRe    = regexp.MustCompile(`(?i)arxiv\.org/(?:abs|pdf|html)/(\d{4}\.\d{4,5}|[a-z-]+(?:\.[A-Z]{2})?/\d{7})`)
+	topicPathRe  = regexp.MustCompile(`^(.*/discussions?/(?:[a-z0-9-]+/)?\d+)(?:/|$)`)
+	reservedPath = map[string]bool{
+		"code": true, "kernels": true, "datasets": true, "competitions": true, "c": true,
+		"models": true, "discussions": true, "discussion": true, "learn": true, "search": true,
+		"static": true, "docs": true, "account": true, "settings": true, "api": true, "rankings": true,
+	}
+)
+
+// Classify returns the kind of rawURL and its canonical form, following the
+// Kaggle URL structure: discussions, notebooks (/code), datasets, models,
+// competitions and user profiles. arXiv links are normalised to their
+// abstract page and GitHub links to their repository.
+func Classify(rawURL string) (Kind, string) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return KindOther, rawURL
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	path := strings.TrimSuffix(u.Path, "/")
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	switch host {
+	case "kaggle.com":
+		if m := topicPathRe.FindStringSubmatch(path); m != nil {
+			return KindDiscussion, "https://www.kaggle.com" + m[1]
+		}
+		kaggle := func(n int) string {
+			return "https://www.kaggle.com/" + strings.Join(parts[:n], "/")
+		}
+		switch {
+		case (parts[0] == "code" || parts[0] == "kernels") && len(parts) >= 3:
+			return KindNotebook, "https://www.kaggle.com/code/" + parts[1] + "/" + parts[2]
+		case parts[0] == "datasets" && len(parts) >= 3:
+			return KindDataset, kaggle(3)
+		case parts[0] == "models" && len(parts) >= 3:
+			return KindModel, kaggle(3)
+		case (parts[0] == "competitions" || parts[0] == "c") && len(parts) >= 2:
+			return KindCompetition, "https://www.kaggle.com/competitions/" + parts[1]
+		case len(parts) == 1 && parts[0] != "" && !reservedPath[parts[0]]:
+			return KindUser, kaggle(1)
+		}
+	case "arxiv.org", "export.arxiv.org":
+		if m := arxivRe.FindStringSubmatch(path); m != nil {
+			return KindPaper, "https://arxiv.org/abs/" + m[1]
+		}
+	case "github.com":
+		if len(parts) >= 2 {
+			return KindCode, "https://github.com/" + parts[0] + "/" + strings.TrimSuffix(parts[1], ".git")
+		}
+	}
+	u.Fragment = ""
+	return KindOther, u.String()
+}
+
+// ExtractRefs returns the distinct outbound links of a Markdown body in order
+// of appearance, keeping the first non-URL link text seen for each.
+func ExtractRefs(md string) []Ref {
+	texts := map[string]string{}
+	for _, m := range mdLinkRe.FindAllStringSubmatch(md, -1) {
+		text := strings.TrimSpace(m[1])
+		if text == "" || strings.Contains(text, "://") || strings.HasPrefix(text, "!") {
+			continue
+		}
+		if _, canonical := Classify(m[2]); texts[canonical] == "" {
+			texts[canonical] = text
+		}
+	}
+
+	var refs []Ref
+	seen := map[string]bool{}
+	for _, raw := range urlRe.FindAllString(md, -1) {
+		kind, canonical := Classify(strings.TrimRight(raw, ".,;:!?"))
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		refs = append(refs, Ref{Kind: kind, URL: canonical, Text: texts[canonical]})
+	}
+	return refs
+}
+
+// Load reads every saved discussion under dir, recursively. Markdown files
+// without a link in their front matter, such as indexes, are skipped, and so
+// are files that cannot be read or parsed, with a warning.
+func Load(dir string) ([]Document, error) {
+	var docs []Document
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return err
+		}
+		meta, body, err := storage.ReadDiscussionFile(path)
+		if err != nil {
+			log.Printf("[warn] %v", err)
+			return nil
+		}
+		if meta.String("link") == "" {
+			return nil
+		}
+		docs = append(docs, Document{
+			Path:     path,
+			Title:    meta.String("title"),
+			Link:     urlutil.CanonicalizeURL(meta.String("link")),
+			Outbound: ExtractRefs(body),
+		})
+		return nil
+	})
+	return docs, err
+}
+
+// Build computes backlinks between documents and ranks the notebooks and
+// datasets they reference by the number of referencing documents. top limits
+// those rankings; 0 keeps everything.
+func Build(docs []Document, top int) Index {
+	byLink := map[string]int{}
+	for i := range docs {
+		_, canonical := Classify(docs[i].Link)
+		byLink[canonical] = i
+		docs[i].Backlinks = []string{}
+	}
+
+	counts := map[Kind]map[string]*Count{KindNotebook: {}, KindDataset: {}, KindPaper: {}}
+	for i, d := range docs {
+		_, self := Classify(d.Link)
+		for _, r := range d.Outbound {
+			if r.URL == self {
+				continue
+			}
+			if j, ok := byLink[r.URL]; ok && r.Kind == KindDiscussion {
+				docs[j].Backlinks = append(docs[j].Backlinks, docs[i].Link)
+			}
+			if m, ok := counts[r.Kind]; ok {
+				c := m[r.URL]
+				if c == nil {
+					c = &Count{URL: r.URL}
+					m[r.URL] = c
+				}
+				if c.Text == "" {
+					c.Text = r.Text
+				}
+				c.Sources = append(c.Sources, d.Link)
+			}
+		}
+	}
+
+	return Index{
+		Documents:    docs,
+		TopNotebooks: ranked(counts[KindNotebook], top),
+		TopDatasets:  ranked(counts[KindDataset], top),
+		Papers:       ranked(counts[KindPaper], 0),
+	}
+}
+
+func ranked(m map[string]*Count, top int) []Count {
+	out := make([]Count, 0, len(m))
+	for _, c := range m {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].Sources) != len(out[j].Sources) {
+			return len(out[i].Sources) > len(out[j].Sources)
+		}
+		return out[i].URL < out[j].URL
+	})
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
+
+// Markdown renders the index. Document paths are written relative to dir.
+func (ix Index) Markdown(dir string) string {
+	titles := map[string]string{}
+	paths := map[string]string{}
+	for _, d := range ix.Documents {
+		titles[d.Link] = d.Title
+		paths[d.Link] = relPath(dir, d.Path)
+	}
+	docLink := func(link string) string {
+		title := urlutil.FirstNonEmpty(titles[link], link)
+		if p, ok := paths[link]; ok {
+			return fmt.Sprintf("[%s](%s)", cell(title), p)
+		}
+		return fmt.Sprintf("[%s](%s)", cell(title), link)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Links index\n")
+	for _, section := range []struct {
+		name   string
+		counts []Count
+	}{
+		{"Most referenced notebooks", ix.TopNotebooks},
+		{"Most referenced datasets", ix.TopDatasets},
+		{"Papers", ix.Papers},
+	} {
+		fmt.Fprintf(&b, "\n## %s\n\n", section.name)
+		if len(section.counts) == 0 {
+			b.WriteString("None.\n")
+			continue
+		}
+		b.WriteString("| Link | References | Referenced by |\n")
+		b.WriteString("| ---- | ---------- | ------------- |\n")
+		for _, c := range section.counts {
+			sources := make([]string, len(c.Sources))
+			for i, s := range c.Sources {
+				sources[i] = docLink(s)
+			}
+			fmt.Fprintf(&b, "| [%s](%s) | %d | %s |\n",
+				cell(urlutil.FirstNonEmpty(c.Text, c.URL)), c.URL, len(c.Sources), strings.Join(sources, ", "))
+		}
+	}
+
+	b.WriteString("\n## Discussions\n\n")
+	b.WriteString("| Discussion | Outbound | Backlinks |\n")
+	b.WriteString("| ---------- | -------- | --------- |\n")
+	docs := append([]Document(nil), ix.Documents...)
+	sort.SliceStable(docs, func(i, j int) bool {
+		if len(docs[i].Backlinks) != len(docs[j].Backlinks) {
+			return len(docs[i].Backlinks) > len(docs[j].Backlinks)
+		}
+		return docs[i].Path < docs[j].Path
+	})
+	for _, d := range docs {
+		backlinks := make([]string, len(d.Backlinks))
+		for i, l := range d.Backlinks {
+			backlinks[i] = docLink(l)
+		}
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", docLink(d.Link), len(d.Outbound), strings.Join(backlinks, ", "))
+	}
+	return b.String()
+}
+
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func cell(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "|", `\|`)
+}
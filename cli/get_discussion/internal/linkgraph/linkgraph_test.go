@@ -0,0 +1,101 @@
+package linkgraph
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		in   string
+		kind Kind
+		want string
+	}{
+		{"https://www.kaggle.com/competitions/titanic/discussion/123?sort=votes#456", KindDiscussion, "https://www.kaggle.com/competitions/titanic/discussion/123"},
+		{"https://www.kaggle.com/discussions/general/99", KindDiscussion, "https://www.kaggle.com/discussions/general/99"},
+		{"https://kaggle.com/code/alice/eda/notebook", KindNotebook, "https://www.kaggle.com/code/alice/eda"},
+		{"https://www.kaggle.com/kernels/alice/eda", KindNotebook, "https://www.kaggle.com/code/alice/eda"},
+		{"https://www.kaggle.com/datasets/bob/train/data", KindDataset, "https://www.kaggle.com/datasets/bob/train"},
+		{"https://www.kaggle.com/c/titanic/leaderboard", KindCompetition, "https://www.kaggle.com/competitions/titanic"},
+		{"https://www.kaggle.com/models/google/gemma", KindModel, "https://www.kaggle.com/models/google/gemma"},
+		{"https://www.kaggle.com/alice", KindUser, "https://www.kaggle.com/alice"},
+		{"https://arxiv.org/pdf/1706.03762v5.pdf", KindPaper, "https://arxiv.org/abs/1706.03762"},
+		{"https://github.com/dmlc/xgboost.git", KindCode, "https://github.com/dmlc/xgboost"},
+		{"https://example.com/a#b", KindOther, "https://example.com/a"},
+	}
+	for _, c := range cases {
+		kind, got := Classify(c.in)
+		if kind != c.kind || got != c.want {
+			t.Errorf("Classify(%q) = %s %s, want %s %s", c.in, kind, got, c.kind, c.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "---\ntitle: A\nlink: https://www.kaggle.com/competitions/x/discussion/1\n---\n\n"+
+		"See [the EDA](https://www.kaggle.com/code/alice/eda) and https://www.kaggle.com/competitions/x/discussion/2.\n"+
+		"Paper: [Attention Is All You Need](https://arxiv.org/abs/1706.03762).")
+	write("users/bob/b.md", "---\ntitle: B\nlink: https://www.kaggle.com/competitions/x/discussion/2\n---\n\n"+
+		"https://www.kaggle.com/code/alice/eda/notebook, https://www.kaggle.com/datasets/bob/extra and back to https://www.kaggle.com/competitions/x/discussion/1#5")
+	write("index.md", "# not a discussion\nhttps://www.kaggle.com/code/z/z")
+	write("broken.md", "---\ntitle: C\ntitle: C again\nlink: https://www.kaggle.com/competitions/x/discussion/3\n---\n\nbody")
+
+	docs, err := Load(dir)
+	if err != nil || len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d: %v", len(docs), err)
+	}
+	ix := Build(docs, 10)
+
+	if len(ix.TopNotebooks) != 1 || len(ix.TopNotebooks[0].Sources) != 2 || ix.TopNotebooks[0].Text != "the EDA" {
+		t.Fatalf("unexpected notebooks: %+v", ix.TopNotebooks)
+	}
+	if len(ix.TopDatasets) != 1 || len(ix.Papers) != 1 {
+		t.Fatalf("unexpected datasets/papers: %+v %+v", ix.TopDatasets, ix.Papers)
+	}
+	for _, d := range ix.Documents {
+		if len(d.Backlinks) != 1 {
+			t.Fatalf("expected one backlink for %s, got %v", d.Title, d.Backlinks)
+		}
+	}
+
+	md := ix.Markdown(dir)
+	if !strings.Contains(md, "| [the EDA](https://www.kaggle.com/code/alice/eda) | 2 | [A](a.md), [B](users/bob/b.md) |") {
+		t.Fatalf("unexpected markdown:\n%s", md)
+	}
+}
+
+func TestAppendPapers(t *testing.T) {
+	doc := "## Paper Table\n\n| Status | Name | Detail | Date | URL |\n| --- | --- | --- | --- | --- |\n" +
+		"| Done | Transformer | detail | 2017 | [Link](https://arxiv.org/abs/1706.03762v2) |\n\nNotes below.\n"
+	papers := []Count{
+		{URL: "https://arxiv.org/abs/1706.03762", Sources: []string{"a"}},
+		{URL: "https://arxiv.org/abs/2106.11959", Text: "Revisiting | Tabular", Sources: []string{"a", "b"}},
+	}
+	out, added := AppendPapers(doc, papers)
+	if len(added) != 1 {
+		t.Fatalf("expected one new paper, got %+v", added)
+	}
+	want := "| Done | Transformer | detail | 2017 | [Link](https://arxiv.org/abs/1706.03762v2) |\n" +
+		"| Todo | Revisiting \\| Tabular | Referenced by 2 discussion(s) | 2021 | [Link](https://arxiv.org/abs/2106.11959) |\n\nNotes below.\n"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("unexpected document:\n%s", out)
+	}
+	if again, added := AppendPapers(out, papers); again != out || added != nil {
+		t.Fatalf("second append should be a no-op")
+	}
+
+	fresh, _ := AppendPapers("", papers[:1])
+	if !strings.HasPrefix(fresh, "## Paper Table\n\n| Status |") || !strings.Contains(fresh, "| Todo | arXiv:1706.03762 |") {
+		t.Fatalf("unexpected new table:\n%s", fresh)
+	}
+}
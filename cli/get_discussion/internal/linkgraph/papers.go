@@ -0,0 +1,84 @@
+package linkgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// paperRow follows the columns of the docs/Paper.md table:
+// Status | Name | Detail | Date | URL.
+const paperRow = "| Todo | %s | %s | %s | [Link](%s) |"
+
+// ArxivID returns the arXiv identifier of a link, or "" when it is not one.
+func ArxivID(link string) string {
+	if m := arxivIDRe.FindStringSubmatch(link); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// AppendPapers adds a Todo row to the paper table of doc for every arXiv
+// paper not already listed there, identified by arXiv ID. Rows go after the
+// last table row; a table is created when the document has none. It returns
+// the updated document and the papers added.
+func AppendPapers(doc string, papers []Count) (string, []Count) {
+	known := map[string]bool{}
+	for _, m := range arxivIDRe.FindAllStringSubmatch(doc, -1) {
+		known[strings.ToLower(m[1])] = true
+	}
+
+	var rows []string
+	var added []Count
+	for _, p := range papers {
+		id := ArxivID(p.URL)
+		if id == "" || known[id] {
+			continue
+		}
+		known[id] = true
+		name := urlutil.FirstNonEmpty(p.Text, "arXiv:"+id)
+		detail := fmt.Sprintf("Referenced by %d discussion(s)", len(p.Sources))
+		rows = append(rows, fmt.Sprintf(paperRow, cell(name), detail, arxivYear(id), p.URL))
+		added = append(added, p)
+	}
+	if len(rows) == 0 {
+		return doc, nil
+	}
+
+	lines := strings.Split(strings.TrimRight(doc, "\n"), "\n")
+	last := -1
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "|") {
+			last = i
+		}
+	}
+	if last < 0 {
+		header := []string{"## Paper Table", "", "| Status | Name | Detail | Date | URL |", "| ------ | ---- | ------ | ---- | --- |"}
+		if len(lines) == 1 && lines[0] == "" {
+			lines = nil
+		} else {
+			lines = append(lines, "")
+		}
+		lines = append(append(lines, header...), rows...)
+	} else {
+		lines = append(lines[:last+1], append(rows, lines[last+1:]...)...)
+	}
+	return strings.Join(lines, "\n") + "\n", added
+}
+
+// arxivYear derives the submission year from a YYMM.NNNNN or archive/YYMMNNN ID.
+func arxivYear(id string) string {
+	digits := id
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		digits = id[i+1:]
+	}
+	if len(digits) < 2 {
+		return ""
+	}
+	yy := digits[:2]
+	if yy >= "91" {
+		return "19" + yy
+	}
+	return "20" + yy
+}
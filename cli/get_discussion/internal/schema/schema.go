@@ -0,0 +1,297 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/fsutil"
+)
+
+// Drift kinds reported by Check.
+const (
+	UnknownField = "unknown_field"
+	MissingField = "missing_field"
+	TypeChanged  = "type_changed"
+)
+
+// Drift is a difference between a JSON payload and the Go type it decodes into.
+type Drift struct {
+	Endpoint string `json:"endpoint"`
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Count    int    `json:"count"`
+}
+
+// Check compares data against the type of dest and returns every unknown
+// field, missing field and type mismatch. Array elements share the path
+// "field[]" so a change is reported once per endpoint.
+//
+// A `schema` struct tag relaxes the missing check: `schema:"optional"` never
+// reports the field, and `schema:"alt=url|link"` only reports it when none of
+// the listed JSON fields is present either, for payloads that carry the same
+// value under different names.
+//
+// Types with their own UnmarshalJSON decode whatever forms they accept, so
+// their values are not inspected.
+func Check(data []byte, dest any) ([]Drift, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var out []Drift
+	walk(v, reflect.TypeOf(dest), "", &out)
+	return out, nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func walk(v any, t reflect.Type, path string, out *[]Drift) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || v == nil || t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			for name, f := range fields {
+				child, ok := val[name]
+				if !ok {
+					if !mayBeMissing(f, val) {
+						*out = append(*out, Drift{Path: join(path, name), Kind: MissingField, Expected: typeName(f.Type)})
+					}
+					continue
+				}
+				walk(child, f.Type, join(path, name), out)
+			}
+			for name := range val {
+				if _, ok := fields[name]; !ok {
+					*out = append(*out, Drift{Path: join(path, name), Kind: UnknownField, Actual: jsonKind(val[name])})
+				}
+			}
+		case reflect.Map:
+			for k, child := range val {
+				walk(child, t.Elem(), join(path, k), out)
+			}
+		default:
+			mismatch(t, v, path, out)
+		}
+	case []any:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			mismatch(t, v, path, out)
+			return
+		}
+		for _, child := range val {
+			walk(child, t.Elem(), path+"[]", out)
+		}
+	case string:
+		if t.Kind() != reflect.String {
+			mismatch(t, v, path, out)
+		}
+	case bool:
+		if t.Kind() != reflect.Bool {
+			mismatch(t, v, path, out)
+		}
+	case json.Number:
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64:
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if _, err := val.Int64(); err != nil {
+				mismatch(t, v, path, out)
+			}
+		default:
+			mismatch(t, v, path, out)
+		}
+	}
+}
+
+// mayBeMissing reports whether the `schema` tag of f allows it to be absent
+// from obj.
+func mayBeMissing(f reflect.StructField, obj map[string]any) bool {
+	tag := f.Tag.Get("schema")
+	if tag == "optional" {
+		return true
+	}
+	alts, ok := strings.CutPrefix(tag, "alt=")
+	if !ok {
+		return false
+	}
+	for _, alt := range strings.Split(alts, "|") {
+		if _, present := obj[alt]; present {
+			return true
+		}
+	}
+	return false
+}
+
+func mismatch(t reflect.Type, v any, path string, out *[]Drift) {
+	*out = append(*out, Drift{Path: path, Kind: TypeChanged, Expected: typeName(t), Actual: jsonKind(v)})
+}
+
+// jsonFields maps JSON names to struct fields, honouring `json` tags.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Interface:
+		return "any"
+	}
+	return "integer"
+}
+
+func jsonKind(v any) string {
+	switch val := v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+	return "null"
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Recorder accumulates drift across requests. It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	drifts map[string]*Drift
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{drifts: map[string]*Drift{}}
+}
+
+// Check records the drift between data and dest under endpoint. Decode errors
+// are recorded as a type change at the payload root.
+func (r *Recorder) Check(endpoint string, data []byte, dest any) {
+	drifts, err := Check(data, dest)
+	if err != nil {
+		drifts = []Drift{{Kind: TypeChanged, Expected: typeName(reflect.TypeOf(dest)), Actual: err.Error()}}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range drifts {
+		d.Endpoint = endpoint
+		key := d.Endpoint + "\x00" + d.Path + "\x00" + d.Kind
+		if existing, ok := r.drifts[key]; ok {
+			existing.Count++
+			continue
+		}
+		d.Count = 1
+		r.drifts[key] = &d
+	}
+}
+
+// Report returns the recorded drift sorted by endpoint, path and kind.
+func (r *Recorder) Report() []Drift {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Drift, 0, len(r.drifts))
+	for _, d := range r.drifts {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Endpoint != out[j].Endpoint {
+			return out[i].Endpoint < out[j].Endpoint
+		}
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Kind < out[j].Kind
+	})
+	return out
+}
+
+// WriteReport writes the drift report as indented JSON to path, flushing it
+// to disk with fsync set.
+func (r *Recorder) WriteReport(path string, fsync bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, append(data, '\n'), 0o644, fsync)
+}
+
+// Summary returns a one-line count per drift kind, e.g. "2 unknown_field, 1 type_changed".
+func Summary(drifts []Drift) string {
+	counts := map[string]int{}
+	for _, d := range drifts {
+		counts[d.Kind]++
+	}
+	var parts []string
+	for _, kind := range []string{UnknownField, MissingField, TypeChanged} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	if len(parts) == 0 {
+		return "no drift"
+	}
+	return strings.Join(parts, ", ")
+}
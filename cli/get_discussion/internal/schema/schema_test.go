@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type topic struct {
+	Name          string `json:"name"`
+	TotalMessages *int   `json:"totalMessages"`
+	Tags          []struct {
+		ID int `json:"id"`
+	} `json:"tags"`
+	Ignored string `json:"-"`
+}
+
+func TestCheckReportsDrift(t *testing.T) {
+	payload := []byte(`{"name":"x","totalMessages":"3","tags":[{"id":1,"label":"a"},{"id":2.5}],"newField":true}`)
+	drifts, err := Check(payload, &topic{})
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	want := map[string]string{
+		"totalMessages": TypeChanged,
+		"tags[].label":  UnknownField,
+		"tags[].id":     TypeChanged,
+		"newField":      UnknownField,
+	}
+	if len(drifts) != len(want) {
+		t.Fatalf("unexpected drifts: %+v", drifts)
+	}
+	for _, d := range drifts {
+		if want[d.Path] != d.Kind {
+			t.Fatalf("unexpected drift: %+v", d)
+		}
+	}
+}
+
+func TestCheckAlternativeFields(t *testing.T) {
+	type item struct {
+		TopicURL string `json:"topicUrl" schema:"alt=url"`
+		URL      string `json:"url" schema:"alt=topicUrl"`
+		IsPinned bool   `json:"isPinned" schema:"optional"`
+	}
+	drifts, err := Check([]byte(`{"url":"/t/1"}`), &item{})
+	if err != nil || len(drifts) != 0 {
+		t.Fatalf("unexpected drifts: %+v %v", drifts, err)
+	}
+	drifts, _ = Check([]byte(`{}`), &item{})
+	if len(drifts) != 2 || drifts[0].Kind != MissingField || drifts[1].Kind != MissingField {
+		t.Fatalf("both alternatives missing should be reported: %+v", drifts)
+	}
+}
+
+func TestRecorderDedupesAndWrites(t *testing.T) {
+	r := NewRecorder()
+	r.Check("/topic", []byte(`{"tags":[]}`), &topic{})
+	r.Check("/topic", []byte(`{"tags":[]}`), &topic{})
+	report := r.Report()
+	if len(report) != 2 || report[0].Path != "name" || report[0].Count != 2 || report[0].Kind != MissingField {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if got := Summary(report); got != "2 missing_field" {
+		t.Fatalf("unexpected summary: %s", got)
+	}
+	if err := r.WriteReport(filepath.Join(t.TempDir(), "drift.json"), false); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
+
+type votes int
+
+func (v *votes) UnmarshalJSON([]byte) error { return nil }
+
+func TestCheckSkipsUnmarshalers(t *testing.T) {
+	type comment struct {
+		Votes votes `json:"votes"`
+	}
+	for _, doc := range []string{`{"votes":3}`, `{"votes":{"totalVotes":3}}`} {
+		if drifts, err := Check([]byte(doc), &comment{}); err != nil || len(drifts) != 0 {
+			t.Fatalf("%s: unexpected drifts: %+v %v", doc, drifts, err)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package scoreboard
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+)
+
+// Row is one submission row of the Score.md table. Algorithm, CV and Notes are
+// maintained by hand and survive every sync; the rest comes from Kaggle.
+type Row struct {
+	Ref          string
+	Date         string
+	FileName     string
+	Description  string
+	Algorithm    string
+	CV           string
+	PublicScore  string
+	PrivateScore string
+	Status       string
+	Notes        string
+	// Other keeps the cells of header columns the tool does not know, by
+	// column index, so they survive syncs too.
+	Other map[int]string
+}
+
+// Table is the parsed tbody of Score.md: rows synced from Kaggle (keyed by
+// data-ref) plus any hand-written rows, which are kept verbatim.
+type Table struct {
+	// Columns is the layout of the document's header, one field per column
+	// ("" for columns the tool does not know). It is nil when the document
+	// has no header, in which case Render writes DefaultColumns.
+	Columns []string
+	Rows    []Row
+	Manual  []string
+	// manualRows are the Manual rows read with Columns, so their scores
+	// count when picking the best rows.
+	manualRows []Row
+}
+
+var (
+	tbodyRe   = regexp.MustCompile(`(?is)<tbody>(.*?)</tbody>`)
+	theadRe   = regexp.MustCompile(`(?is)<thead>(.*?)</thead>`)
+	trRe      = regexp.MustCompile(`(?is)<tr([^>]*)>(.*?)</tr>`)
+	thRe      = regexp.MustCompile(`(?is)<th[^>]*>(.*?)</th>`)
+	tdRe      = regexp.MustCompile(`(?is)<td[^>]*>(.*?)</td>`)
+	refAttrRe = regexp.MustCompile(`data-ref=["']([^"']+)["']`)
+	tagRe     = regexp.MustCompile(`<[^>]+>`)
+	numberRe  = regexp.MustCompile(`^-?\d+(?:\.\d+)?$`)
+	labelRe   = regexp.MustCompile(`^[A-Za-z][\w ]*:`)
+)
+
+// Row fields a column can hold.
+const (
+	fieldDate        = "date"
+	fieldFile        = "file"
+	fieldDescription = "description"
+	fieldAlgorithm   = "algorithm"
+	fieldCV          = "cv"
+	fieldPublic      = "public"
+	fieldPrivate     = "private"
+	fieldStatus      = "status"
+	fieldNotes       = "notes"
+)
+
+// DefaultColumns is the header Render writes into a document without one.
+var DefaultColumns = []string{"Date", "File", "Description", "Algorithm", "CV", "Public", "Private", "Status", "Notes"}
+
+// headerFields maps header names, lowercased, to the field they hold. The
+// template in docs/Score.md uses Name/Algorithm/Eval/Score/Detail.
+var headerFields = map[string]string{
+	"date":          fieldDate,
+	"file":          fieldFile,
+	"name":          fieldFile,
+	"description":   fieldDescription,
+	"algorithm":     fieldAlgorithm,
+	"cv":            fieldCV,
+	"eval":          fieldCV,
+	"public":        fieldPublic,
+	"public score":  fieldPublic,
+	"score":         fieldPublic,
+	"private":       fieldPrivate,
+	"private score": fieldPrivate,
+	"status":        fieldStatus,
+	"notes":         fieldNotes,
+	"detail":        fieldNotes,
+}
+
+// layout returns the fields of header names.
+func layout(names []string) []string {
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = headerFields[strings.ToLower(strings.TrimSpace(name))]
+	}
+	return fields
+}
+
+// headerColumns returns the column names of the thead of doc, or nil.
+func headerColumns(doc string) []string {
+	m := theadRe.FindStringSubmatch(doc)
+	if m == nil {
+		return nil
+	}
+	var names []string
+	for _, th := range thRe.FindAllStringSubmatch(m[1], -1) {
+		names = append(names, cellText(th[1]))
+	}
+	return names
+}
+
+// Parse extracts the table rows from a Score.md document.
+func Parse(doc string) Table {
+	var t Table
+	if names := headerColumns(doc); len(names) > 0 {
+		t.Columns = layout(names)
+	}
+	columns := t.Columns
+	if columns == nil {
+		columns = layout(DefaultColumns)
+	}
+	m := tbodyRe.FindStringSubmatch(doc)
+	if m == nil {
+		return t
+	}
+	for _, tr := range trRe.FindAllStringSubmatch(m[1], -1) {
+		var cells []string
+		for _, td := range tdRe.FindAllStringSubmatch(tr[2], -1) {
+			cells = append(cells, cellText(td[1]))
+		}
+		row := rowFromCells(columns, cells)
+		ref := refAttrRe.FindStringSubmatch(tr[1])
+		if ref == nil {
+			t.Manual = append(t.Manual, strings.TrimSpace(tr[0]))
+			t.manualRows = append(t.manualRows, row)
+			continue
+		}
+		row.Ref = ref[1]
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+func rowFromCells(columns, cells []string) Row {
+	var r Row
+	for i, field := range columns {
+		if i >= len(cells) {
+			break
+		}
+		if p := r.field(field); p != nil {
+			*p = cells[i]
+		} else if cells[i] != "" {
+			if r.Other == nil {
+				r.Other = map[int]string{}
+			}
+			r.Other[i] = cells[i]
+		}
+	}
+	return r
+}
+
+// field returns the Row field a column holds, or nil for unknown columns.
+func (r *Row) field(name string) *string {
+	switch name {
+	case fieldDate:
+		return &r.Date
+	case fieldFile:
+		return &r.FileName
+	case fieldDescription:
+		return &r.Description
+	case fieldAlgorithm:
+		return &r.Algorithm
+	case fieldCV:
+		return &r.CV
+	case fieldPublic:
+		return &r.PublicScore
+	case fieldPrivate:
+		return &r.PrivateScore
+	case fieldStatus:
+		return &r.Status
+	case fieldNotes:
+		return &r.Notes
+	}
+	return nil
+}
+
+func cellText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(tagRe.ReplaceAllString(s, "")))
+}
+
+// Merge updates t with subs, keeping the manual columns of rows already
+// present. Rows are ordered newest first so repeated syncs are stable.
+func Merge(t Table, subs []api.Submission) Table {
+	byRef := map[string]Row{}
+	for _, r := range t.Rows {
+		byRef[r.Ref] = r
+	}
+	for _, s := range subs {
+		ref := strconv.Itoa(s.Ref)
+		r := byRef[ref]
+		r.Ref = ref
+		r.Date = datePart(s.Date)
+		r.FileName = s.FileName
+		r.Description = s.Description
+		r.PublicScore = s.PublicScore
+		r.PrivateScore = s.PrivateScore
+		r.Status = normalizeStatus(s.Status)
+		byRef[ref] = r
+	}
+	rows := make([]Row, 0, len(byRef))
+	for _, r := range byRef {
+		rows = append(rows, r)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Date != rows[j].Date {
+			return rows[i].Date > rows[j].Date
+		}
+		a, _ := strconv.Atoi(rows[i].Ref)
+		b, _ := strconv.Atoi(rows[j].Ref)
+		return a > b
+	})
+	return Table{Columns: t.Columns, Rows: rows, Manual: t.Manual, manualRows: t.manualRows}
+}
+
+func datePart(s string) string {
+	if i := strings.IndexAny(s, "T "); i > 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// normalizeStatus turns "SubmissionStatus.COMPLETE" into "complete".
+func normalizeStatus(s string) string {
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.ToLower(s)
+}
+
+// Best returns the indexes of the rows with the best public and best CV score,
+// or -1 when no row has a parseable value. Rows whose score does not parse,
+// such as the template's "0.xxx" placeholder, never win.
+func Best(rows []Row, lowerIsBetter bool) (public, cv int) {
+	public, cv = -1, -1
+	var bestPublic, bestCV float64
+	better := func(a, b float64) bool {
+		if lowerIsBetter {
+			return a < b
+		}
+		return a > b
+	}
+	for i, r := range rows {
+		if v, ok := parseScore(r.PublicScore); ok && (public < 0 || better(v, bestPublic)) {
+			public, bestPublic = i, v
+		}
+		if v, ok := parseScore(r.CV); ok && (cv < 0 || better(v, bestCV)) {
+			cv, bestCV = i, v
+		}
+	}
+	return public, cv
+}
+
+// parseScore reads a score cell: a number, or labelled numbers such as
+// "CV: 0.81, Loss: 0.4", which yields the first one. Every part must be a
+// whole number, so placeholders like "0.xxx" or "CV: , Loss: " do not parse.
+func parseScore(s string) (float64, bool) {
+	var first string
+	for i, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if label := labelRe.FindString(part); label != "" {
+			part = strings.TrimSpace(part[len(label):])
+		}
+		if !numberRe.MatchString(part) {
+			return 0, false
+		}
+		if i == 0 {
+			first = part
+		}
+	}
+	v, err := strconv.ParseFloat(first, 64)
+	return v, err == nil
+}
+
+// Render replaces the tbody of doc with the contents of t, writing each
+// synced row in the layout of the document's header. A document without a
+// header gets DefaultColumns. Hand-written rows are kept verbatim but their
+// scores still compete for the best-row badges.
+func Render(doc string, t Table, lowerIsBetter bool) string {
+	if !tbodyRe.MatchString(doc) {
+		return doc
+	}
+	columns := t.Columns
+	if columns == nil {
+		columns = layout(DefaultColumns)
+	}
+	bestPublic, bestCV := Best(append(append([]Row(nil), t.Rows...), t.manualRows...), lowerIsBetter)
+
+	var body strings.Builder
+	body.WriteString("<tbody>\n")
+	for i, r := range t.Rows {
+		fmt.Fprintf(&body, "      <tr data-ref=\"%s\">\n", html.EscapeString(r.Ref))
+		for c, field := range columns {
+			body.WriteString("        " + renderCell(field, r, c, i == bestPublic, i == bestCV) + "\n")
+		}
+		body.WriteString("      </tr>\n")
+	}
+	for _, m := range t.Manual {
+		body.WriteString("      " + m + "\n")
+	}
+	body.WriteString("    </tbody>")
+
+	if t.Columns == nil {
+		var head strings.Builder
+		head.WriteString("<thead>\n      <tr>\n")
+		for _, c := range DefaultColumns {
+			fmt.Fprintf(&head, "        <th>%s</th>\n", c)
+		}
+		head.WriteString("      </tr>\n    </thead>\n    ")
+		if theadRe.MatchString(doc) {
+			doc = theadRe.ReplaceAllLiteralString(doc, strings.TrimSuffix(head.String(), "\n    "))
+		} else {
+			loc := tbodyRe.FindStringIndex(doc)
+			doc = doc[:loc[0]] + head.String() + doc[loc[0]:]
+		}
+	}
+	return tbodyRe.ReplaceAllLiteralString(doc, body.String())
+}
+
+// renderCell writes column c of r, which holds field.
+func renderCell(field string, r Row, c int, bestPublic, bestCV bool) string {
+	switch field {
+	case fieldDate:
+		return fmt.Sprintf("<td class=\"eval-data\">%s</td>", esc(r.Date))
+	case fieldFile:
+		return fmt.Sprintf("<td class=\"model-name\">%s</td>", esc(r.FileName))
+	case fieldDescription, fieldNotes:
+		return fmt.Sprintf("<td class=\"detail-text\">%s</td>", esc(*r.field(field)))
+	case fieldAlgorithm:
+		return "<td>" + span("badge badge-blue", r.Algorithm) + "</td>"
+	case fieldCV:
+		class := ""
+		if bestCV {
+			class = "badge badge-purple"
+		}
+		return "<td class=\"eval-data\">" + span(class, r.CV) + "</td>"
+	case fieldPublic:
+		class := ""
+		if bestPublic {
+			class = "badge badge-green"
+		}
+		return "<td>" + span(class, r.PublicScore) + "</td>"
+	case fieldPrivate:
+		return "<td>" + span("", r.PrivateScore) + "</td>"
+	case fieldStatus:
+		return "<td>" + span(statusClass(r.Status), r.Status) + "</td>"
+	}
+	return "<td>" + esc(r.Other[c]) + "</td>"
+}
+
+func esc(s string) string {
+	return html.EscapeString(s)
+}
+
+func span(class, value string) string {
+	if value == "" {
+		return ""
+	}
+	if class == "" {
+		return "<span>" + esc(value) + "</span>"
+	}
+	return fmt.Sprintf("<span class=\"%s\">%s</span>", class, esc(value))
+}
+
+func statusClass(status string) string {
+	switch status {
+	case "complete":
+		return "badge badge-gray"
+	case "pending":
+		return "badge badge-yellow"
+	case "error":
+		return "badge badge-red"
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package scoreboard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+)
+
+// sampleDoc has no header, so Render writes DefaultColumns.
+const sampleDoc = `<body>
+  <table>
+    <tbody>
+      <tr>
+        <td class="model-name">name</td>
+      </tr>
+    </tbody>
+  </table>
+</body>`
+
+func TestMergeKeepsManualColumns(t *testing.T) {
+	subs := []api.Submission{
+		{Ref: 2, FileName: "b.csv", Date: "2024-05-02T10:00:00Z", PublicScore: "0.80", Status: "SubmissionStatus.COMPLETE"},
+		{Ref: 1, FileName: "a.csv", Date: "2024-05-01T10:00:00Z", PublicScore: "0.85", Status: "complete"},
+	}
+	doc := Render(sampleDoc, Merge(Parse(sampleDoc), subs), false)
+
+	table := Parse(doc)
+	if len(table.Rows) != 2 || len(table.Manual) != 1 {
+		t.Fatalf("unexpected table: %+v", table)
+	}
+	table.Rows[1].CV = "CV: 0.9"
+	table.Rows[1].Notes = "seed avg & tta"
+	doc = Render(doc, table, false)
+
+	subs[0].PublicScore = "0.86"
+	again := Render(doc, Merge(Parse(doc), subs), false)
+	if Render(again, Merge(Parse(again), subs), false) != again {
+		t.Fatalf("sync is not idempotent")
+	}
+
+	rows := Parse(again).Rows
+	if rows[0].Ref != "2" || rows[0].PublicScore != "0.86" || rows[0].Status != "complete" {
+		t.Fatalf("unexpected first row: %+v", rows[0])
+	}
+	if rows[1].CV != "CV: 0.9" || rows[1].Notes != "seed avg & tta" {
+		t.Fatalf("manual columns lost: %+v", rows[1])
+	}
+	if !strings.Contains(again, `<span class="badge badge-green">0.86</span>`) {
+		t.Fatalf("best public not highlighted:\n%s", again)
+	}
+	if !strings.Contains(again, `<span class="badge badge-purple">CV: 0.9</span>`) {
+		t.Fatalf("best cv not highlighted:\n%s", again)
+	}
+}
+
+func TestBestLowerIsBetter(t *testing.T) {
+	rows := []Row{{PublicScore: "0.3"}, {PublicScore: "0.1"}, {PublicScore: ""}}
+	public, cv := Best(rows, true)
+	if public != 1 || cv != -1 {
+		t.Fatalf("unexpected best: public=%d cv=%d", public, cv)
+	}
+}
+
+func TestRenderKeepsScoreTemplateLayout(t *testing.T) {
+	// testdata/Score.md is a copy of the template in docs/Score.md.
+	data, err := os.ReadFile(filepath.Join("testdata", "Score.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(data)
+	subs := []api.Submission{
+		{Ref: 7, FileName: "lgbm.csv", Date: "2024-05-02T10:00:00Z", PublicScore: "0.80", Status: "complete"},
+	}
+	table := Merge(Parse(doc), subs)
+	table.Rows[0].Algorithm = "LightGBM"
+	table.Rows[0].CV = "CV: 0.79"
+	table.Rows[0].Notes = "5 folds"
+	out := Render(doc, table, false)
+
+	if theadRe.FindString(out) != theadRe.FindString(doc) {
+		t.Fatalf("header changed:\n%s", theadRe.FindString(out))
+	}
+	want := `      <tr data-ref="7">
+        <td class="model-name">lgbm.csv</td>
+        <td><span class="badge badge-blue">LightGBM</span></td>
+        <td class="eval-data"><span class="badge badge-purple">CV: 0.79</span></td>
+        <td><span class="badge badge-green">0.80</span></td>
+        <td class="detail-text">5 folds</td>
+      </tr>`
+	if !strings.Contains(out, want) {
+		t.Fatalf("row not in the template layout:\n%s", out)
+	}
+	if !strings.Contains(out, `<td class="eval-data">CV: , Loss: </td>`) {
+		t.Fatalf("manual row lost:\n%s", out)
+	}
+	if Render(out, Merge(Parse(out), subs), false) != out {
+		t.Fatalf("sync is not idempotent")
+	}
+}
+
+func TestManualRowsCompeteForBestCV(t *testing.T) {
+	doc := `<table>
+    <thead>
+      <tr><th>Name</th><th>Eval</th><th>Score</th></tr>
+    </thead>
+    <tbody>
+      <tr><td>blend</td><td>CV: 0.95</td><td>0.90</td></tr>
+    </tbody>
+  </table>`
+	subs := []api.Submission{{Ref: 1, FileName: "a.csv", PublicScore: "0.85"}}
+	table := Merge(Parse(doc), subs)
+	table.Rows[0].CV = "CV: 0.9"
+	out := Render(doc, table, false)
+	if strings.Contains(out, "badge-purple") || strings.Contains(out, "badge-green") {
+		t.Fatalf("synced row badged although the manual row is better:\n%s", out)
+	}
+}
+
+func TestPlaceholdersNeverWin(t *testing.T) {
+	for s, want := range map[string]bool{
+		"0.81": true, "-1.5": true, "CV: 0.81": true, "CV: 0.81, Loss: 0.4": true,
+		"0.xxx": false, "CV: , Loss: ": false, "~0.8": false, "0.8 (seed 1)": false, "": false,
+	} {
+		if _, ok := parseScore(s); ok != want {
+			t.Errorf("parseScore(%q) ok = %v, want %v", s, ok, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "Score.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := Merge(Parse(string(data)), []api.Submission{{Ref: 1, FileName: "a.csv", PublicScore: "0.85"}})
+	table.Rows[0].CV = "CV: 0.9, Loss: 0.3"
+	out := Render(string(data), table, true)
+	if !strings.Contains(out, `<span class="badge badge-green">0.85</span>`) || !strings.Contains(out, `<span class="badge badge-purple">CV: 0.9, Loss: 0.3</span>`) {
+		t.Fatalf("the template row should not take the badges:\n%s", out)
+	}
+}
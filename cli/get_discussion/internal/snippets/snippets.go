@@ -0,0 +1,328 @@
+package snippets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/fsutil"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// Snippet is one fenced code block found in a discussion.
+type Snippet struct {
+	Language string
+	Author   string
+	// Source links to the post or comment the block came from.
+	Source string
+	Code   string
+}
+
+// Entry is a snippet written to disk with its syntax check result.
+type Entry struct {
+	Snippet
+	File string
+	// Status is "ok", "syntax error: ...", "not checked" or "" for
+	// non-Python snippets.
+	Status string
+}
+
+// Python reports whether the snippet was treated as Python.
+func (e Entry) Python() bool {
+	return e.Language == "python"
+}
+
+var (
+	fenceRe      = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^\\s`]*)")
+	authorSlugRe = regexp.MustCompile(`[^a-z0-9]+`)
+	pythonHintRe = regexp.MustCompile(`(?m)^\s*(?:import\s+\w|from\s+[\w.]+\s+import\s|def\s+\w+\(|class\s+\w+[(:]|print\()`)
+)
+
+// languageAliases normalises fence info strings.
+var languageAliases = map[string]string{
+	"py": "python", "python3": "python", "ipython": "python", "ipython3": "python",
+	"sh": "bash", "shell": "bash", "console": "bash", "zsh": "bash",
+	"js": "javascript", "ts": "typescript", "c++": "cpp", "golang": "go",
+	"yml": "yaml", "rscript": "r",
+}
+
+// extensions maps a language to its file extension and line comment prefix.
+// An empty prefix means the format has no comments, so no header is written.
+var extensions = map[string]struct{ ext, comment string }{
+	"python":     {".py", "#"},
+	"r":          {".R", "#"},
+	"bash":       {".sh", "#"},
+	"julia":      {".jl", "#"},
+	"yaml":       {".yaml", "#"},
+	"toml":       {".toml", "#"},
+	"sql":        {".sql", "--"},
+	"javascript": {".js", "//"},
+	"typescript": {".ts", "//"},
+	"go":         {".go", "//"},
+	"c":          {".c", "//"},
+	"cpp":        {".cpp", "//"},
+	"java":       {".java", "//"},
+	"scala":      {".scala", "//"},
+	"rust":       {".rs", "//"},
+	"json":       {".json", ""},
+}
+
+// Extract returns every fenced code block of d in thread order. Messages are
+// used when available so each block is attributed to its comment; otherwise
+// the whole document is scanned and attributed to the topic author. Blocks
+// without a language are treated as Python when they look like it.
+func Extract(d *discussion.Discussion) []Snippet {
+	if len(d.Messages) == 0 {
+		return fromMarkdown(d.ContentMD, d.Author, d.Link)
+	}
+	var out []Snippet
+	for _, m := range d.Messages {
+		source := d.Link
+		if !m.IsMain && m.ID > 0 {
+			source = fmt.Sprintf("%s#%d", d.Link, m.ID)
+		}
+		out = append(out, fromMarkdown(m.Body, urlutil.FirstNonEmpty(m.AuthorName, m.Author), source)...)
+	}
+	return out
+}
+
+// fromMarkdown scans md for fenced code blocks. A fence closes on a line of
+// the same character at least as long as the opening one.
+func fromMarkdown(md, author, source string) []Snippet {
+	var out []Snippet
+	lines := strings.Split(md, "\n")
+	for i := 0; i < len(lines); i++ {
+		m := fenceRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		fence := m[1]
+		if fence[0] == '`' && strings.Contains(lines[i][strings.Index(lines[i], fence)+len(fence):], "`") {
+			continue // inline code span, not a fence
+		}
+		var code []string
+		j := i + 1
+		for ; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				break
+			}
+			code = append(code, lines[j])
+		}
+		i = j
+		body := strings.Trim(strings.Join(code, "\n"), "\n")
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+		out = append(out, Snippet{
+			Language: normalizeLanguage(m[2], body),
+			Author:   author,
+			Source:   source,
+			Code:     body,
+		})
+	}
+	return out
+}
+
+func normalizeLanguage(info, code string) string {
+	lang := strings.ToLower(strings.Trim(info, "{}."))
+	if alias, ok := languageAliases[lang]; ok {
+		lang = alias
+	}
+	if lang == "" && pythonHintRe.MatchString(code) {
+		return "python"
+	}
+	return lang
+}
+
+// FileName returns NN_<author> with the language's extension, .txt when unknown.
+func FileName(n int, s Snippet) string {
+	author := strings.Trim(authorSlugRe.ReplaceAllString(strings.ToLower(s.Author), "_"), "_")
+	if author == "" {
+		author = "unknown"
+	}
+	ext := ".txt"
+	if e, ok := extensions[s.Language]; ok {
+		ext = e.ext
+	}
+	return fmt.Sprintf("%02d_%s%s", n, author, ext)
+}
+
+// fileContent prefixes the code with a comment pointing back to its source.
+func fileContent(s Snippet) string {
+	prefix := "#"
+	if e, ok := extensions[s.Language]; ok {
+		prefix = e.comment
+	}
+	if prefix == "" {
+		return s.Code + "\n"
+	}
+	header := fmt.Sprintf("%s Source: %s\n", prefix, s.Source)
+	if s.Author != "" {
+		header += fmt.Sprintf("%s Author: %s\n", prefix, s.Author)
+	}
+	return header + "\n" + s.Code + "\n"
+}
+
+// errNoPython is returned by the checker when no interpreter is available.
+var errNoPython = errors.New("python3 not found")
+
+// checkPython parses Python source with the standard ast module, reporting
+// errors against name. It is a variable so tests can run without an
+// interpreter.
+var checkPython = func(name, code string) error {
+	bin, err := exec.LookPath("python3")
+	if err != nil {
+		return errNoPython
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(bin, "-c", "import ast, sys\nast.parse(sys.stdin.read(), sys.argv[1])", name)
+	cmd.Stdin = strings.NewReader(code)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		return errors.New(lines[len(lines)-1])
+	}
+	return nil
+}
+
+var (
+	// magicRe matches IPython line magics and shell escapes such as
+	// "%time fit()" or "!pip install x", and assignments from them.
+	magicRe = regexp.MustCompile(`^(\s*)(?:[\w.]+\s*=\s*)?[%!]`)
+	// pythonCellMagics run the rest of the cell as Python.
+	pythonCellMagics = map[string]bool{"time": true, "timeit": true, "capture": true, "prun": true}
+)
+
+// stripMagics turns the IPython syntax of a notebook cell into Python the
+// ast module accepts: magic and shell lines become "pass" at the same
+// indentation, so blocks and line numbers stay intact. ok is false for cells
+// whose %% magic makes them another language, e.g. %%bash.
+func stripMagics(code string) (py string, ok bool) {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if name, found := strings.CutPrefix(trimmed, "%%"); found {
+			if name, _, _ = strings.Cut(name, " "); !pythonCellMagics[name] {
+				return "", false
+			}
+		}
+		if m := magicRe.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1] + "pass"
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// indexFileRe matches the file cell of an index.md row, see BuildIndex.
+var indexFileRe = regexp.MustCompile(`(?m)^\| \[([^\]/\\]+)\]\(`)
+
+// Write replaces the snippets in dir with the given ones and writes an
+// index.md listing them. Only files listed in the previous index.md are
+// replaced; anything else in dir is left alone. Python snippets are
+// syntax-checked with IPython magics stripped. With sync set every file is
+// flushed to disk.
+func Write(dir string, snippets []Snippet, sync bool) ([]Entry, error) {
+	entries := make([]Entry, 0, len(snippets))
+	keep := map[string]bool{}
+	for i, s := range snippets {
+		e := Entry{Snippet: s, File: FileName(i+1, s)}
+		keep[e.File] = true
+		entries = append(entries, e)
+	}
+	if err := removeStale(dir, keep); err != nil {
+		return nil, err
+	}
+	if len(snippets) == 0 {
+		os.Remove(dir) // only succeeds when nothing else is in it
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		content := fileContent(e.Snippet)
+		if err := fsutil.WriteFileAtomic(filepath.Join(dir, e.File), []byte(content), 0o644, sync); err != nil {
+			return entries[:i], err
+		}
+		if !e.Python() {
+			continue
+		}
+		code, ok := stripMagics(content)
+		if !ok {
+			e.Status = "not checked"
+			continue
+		}
+		switch err := checkPython(e.File, code); {
+		case err == nil:
+			e.Status = "ok"
+		case errors.Is(err, errNoPython):
+			e.Status = "not checked"
+		default:
+			e.Status = "syntax error: " + err.Error()
+		}
+	}
+	return entries, fsutil.WriteFileAtomic(filepath.Join(dir, "index.md"), []byte(BuildIndex(entries)), 0o644, sync)
+}
+
+// removeStale deletes the snippet files listed in the index.md of dir that
+// are not in keep, and the index itself when keep is empty. Files missing
+// from the index were not written by Write and are left alone, even when
+// they look like snippets, e.g. "01_notes.txt".
+func removeStale(dir string, keep map[string]bool) error {
+	index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for _, m := range indexFileRe.FindAllStringSubmatch(string(index), -1) {
+		if !keep[m[1]] {
+			stale = append(stale, m[1])
+		}
+	}
+	if len(keep) == 0 {
+		stale = append(stale, "index.md")
+	}
+	for _, name := range stale {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildIndex renders a Markdown table of snippets and their Python check.
+func BuildIndex(entries []Entry) string {
+	var b strings.Builder
+	b.WriteString("# Snippets\n\n")
+	b.WriteString("| File | Language | Author | Source | Python |\n")
+	b.WriteString("| ---- | -------- | ------ | ------ | ------ |\n")
+	for _, e := range entries {
+		lang := e.Language
+		if lang == "" {
+			lang = "-"
+		}
+		status := e.Status
+		if status == "" {
+			status = "-"
+		}
+		fmt.Fprintf(&b, "| [%s](%s) | %s | %s | [link](%s) | %s |\n",
+			e.File, e.File, lang, cell(e.Author), e.Source, cell(status))
+	}
+	return b.String()
+}
+
+func cell(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "|", `\|`)
+}
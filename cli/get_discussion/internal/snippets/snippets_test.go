@@ -0,0 +1,149 @@
+package snippets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func TestExtract(t *testing.T) {
+	d := &discussion.Discussion{
+		Link: "https://www.kaggle.com/competitions/x/discussion/100",
+		Messages: []discussion.Message{
+			{ID: 1, AuthorName: "alice", IsMain: true, Body: "CV scheme:\n\n```python\nfrom sklearn.model_selection import GroupKFold\n```\n\nInline ```not a fence``` here."},
+			{ID: 7, Author: "Bob B.", Body: "````\nimport numpy as np\n```\nnested\n```\n````\n\n~~~sql\nSELECT 1\n~~~\n\n```\n\n```"},
+		},
+	}
+	got := Extract(d)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 snippets, got %d: %+v", len(got), got)
+	}
+	if got[0].Language != "python" || got[0].Author != "alice" || got[0].Source != d.Link {
+		t.Fatalf("unexpected first snippet: %+v", got[0])
+	}
+	if got[1].Language != "python" || !strings.Contains(got[1].Code, "nested\n```") {
+		t.Fatalf("unlabelled block should be guessed as python and keep inner fences: %+v", got[1])
+	}
+	if got[1].Source != d.Link+"#7" || got[2].Language != "sql" {
+		t.Fatalf("unexpected comment snippets: %+v", got[1:])
+	}
+
+	if name := FileName(2, got[1]); name != "02_bob_b.py" {
+		t.Fatalf("unexpected file name: %s", name)
+	}
+	if name := FileName(12, Snippet{Language: "text"}); name != "12_unknown.txt" {
+		t.Fatalf("unexpected file name: %s", name)
+	}
+}
+
+func TestExtractWithoutMessages(t *testing.T) {
+	d := &discussion.Discussion{Author: "carol", Link: "https://www.kaggle.com/discussion/5", ContentMD: "```r\nx <- 1\n```"}
+	got := Extract(d)
+	if len(got) != 1 || got[0].Language != "r" || got[0].Author != "carol" || got[0].Source != d.Link {
+		t.Fatalf("unexpected snippets: %+v", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	orig := checkPython
+	defer func() { checkPython = orig }()
+	checkPython = func(name, code string) error {
+		if strings.Contains(code, "def (") {
+			return errors.New("SyntaxError: invalid syntax")
+		}
+		return nil
+	}
+
+	dir := filepath.Join(t.TempDir(), "topic", "snippets")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, "09_old.py")
+	if err := os.WriteFile(stale, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	previous := BuildIndex([]Entry{{Snippet: Snippet{Language: "python"}, File: "09_old.py"}})
+	if err := os.WriteFile(filepath.Join(dir, "index.md"), []byte(previous), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	own := filepath.Join(dir, "my_notes.txt")
+	if err := os.WriteFile(own, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lookalike := filepath.Join(dir, "10_notes.txt")
+	if err := os.WriteFile(lookalike, []byte("keep me too"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Write(dir, []Snippet{
+		{Language: "python", Author: "alice", Source: "https://k/1", Code: "print(1)"},
+		{Language: "python", Author: "bob", Source: "https://k/1#2", Code: "def (:"},
+		{Language: "json", Author: "bob", Source: "https://k/1#2", Code: "{}"},
+	}, false)
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale snippet should be removed")
+	}
+	for _, path := range []string{own, lookalike} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("files Write did not generate should be kept: %v", err)
+		}
+	}
+	if entries[0].Status != "ok" || !strings.HasPrefix(entries[1].Status, "syntax error") || entries[2].Status != "" {
+		t.Fatalf("unexpected statuses: %+v", entries)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "01_alice.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "# Source: https://k/1\n# Author: alice\n\nprint(1)\n" {
+		t.Fatalf("unexpected file content: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "03_bob.json")); string(data) != "{}\n" {
+		t.Fatalf("json snippet should have no header: %q", data)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "| [01_alice.py](01_alice.py) | python | alice | [link](https://k/1) | ok |") {
+		t.Fatalf("unexpected index:\n%s", index)
+	}
+}
+
+func TestStripMagics(t *testing.T) {
+	code, ok := stripMagics("%time model.fit(X)\n!pip install -q lightgbm\nfor f in files:\n    out = !ls {f}\nprint(1)")
+	want := "pass\npass\nfor f in files:\n    pass\nprint(1)"
+	if !ok || code != want {
+		t.Fatalf("unexpected code (ok=%v):\n%s", ok, code)
+	}
+	if _, ok := stripMagics("%%bash\nls -la"); ok {
+		t.Fatalf("bash cells are not Python")
+	}
+	if _, ok := stripMagics("%%time\nx = 1"); !ok {
+		t.Fatalf("%%%%time cells are Python")
+	}
+}
+
+func TestCheckPython(t *testing.T) {
+	if err := checkPython("good.py", "# Source: x\nimport os\n"); errors.Is(err, errNoPython) {
+		t.Skip("python3 not available")
+	} else if err != nil {
+		t.Fatalf("valid code reported as error: %v", err)
+	}
+	if err := checkPython("bad.py", "def f(:\n"); err == nil || !strings.Contains(err.Error(), "SyntaxError") {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+	code, _ := stripMagics("%time x = 1\n!pip install numpy\n")
+	if err := checkPython("magic.py", code); err != nil {
+		t.Fatalf("magics should be stripped before the check: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package solutions
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry is one harvested solution write-up.
+type Entry struct {
+	Placement int
+	Title     string
+	Team      string
+	Link      string
+	Path      string
+	Code      []string
+	Notebooks []string
+}
+
+var (
+	// placementRe needs an ordinal before "solution", so "2024 solution"
+	// is not read as 2024th place.
+	placementRe = regexp.MustCompile(`(?i)\b(?:(\d+)\s*(?:st|nd|rd|th)\s*(?:place|position|solution)|(\d+)\s*(?:place|position))\b`)
+	placeWordRe = regexp.MustCompile(`(?i)\b(first|second|third)\s+place\b`)
+	teamRe      = regexp.MustCompile(`(?i)(?:\bby\s+|[-–—|:(]\s*team\s+|\bteam\s+)([^()\[\]|,]+)`)
+	urlRe       = regexp.MustCompile(`https?://[^\s<>"'\)\]]+`)
+)
+
+var placeWords = map[string]int{"first": 1, "second": 2, "third": 3}
+
+// IsSolutionTitle reports whether a topic title announces a placement write-up,
+// e.g. "1st Place Solution" or "Silver medal - 42nd place".
+func IsSolutionTitle(title string) bool {
+	_, ok := ParsePlacement(title)
+	return ok && strings.Contains(strings.ToLower(title), "place")
+}
+
+// ParsePlacement extracts the leaderboard rank from a write-up title.
+func ParsePlacement(title string) (int, bool) {
+	if m := placementRe.FindStringSubmatch(title); m != nil {
+		if n, err := strconv.Atoi(m[1] + m[2]); err == nil && n > 0 {
+			return n, true
+		}
+	}
+	if m := placeWordRe.FindStringSubmatch(title); m != nil {
+		return placeWords[strings.ToLower(m[1])], true
+	}
+	return 0, false
+}
+
+// ParseTeam extracts a team name from titles such as
+// "3rd place solution - Team Foo" or "5th Place by Bar".
+func ParseTeam(title string) string {
+	m := teamRe.FindStringSubmatch(title)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimSpace(m[1])
+}
+
+// ExtractLinks returns the code repository and Kaggle notebook links found in
+// a Markdown body, deduplicated in order of appearance.
+func ExtractLinks(md string) (code, notebooks []string) {
+	seen := map[string]struct{}{}
+	for _, raw := range urlRe.FindAllString(md, -1) {
+		u := strings.TrimRight(raw, ".,;:!?")
+		if _, ok := seen[u]; ok {
+			continue
+		}
+		seen[u] = struct{}{}
+		lower := strings.ToLower(u)
+		switch {
+		case strings.Contains(lower, "kaggle.com/code/"), strings.Contains(lower, "kaggle.com/kernels/"),
+			strings.Contains(lower, "colab.research.google.com"):
+			notebooks = append(notebooks, u)
+		case strings.Contains(lower, "github.com/"), strings.Contains(lower, "gitlab.com/"),
+			strings.Contains(lower, "huggingface.co/"):
+			code = append(code, u)
+		}
+	}
+	return code, notebooks
+}
+
+// SortByPlacement orders entries by rank; unranked entries go last by title.
+func SortByPlacement(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Placement, entries[j].Placement
+		if a == 0 || b == 0 {
+			if a == b {
+				return entries[i].Title < entries[j].Title
+			}
+			return b == 0
+		}
+		return a < b
+	})
+}
+
+// BuildIndex renders a Markdown table of entries sorted by placement. Paths
+// are written relative to dir.
+func BuildIndex(competition, dir string, entries []Entry) string {
+	sorted := append([]Entry(nil), entries...)
+	SortByPlacement(sorted)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s solutions\n\n", competition)
+	b.WriteString("| Rank | Title | Team | Code | Notebooks |\n")
+	b.WriteString("| ---- | ----- | ---- | ---- | --------- |\n")
+	for _, e := range sorted {
+		rank := "-"
+		if e.Placement > 0 {
+			rank = strconv.Itoa(e.Placement)
+		}
+		title := cell(e.Title)
+		if e.Path != "" {
+			rel, err := filepath.Rel(dir, e.Path)
+			if err != nil {
+				rel = e.Path
+			}
+			title = fmt.Sprintf("[%s](%s)", title, filepath.ToSlash(rel))
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+			rank, title, cell(e.Team), linkList(e.Code), linkList(e.Notebooks))
+	}
+	return b.String()
+}
+
+func cell(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "|", `\|`)
+}
+
+func linkList(urls []string) string {
+	parts := make([]string, len(urls))
+	for i, u := range urls {
+		parts[i] = fmt.Sprintf("[%d](%s)", i+1, u)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,65 @@
+package solutions
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePlacementAndTeam(t *testing.T) {
+	cases := []struct {
+		title string
+		place int
+		team  string
+	}{
+		{"1st Place Solution - Team Foo", 1, "Foo"},
+		{"[42nd place] simple GBDT ensemble by bar", 42, "bar"},
+		{"Second place write-up", 2, ""},
+	}
+	for _, c := range cases {
+		got, ok := ParsePlacement(c.title)
+		if !ok || got != c.place {
+			t.Fatalf("placement for %q: got %d ok=%v", c.title, got, ok)
+		}
+		if team := ParseTeam(c.title); team != c.team {
+			t.Fatalf("team for %q: got %q", c.title, team)
+		}
+	}
+	if IsSolutionTitle("Which CV scheme do you use?") {
+		t.Fatalf("unexpected solution title")
+	}
+	for _, title := range []string{"Our 2024 solution", "Kaggle 2023 solution - 7 place"} {
+		if got, _ := ParsePlacement(title); got > 100 {
+			t.Fatalf("year read as placement in %q: %d", title, got)
+		}
+	}
+	if got, ok := ParsePlacement("7 place"); !ok || got != 7 {
+		t.Fatalf("placement without ordinal: got %d ok=%v", got, ok)
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	md := "Code: https://github.com/foo/bar. Notebook [here](https://www.kaggle.com/code/foo/baz) and https://github.com/foo/bar"
+	code, nbs := ExtractLinks(md)
+	if len(code) != 1 || code[0] != "https://github.com/foo/bar" {
+		t.Fatalf("unexpected code links: %v", code)
+	}
+	if len(nbs) != 1 || nbs[0] != "https://www.kaggle.com/code/foo/baz" {
+		t.Fatalf("unexpected notebook links: %v", nbs)
+	}
+}
+
+func TestBuildIndexSortsByRank(t *testing.T) {
+	dir := filepath.Join("out", "solutions", "comp")
+	idx := BuildIndex("comp", dir, []Entry{
+		{Placement: 0, Title: "Misc"},
+		{Placement: 10, Title: "Tenth", Path: filepath.Join(dir, "tenth.md")},
+		{Placement: 2, Title: "Second"},
+	})
+	second := strings.Index(idx, "Second")
+	tenth := strings.Index(idx, "[Tenth](tenth.md)")
+	misc := strings.Index(idx, "Misc")
+	if second < 0 || tenth < 0 || misc < 0 || !(second < tenth && tenth < misc) {
+		t.Fatalf("unexpected index order:\n%s", idx)
+	}
+}
@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// DefaultFilenameTemplate keeps every thread in one flat directory, named
+// after its title and topic ID.
+const DefaultFilenameTemplate = "{slug}.md"
+
+// Subdirectories of the output directory that harvest and --user mode
+// manage as output directories of their own. LoadExistingLinks skips them.
+const (
+	SolutionsDir = "solutions"
+	UsersDir     = "users"
+)
+
+var userNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// UserDir returns the output directory of --user mode for userName. Names
+// that are not plain Kaggle user names are rejected, so "../.." cannot
+// point it outside UsersDir.
+func UserDir(outputDir, userName string) (string, error) {
+	if !userNameRe.MatchString(userName) {
+		return "", fmt.Errorf("invalid user name %q: want letters, digits, '-' or '_'", userName)
+	}
+	return filepath.Join(outputDir, UsersDir, userName), nil
+}
+
+// Options control how SaveDiscussion lays files out.
+type Options struct {
+	// FilenameTemplate is the path of a thread relative to the output
+	// directory, with placeholders; see ValidateTemplate. Empty means
+	// DefaultFilenameTemplate.
+	FilenameTemplate string
+	// Fsync flushes every written file and its directory to disk before
+	// the write counts as done.
+	Fsync bool
+}
+
+func (o Options) template() string {
+	if o.FilenameTemplate == "" {
+		return DefaultFilenameTemplate
+	}
+	return o.FilenameTemplate
+}
+
+var placeholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// placeholders expand to a single path segment each.
+var placeholders = map[string]func(d *discussion.Discussion) string{
+	"slug":        func(d *discussion.Discussion) string { return slugifyTitle(d.Title) },
+	"id":          templateTopicID,
+	"topic_id":    templateTopicID,
+	"author":      func(d *discussion.Discussion) string { return placeholderSlug(d.Author, "unknown") },
+	"date":        func(d *discussion.Discussion) string { return templateDate(d, "2006-01-02") },
+	"year":        func(d *discussion.Discussion) string { return templateDate(d, "2006") },
+	"month":       func(d *discussion.Discussion) string { return templateDate(d, "2006-01") },
+	"competition": templateCompetition,
+}
+
+func templateTopicID(d *discussion.Discussion) string {
+	id, _ := urlutil.ExtractTopicID(d.Link)
+	return strconv.Itoa(id)
+}
+
+func templateDate(d *discussion.Discussion, layout string) string {
+	t, ok := ParseDate(d.PublishedDate)
+	if !ok {
+		return "undated"
+	}
+	return t.Format(layout)
+}
+
+func templateCompetition(d *discussion.Discussion) string {
+	if slug, ok := urlutil.CompetitionSlug(d.Link); ok {
+		return placeholderSlug(slug, "general")
+	}
+	return "general"
+}
+
+func placeholderSlug(s, fallback string) string {
+	if slug := slugifyTitle(s); slug != "discussion" {
+		return slug
+	}
+	return fallback
+}
+
+// ValidateTemplate checks a filename template: a relative, slash-separated
+// path ending in .md, using only the placeholders {slug} (the title, plus
+// the topic ID when the template has no {id}), {id} (or
+// {topic_id}, 0 when the link has none), {author}, {date}, {year}, {month}
+// (of the published date, "undated" when unknown) and {competition}
+// ("general" for site forums).
+func ValidateTemplate(tmpl string) error {
+	if !strings.HasSuffix(tmpl, ".md") {
+		return fmt.Errorf("filename template %q must end in .md", tmpl)
+	}
+	if path.IsAbs(tmpl) || filepath.IsAbs(tmpl) {
+		return fmt.Errorf("filename template %q must be relative", tmpl)
+	}
+	for _, seg := range strings.Split(tmpl, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("filename template %q has an empty, . or .. segment", tmpl)
+		}
+	}
+	for _, m := range placeholderRe.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := placeholders[m[1]]; !ok {
+			return fmt.Errorf("filename template %q: unknown placeholder {%s}", tmpl, m[1])
+		}
+	}
+	return nil
+}
+
+// expandTemplate returns the path of d relative to the output directory,
+// without the .md extension. {slug} ends in the topic ID unless the template
+// places the ID itself.
+func expandTemplate(tmpl string, d *discussion.Discussion) string {
+	slugID := !strings.Contains(tmpl, "{id}") && !strings.Contains(tmpl, "{topic_id}")
+	rel := placeholderRe.ReplaceAllStringFunc(tmpl, func(p string) string {
+		name := p[1 : len(p)-1]
+		if name == "slug" && slugID {
+			id, ok := urlutil.ExtractTopicID(d.Link)
+			return slugWithID(slugifyTitle(d.Title), id, ok)
+		}
+		expand, ok := placeholders[name]
+		if !ok {
+			return p
+		}
+		return expand(d)
+	})
+	return filepath.FromSlash(strings.TrimSuffix(rel, ".md"))
+}
+
+// availablePath returns outputDir/base.md, or base_2.md and so on when that
+// is taken by another thread or file. own is the thread's current file,
+// which does not count as taken, and neither does a file whose front matter
+// links to linkKey: the thread saved by a run that stopped before writing
+// its manifest.
+func availablePath(outputDir, base string, taken map[string]struct{}, own, linkKey string) string {
+	free := func(candidate string) bool {
+		if candidate == own {
+			return true
+		}
+		if _, dup := taken[candidate]; dup {
+			return false
+		}
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return true
+		}
+		return linkKey != "" && savedLink(candidate) == linkKey
+	}
+	candidate := filepath.Join(outputDir, base+".md")
+	for i := 2; !free(candidate); i++ {
+		candidate = filepath.Join(outputDir, fmt.Sprintf("%s_%d.md", base, i))
+	}
+	return candidate
+}
+
+// savedLink returns the canonical front matter link of the thread at path,
+// or "" when it cannot be read.
+func savedLink(path string) string {
+	meta, _, err := ReadDiscussionFile(path)
+	if err != nil {
+		return ""
+	}
+	return urlutil.CanonicalizeURL(meta.String("link"))
+}
+
+// placeDiscussion returns the path d is saved under, registering it in
+// existingByLink. A thread saved elsewhere, because its title or the
+// template changed, is moved there with its comment index and snippets;
+// when the move fails it stays where it is.
+func placeDiscussion(d *discussion.Discussion, outputDir string, existingByLink map[string]string, opts Options) string {
+	linkKey := urlutil.CanonicalizeURL(d.Link)
+	current, exists := existingByLink[linkKey]
+	want := availablePath(outputDir, expandTemplate(opts.template(), d), takenPaths(existingByLink, linkKey), current, linkKey)
+	if exists && want != current {
+		if err := moveDiscussion(current, want); err != nil {
+			log.Printf("[warn] Failed to move %s to %s: %v", current, want, err)
+			want = current
+		} else {
+			removeEmptyDirs(filepath.Dir(current), outputDir)
+		}
+	}
+	existingByLink[linkKey] = want
+	return want
+}
+
+// ThreadPath returns the Markdown path of d as SaveDiscussion would save it,
+// and reserves it in existingByLink without writing the thread. Formats that
+// skip Markdown place their files next to it, so files of a thread saved
+// elsewhere are moved there as SaveDiscussion would.
+func ThreadPath(d *discussion.Discussion, outputDir string, existingByLink map[string]string, opts Options) string {
+	return placeDiscussion(d, outputDir, existingByLink, opts)
+}
+
+// takenPaths returns the files of every thread but linkKey.
+func takenPaths(existingByLink map[string]string, linkKey string) map[string]struct{} {
+	taken := map[string]struct{}{}
+	for link, p := range existingByLink {
+		if link != linkKey {
+			taken[p] = struct{}{}
+		}
+	}
+	return taken
+}
+
+// siblingExts are the extensions of the per-thread export formats, whose
+// files move with their thread.
+var siblingExts = []string{".json", ".html"}
+
+// moveDiscussion renames a saved thread and the files kept next to it,
+// never replacing an existing file. Threads exported without Markdown have
+// only the files next to it.
+func moveDiscussion(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
+	}
+	if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fromBase, toBase := strings.TrimSuffix(from, ".md"), strings.TrimSuffix(to, ".md")
+	related := [][2]string{
+		{commentIndexPath(from), commentIndexPath(to)},
+		{fromBase, toBase},
+	}
+	for _, ext := range siblingExts {
+		related = append(related, [2]string{fromBase + ext, toBase + ext})
+	}
+	for _, r := range related {
+		if _, err := os.Stat(r[0]); err != nil {
+			continue
+		}
+		if _, err := os.Stat(r[1]); err == nil {
+			log.Printf("[warn] Not moving %s: %s already exists", r[0], r[1])
+			continue
+		}
+		if err := os.Rename(r[0], r[1]); err != nil {
+			log.Printf("[warn] Failed to move %s: %v", r[0], err)
+		}
+	}
+	return nil
+}
+
+// threadExists reports whether the thread whose Markdown path is path has
+// any file: the Markdown itself or an exported sibling.
+func threadExists(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	for _, ext := range siblingExts {
+		if _, err := os.Stat(strings.TrimSuffix(path, ".md") + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// removeEmptyDirs removes dir and its parents up to root while they are
+// empty.
+func removeEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
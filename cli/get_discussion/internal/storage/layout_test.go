@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	d := &discussion.Discussion{
+		Title:         "1st Place Solution",
+		Link:          "https://www.kaggle.com/competitions/titanic/discussion/123",
+		Author:        "Alice B",
+		PublishedDate: "2024-03-01T23:30:00-02:00",
+	}
+	got := expandTemplate("{competition}/{year}/{date}_{topic_id}_{author}_{slug}.md", d)
+	want := filepath.Join("titanic", "2024", "2024-03-02_123_alice_b_1st_place_solution")
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	forum := &discussion.Discussion{Title: "Hi", Link: "https://www.kaggle.com/discussions/general/5"}
+	if got := expandTemplate("{competition}/{month}_{id}.md", forum); got != filepath.Join("general", "undated_0") {
+		t.Fatalf("unexpected fallbacks: %s", got)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	if err := ValidateTemplate("{competition}/{date}_{topic_id}_{slug}.md"); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{"{slug}", "/abs/{slug}.md", "../{slug}.md", "a//{slug}.md", "{title}.md"} {
+		if ValidateTemplate(bad) == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSaveDiscussionMovesOnRename(t *testing.T) {
+	dir := t.TempDir()
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	d := thread(main)
+	path, err := SaveDiscussion(d, dir, LoadExistingLinks(dir), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "thread_1.md") {
+		t.Fatalf("default name should end in the topic ID: %s", path)
+	}
+	snippets := filepath.Join(dir, "thread_1", "snippets")
+	if err := os.MkdirAll(snippets, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "thread_1.json"), []byte("{}"), 0o644)
+
+	opts := Options{FilenameTemplate: "{competition}/{id}_{slug}.md"}
+	d.Title = "Renamed thread"
+	moved, err := SaveDiscussion(d, dir, LoadExistingLinks(dir), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "x", "1_renamed_thread.md")
+	if moved != want {
+		t.Fatalf("got %s, want %s", moved, want)
+	}
+	for _, gone := range []string{path, commentIndexPath(path), snippets, filepath.Join(dir, "thread_1.json")} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Errorf("%s should have moved", gone)
+		}
+	}
+	for _, kept := range []string{commentIndexPath(want), filepath.Join(dir, "x", "1_renamed_thread", "snippets"), filepath.Join(dir, "x", "1_renamed_thread.json")} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("%s missing: %v", kept, err)
+		}
+	}
+
+	links := LoadExistingLinks(dir)
+	if len(links) != 1 {
+		t.Fatalf("expected one saved thread, got %v", links)
+	}
+	if again, _ := SaveDiscussion(d, dir, links, opts); again != want {
+		t.Fatalf("unchanged thread moved to %s", again)
+	}
+}
+
+func TestSaveDiscussionReusesFileWithoutManifest(t *testing.T) {
+	dir := t.TempDir()
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	first, err := SaveDiscussion(thread(main), dir, map[string]string{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The run stopped before saving its manifest, so the next one does not
+	// know the thread.
+	again, err := SaveDiscussion(thread(main), dir, map[string]string{}, Options{})
+	if err != nil || again != first {
+		t.Fatalf("expected %s to be reused, got %s: %v", first, again, err)
+	}
+	other := thread(main)
+	other.Link = "https://www.kaggle.com/competitions/x/discussion/2"
+	if p, _ := SaveDiscussion(other, dir, map[string]string{}, Options{FilenameTemplate: "thread_1.md"}); p == first {
+		t.Fatalf("another thread must not take %s", first)
+	}
+}
+
+func TestThreadPath(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	d := thread()
+	path := ThreadPath(d, dir, links, Options{})
+	if path != filepath.Join(dir, "thread_1.md") || links[d.Link] != path {
+		t.Fatalf("got %s, links %v", path, links)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("ThreadPath should not write anything")
+	}
+	if again := ThreadPath(d, dir, links, Options{}); again != path {
+		t.Errorf("known thread got a new path: %s", again)
+	}
+
+	// Threads exported without Markdown move their files on renames.
+	exported := strings.TrimSuffix(path, ".md") + ".json"
+	if err := os.WriteFile(exported, []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.Title = "Renamed"
+	renamed := ThreadPath(d, dir, links, Options{})
+	if renamed != filepath.Join(dir, "renamed_1.md") {
+		t.Fatalf("renamed thread at %s", renamed)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(renamed, ".md") + ".json"); err != nil {
+		t.Errorf("export should move with the thread: %v", err)
+	}
+	if _, err := os.Stat(exported); !os.IsNotExist(err) {
+		t.Errorf("old export left behind")
+	}
+}
+
+func TestLoadExistingLinksSkipsManagedDirs(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, SolutionsDir, "titanic")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveDiscussion(thread(), sub, map[string]string{}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if links := LoadExistingLinks(dir); len(links) != 0 {
+		t.Fatalf("harvested threads should be skipped: %v", links)
+	}
+}
+
+func TestUserDir(t *testing.T) {
+	dir, err := UserDir("out", "alice-b_2")
+	if err != nil || dir != filepath.Join("out", UsersDir, "alice-b_2") {
+		t.Fatalf("unexpected dir %q: %v", dir, err)
+	}
+	for _, name := range []string{"", "..", "../..", "a/b", `a\b`, ".hidden", "-x"} {
+		if _, err := UserDir("out", name); err == nil {
+			t.Fatalf("%q should be rejected", name)
+		}
+	}
+}
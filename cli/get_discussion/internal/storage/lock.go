@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LockFile is the advisory lock a run holds on its output directory.
+const LockFile = ".get_discussion.lock"
+
+// ErrLocked is returned by LockDir when another live run holds the lock.
+var ErrLocked = errors.New("output directory is locked")
+
+// Lock is a held output directory lock.
+type Lock struct {
+	path  string
+	owner string
+}
+
+// LockDir takes the lock of dir, creating dir if needed. A lock left by a
+// process that is no longer running on this host is taken over; any other
+// lock fails with ErrLocked, naming its holder.
+func LockDir(dir string) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, LockFile)
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%d %s %s\n", os.Getpid(), host, time.Now().UTC().Format(time.RFC3339Nano))
+	for attempt := 0; attempt < 3; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &Lock{path: path, owner: owner}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		holder, stale := readLock(path, host)
+		if !stale {
+			return nil, fmt.Errorf("%w by %s (remove %s if that run is gone)", ErrLocked, holder, path)
+		}
+		if err := removeStale(path, host); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: could not take over a stale lock at %s", ErrLocked, path)
+}
+
+// removeStale removes the stale lock at path. Two runs may find the same
+// stale lock, and the slower one must not remove the lock the faster one
+// has taken meanwhile: the lock is first renamed away, which only one of
+// them can do, and put back if it turns out to be live.
+func removeStale(path, host string) error {
+	aside := fmt.Sprintf("%s.%d.stale", path, os.Getpid())
+	if err := os.Rename(path, aside); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if holder, stale := readLock(aside, host); !stale {
+		// Link fails if yet another run has taken the lock since.
+		err := os.Link(aside, path)
+		os.Remove(aside)
+		if err != nil {
+			return fmt.Errorf("%w by %s: its lock file was replaced while taking over a stale lock at %s", ErrLocked, holder, path)
+		}
+		return fmt.Errorf("%w by %s (remove %s if that run is gone)", ErrLocked, holder, path)
+	}
+	return os.Remove(aside)
+}
+
+// Unlock releases the lock. A lock file that is not ours anymore, because
+// it was removed by hand and taken by another run, is left alone.
+func (l *Lock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if string(data) != l.owner {
+		return fmt.Errorf("lock %s is held by another run", l.path)
+	}
+	return os.Remove(l.path)
+}
+
+// LockTree takes the lock of dir and of the directories below it that runs
+// lock on their own: users/<name> and solutions/<competition>. Commands that
+// rewrite everything under dir, such as migrate, need all of them. On error
+// the locks taken so far are released.
+func LockTree(dir string) ([]*Lock, error) {
+	lock, err := LockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	locks := []*Lock{lock}
+	for _, parent := range []string{SolutionsDir, UsersDir} {
+		entries, err := os.ReadDir(filepath.Join(dir, parent))
+		if err != nil && !os.IsNotExist(err) {
+			UnlockAll(locks)
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			sub, err := LockDir(filepath.Join(dir, parent, e.Name()))
+			if err != nil {
+				UnlockAll(locks)
+				return nil, err
+			}
+			locks = append(locks, sub)
+		}
+	}
+	return locks, nil
+}
+
+// UnlockAll releases locks in reverse order.
+func UnlockAll(locks []*Lock) {
+	for i := len(locks) - 1; i >= 0; i-- {
+		locks[i].Unlock()
+	}
+}
+
+// readLock describes the holder of a lock file and reports whether it is
+// stale: held by a process of this host that is not running anymore.
+func readLock(path, host string) (holder string, stale bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "an unknown process", false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return "an unknown process", false
+	}
+	holder = fmt.Sprintf("pid %s on %s since %s", fields[0], fields[1], fields[2])
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || fields[1] != host {
+		return holder, false
+	}
+	return holder, !processAlive(pid)
+}
+
+func processAlive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	lock, err := LockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LockDir(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second lock: %v, want ErrLocked", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = LockDir(dir)
+	if err != nil {
+		t.Fatalf("lock after unlock: %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestLockDirStale(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, LockFile)
+	host, _ := os.Hostname()
+
+	// A pid far above pid_max is never running.
+	os.WriteFile(path, []byte(fmt.Sprintf("99999999 %s 2024-01-01T00:00:00Z\n", host)), 0o644)
+	lock, err := LockDir(dir)
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	lock.Unlock()
+
+	// Locks of other hosts are never taken over.
+	os.WriteFile(path, []byte("99999999 elsewhere 2024-01-01T00:00:00Z\n"), 0o644)
+	if _, err := LockDir(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("lock of another host: %v, want ErrLocked", err)
+	}
+}
+
+func TestLockDirStaleTakeoverKeepsLiveLock(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, LockFile)
+	host, _ := os.Hostname()
+
+	// Another run took over the stale lock between our check and removal.
+	os.WriteFile(path, []byte(fmt.Sprintf("%d %s 2024-01-01T00:00:00Z\n", os.Getppid(), host)), 0o644)
+	if err := removeStale(path, host); !errors.Is(err, ErrLocked) {
+		t.Fatalf("live lock removed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("live lock should be put back: %v", err)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Fatalf("leftover files: %v", matches)
+	}
+}
+
+func TestUnlockLeavesOtherLocks(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := LockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, LockFile)
+	os.WriteFile(path, []byte("1 elsewhere 2024-01-01T00:00:00Z\n"), 0o644)
+	if err := lock.Unlock(); err == nil {
+		t.Fatalf("unlocking a lock of another run should fail")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("lock of another run removed: %v", err)
+	}
+}
+
+func TestLockTree(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, UsersDir, "alice")
+	userLock, err := LockDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LockTree(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("tree lock with a locked user directory: %v, want ErrLocked", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFile)); !os.IsNotExist(err) {
+		t.Fatalf("top lock should be released on failure")
+	}
+	userLock.Unlock()
+
+	os.MkdirAll(filepath.Join(dir, SolutionsDir, "titanic"), 0o755)
+	locks, err := LockTree(dir)
+	if err != nil || len(locks) != 3 {
+		t.Fatalf("expected 3 locks, got %d: %v", len(locks), err)
+	}
+	if _, err := LockDir(filepath.Join(dir, SolutionsDir, "titanic")); !errors.Is(err, ErrLocked) {
+		t.Fatalf("solutions directory should be locked: %v", err)
+	}
+	UnlockAll(locks)
+	if _, err := LockDir(user); err != nil {
+		t.Fatalf("user directory should be released: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/frontmatter"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// ManifestFile is the machine-readable index of an output directory.
+const ManifestFile = "manifest.json"
+
+// ManifestVersion is the format version of manifest.json.
+const ManifestVersion = 1
+
+// ManifestEntry records a saved discussion and how it was fetched. Entries
+// of files saved before the manifest existed have no source, fetch time or
+// warnings.
+type ManifestEntry struct {
+	TopicID int    `json:"topic_id,omitempty"`
+	Link    string `json:"link"`
+	// Path is relative to the output directory, slash-separated.
+	Path string `json:"path"`
+	// ContentHash is the SHA-256 of the file as the tool last wrote it. It
+	// is empty for threads exported in other formats only, whose Path is
+	// where their Markdown would be and which are listed so that their
+	// files move with them on renames.
+	ContentHash string     `json:"content_hash"`
+	Source      string     `json:"source,omitempty"`
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`
+	// APIMessages is the message count the topic metadata reports, and
+	// RenderedMessages the number of messages in the file.
+	APIMessages      *int     `json:"api_messages,omitempty"`
+	RenderedMessages int      `json:"rendered_messages"`
+	Warnings         []string `json:"warnings,omitempty"`
+}
+
+// Manifest is the index of the discussions saved in an output directory,
+// kept in its manifest.json.
+type Manifest struct {
+	dir     string
+	entries map[string]*ManifestEntry // by canonical link
+}
+
+type manifestFile struct {
+	Version     int              `json:"version"`
+	Discussions []*ManifestEntry `json:"discussions"`
+}
+
+// LoadManifest reads the manifest of outputDir. Its entries are trusted as
+// they are, so startup does not touch the saved files.
+//
+// Without a usable manifest, or with rescan, the directory is walked to
+// bring it up to date: entries of files that are gone are dropped, and
+// Markdown files it does not list, in any subdirectory but SolutionsDir and
+// UsersDir, are read and added. Only those files are parsed.
+func LoadManifest(outputDir string, rescan bool) *Manifest {
+	m := &Manifest{dir: outputDir, entries: map[string]*ManifestEntry{}}
+	var mf manifestFile
+	data, err := os.ReadFile(filepath.Join(outputDir, ManifestFile))
+	switch {
+	case os.IsNotExist(err):
+		rescan = true
+	case err != nil:
+		log.Printf("[warn] Ignoring manifest: %v", err)
+		rescan = true
+	default:
+		if err := json.Unmarshal(data, &mf); err != nil {
+			log.Printf("[warn] Ignoring manifest %s: %v", filepath.Join(outputDir, ManifestFile), err)
+			mf.Discussions, rescan = nil, true
+		} else if mf.Version > ManifestVersion {
+			log.Printf("[warn] Ignoring manifest %s: version %d is newer than this tool", filepath.Join(outputDir, ManifestFile), mf.Version)
+			mf.Discussions, rescan = nil, true
+		}
+	}
+
+	listed := map[string]bool{}
+	for _, e := range mf.Discussions {
+		if e == nil || e.Link == "" || e.Path == "" {
+			continue
+		}
+		if rescan && !threadExists(m.abs(e.Path)) {
+			continue
+		}
+		e.Link = urlutil.CanonicalizeURL(e.Link)
+		m.entries[e.Link] = e
+		listed[e.Path] = true
+	}
+	if !rescan {
+		return m
+	}
+
+	filepath.WalkDir(outputDir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if e.IsDir() {
+			if path == filepath.Join(outputDir, SolutionsDir) || path == filepath.Join(outputDir, UsersDir) || e.Name() == "snippets" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(e.Name(), ".md") || listed[m.rel(path)] {
+			return nil
+		}
+		if entry := entryFromFile(path); entry != nil {
+			if _, dup := m.entries[entry.Link]; !dup {
+				entry.Path = m.rel(path)
+				m.entries[entry.Link] = entry
+			}
+		}
+		return nil
+	})
+	return m
+}
+
+// entryFromFile builds the entry of a saved file the manifest does not
+// list, or returns nil when it has no link.
+func entryFromFile(path string) *ManifestEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	meta, body, err := frontmatter.Parse(string(data))
+	if err != nil {
+		log.Printf("[warn] %s: %v", path, err)
+	}
+	link := meta.String("link")
+	if link == "" {
+		return nil
+	}
+	entry := &ManifestEntry{
+		Link:             urlutil.CanonicalizeURL(link),
+		ContentHash:      hashFile(data),
+		RenderedMessages: len(splitAnnotated(strings.TrimSpace(body), lastHash(path)).sections),
+	}
+	entry.TopicID, _ = urlutil.ExtractTopicID(link)
+	if n, ok := typedCount(meta.String("comments")).(int); ok {
+		entry.APIMessages = &n
+	}
+	return entry
+}
+
+// Links maps the canonical link of every listed discussion to its file.
+func (m *Manifest) Links() map[string]string {
+	links := make(map[string]string, len(m.entries))
+	for link, e := range m.entries {
+		links[link] = m.abs(e.Path)
+	}
+	return links
+}
+
+// Entries returns the listed discussions ordered by path.
+func (m *Manifest) Entries() []*ManifestEntry {
+	entries := make([]*ManifestEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// Record lists d, just saved at path, replacing its previous entry. Without
+// a file at path, when only other formats were written, the entry has no
+// content hash.
+func (m *Manifest) Record(d *discussion.Discussion, path string) error {
+	var hash string
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		hash = hashFile(data)
+	case !os.IsNotExist(err):
+		return err
+	}
+	entry := &ManifestEntry{
+		Link:             urlutil.CanonicalizeURL(d.Link),
+		Path:             m.rel(path),
+		ContentHash:      hash,
+		Source:           d.Source,
+		RenderedMessages: renderedMessages(d),
+		Warnings:         d.Warnings,
+	}
+	entry.TopicID, _ = urlutil.ExtractTopicID(d.Link)
+	if !d.FetchedAt.IsZero() {
+		t := d.FetchedAt.UTC()
+		entry.FetchedAt = &t
+	}
+	if n, ok := typedCount(d.Comments).(int); ok {
+		entry.APIMessages = &n
+	}
+	m.entries[entry.Link] = entry
+	return nil
+}
+
+// Refresh updates the content hash of the entry of the file at path after
+// it was rewritten outside Record, as migrate does. Unlisted files are
+// ignored.
+func (m *Manifest) Refresh(path string) error {
+	rel := m.rel(path)
+	for _, e := range m.entries {
+		if e.Path != rel {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		e.ContentHash = hashFile(data)
+	}
+	return nil
+}
+
+// ManifestDir returns the directory whose manifest lists path, a file below
+// outputDir: its users/<name> or solutions/<competition> tree, or outputDir.
+func ManifestDir(outputDir, path string) string {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		return outputDir
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) > 2 && (parts[0] == SolutionsDir || parts[0] == UsersDir) {
+		return filepath.Join(outputDir, parts[0], parts[1])
+	}
+	return outputDir
+}
+
+// Save writes manifest.json.
+func (m *Manifest) Save(sync bool) error {
+	data, err := json.MarshalIndent(manifestFile{Version: ManifestVersion, Discussions: m.Entries()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(m.dir, ManifestFile), append(data, '\n'), 0o644, sync)
+}
+
+func (m *Manifest) rel(path string) string {
+	rel, err := filepath.Rel(m.dir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (m *Manifest) abs(rel string) string {
+	return filepath.Join(m.dir, filepath.FromSlash(rel))
+}
+
+// renderedMessages counts the messages of d as written to its file.
+func renderedMessages(d *discussion.Discussion) int {
+	if len(d.Messages) > 0 {
+		return len(d.Messages)
+	}
+	content := strings.TrimSpace(d.ContentMD)
+	if content == "" {
+		return 0
+	}
+	return len(splitSections(content))
+}
+
+func hashFile(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func TestManifestRecordAndReload(t *testing.T) {
+	dir := t.TempDir()
+	m := LoadManifest(dir, false)
+	d := thread(
+		discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true},
+		discussion.Message{ID: 2, Author: "bob", Body: "Reply"},
+	)
+	d.Comments = "3"
+	d.Source = "api"
+	d.FetchedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	d.Warnings = []string{"html failed: boom"}
+	path, err := SaveDiscussion(d, dir, m.Links(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Record(d, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(false); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, ManifestFile))
+	var mf manifestFile
+	if err := json.Unmarshal(data, &mf); err != nil || mf.Version != ManifestVersion || len(mf.Discussions) != 1 {
+		t.Fatalf("unexpected manifest: %s (%v)", data, err)
+	}
+	e := mf.Discussions[0]
+	if e.TopicID != 1 || e.Path != filepath.Base(path) || e.Source != "api" || !e.FetchedAt.Equal(d.FetchedAt) ||
+		e.APIMessages == nil || *e.APIMessages != 3 || e.RenderedMessages != 2 || len(e.Warnings) != 1 || len(e.ContentHash) != 64 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	// A listed file is not parsed again: a link edited by hand is not seen.
+	content, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(content), "discussion/1", "discussion/9", 1)), 0o644)
+	if links := LoadExistingLinks(dir); links[d.Link] != path {
+		t.Errorf("links = %v, want %s from the manifest", links, path)
+	}
+}
+
+func TestManifestCatchesUp(t *testing.T) {
+	dir := t.TempDir()
+	legacy := "---\ntitle: Old\nlink: https://www.kaggle.com/competitions/x/discussion/7\ncomments: \"5\"\n---\n\nBody\n"
+	os.MkdirAll(filepath.Join(dir, "2024"), 0o755)
+	os.WriteFile(filepath.Join(dir, "2024", "old.md"), []byte(legacy), 0o644)
+	stale := `{"version": 1, "discussions": [{"link": "https://www.kaggle.com/discussion/8", "path": "gone.md", "content_hash": "x", "rendered_messages": 1}]}`
+	os.WriteFile(filepath.Join(dir, ManifestFile), []byte(stale), 0o644)
+
+	entries := LoadManifest(dir, true).Entries()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v, want only the unlisted file", entries)
+	}
+	e := entries[0]
+	if e.Path != "2024/old.md" || e.TopicID != 7 || e.APIMessages == nil || *e.APIMessages != 5 || e.RenderedMessages != 1 || e.Source != "" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	os.WriteFile(filepath.Join(dir, ManifestFile), []byte("{"), 0o644)
+	if links := LoadExistingLinks(dir); len(links) != 1 {
+		t.Errorf("broken manifest: links = %v, want a full scan", links)
+	}
+}
+
+func TestManifestRescanIsOptIn(t *testing.T) {
+	dir := t.TempDir()
+	listed := `{"version": 1, "discussions": [{"link": "https://www.kaggle.com/discussion/8", "path": "gone.md", "content_hash": "x", "rendered_messages": 1}]}`
+	os.WriteFile(filepath.Join(dir, ManifestFile), []byte(listed), 0o644)
+	os.WriteFile(filepath.Join(dir, "new.md"), []byte("---\nlink: https://www.kaggle.com/discussion/9\n---\n\nBody\n"), 0o644)
+
+	if links := LoadManifest(dir, false).Links(); len(links) != 1 || links["https://www.kaggle.com/discussion/8"] == "" {
+		t.Errorf("without rescan the manifest should be trusted: %v", links)
+	}
+	if links := LoadManifest(dir, true).Links(); len(links) != 1 || links["https://www.kaggle.com/discussion/9"] == "" {
+		t.Errorf("rescan should drop gone files and add new ones: %v", links)
+	}
+}
+
+func TestManifestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, UsersDir, "alice")
+	m := LoadManifest(user, false)
+	d := thread(discussion.Message{ID: 1, Body: "Main", IsMain: true})
+	path, err := SaveDiscussion(d, user, map[string]string{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ManifestDir(dir, path); got != user {
+		t.Fatalf("ManifestDir = %s, want %s", got, user)
+	}
+	if got := ManifestDir(dir, filepath.Join(dir, "2024", "x.md")); got != dir {
+		t.Fatalf("ManifestDir = %s, want %s", got, dir)
+	}
+	if err := m.Record(d, path); err != nil {
+		t.Fatal(err)
+	}
+	before := m.Entries()[0].ContentHash
+
+	os.WriteFile(path, []byte("---\nlink: "+d.Link+"\n---\n\nMigrated\n"), 0o644)
+	if err := m.Refresh(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if got := m.Entries()[0].ContentHash; got == before || got != hashFile(data) {
+		t.Errorf("content hash not refreshed: %s", got)
+	}
+}
+
+func TestManifestListsExportedThreads(t *testing.T) {
+	dir := t.TempDir()
+	m := LoadManifest(dir, false)
+	d := thread(discussion.Message{ID: 1, Body: "Main", IsMain: true})
+	path := ThreadPath(d, dir, map[string]string{}, Options{})
+	if err := os.WriteFile(strings.TrimSuffix(path, ".md")+".json", []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Record(d, path); err != nil {
+		t.Fatalf("thread without Markdown: %v", err)
+	}
+	if err := m.Save(false); err != nil {
+		t.Fatal(err)
+	}
+	entries := LoadManifest(dir, true).Entries()
+	if len(entries) != 1 || entries[0].ContentHash != "" || entries[0].Path != filepath.Base(path) {
+		t.Fatalf("exported thread should stay listed: %+v", entries)
+	}
+}
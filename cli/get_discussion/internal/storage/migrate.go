@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/frontmatter"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// SchemaVersion is the front matter layout SaveDiscussion writes. Files
+// without a schema_version key predate versioning and are version 1, where
+// every value was a string.
+const SchemaVersion = 2
+
+// migrations[i] upgrades front matter from version i+1 to i+2. Each returns
+// a description of every change it made.
+var migrations = []func(m *frontmatter.Map) []string{
+	migrateV1,
+}
+
+// frontMatterOrder is the order buildFrontMatter writes its keys in.
+// Migrated files are reordered the same way, user keys last.
+var frontMatterOrder = []string{"title", "link", "author", "comments", "published_date", "topic_id", "schema_version"}
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123,
+	time.RFC1123Z,
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// ParseDate reads the date formats found in saved files and returns them
+// in UTC.
+func ParseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Migration is the upgrade of one saved file to SchemaVersion. Apply writes
+// it.
+type Migration struct {
+	Path    string
+	From    int
+	To      int
+	Changes []string
+	// Old and New are the full file contents before and after.
+	Old string
+	New string
+}
+
+// MigrateFile computes the upgrade of a saved discussion to SchemaVersion
+// without writing anything. It returns nil for files that are up to date or
+// are not saved discussions, such as indexes without a link.
+func MigrateFile(path string) (*Migration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	yaml, body, ok := frontmatter.Split(string(data))
+	if !ok {
+		return nil, nil
+	}
+	meta, err := frontmatter.Decode(yaml)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if meta.String("link") == "" {
+		return nil, nil
+	}
+
+	from := 1
+	if v, ok := meta.Get("schema_version"); ok {
+		n, isInt := v.(int)
+		if !isInt || n < 1 {
+			return nil, fmt.Errorf("%s: invalid schema_version %q", path, meta.String("schema_version"))
+		}
+		from = n
+	}
+	if from > SchemaVersion {
+		return nil, fmt.Errorf("%s: schema_version %d is newer than this tool (%d)", path, from, SchemaVersion)
+	}
+	if from == SchemaVersion {
+		return nil, nil
+	}
+
+	mg := &Migration{Path: path, From: from, To: SchemaVersion, Old: string(data)}
+	for v := from; v < SchemaVersion; v++ {
+		mg.Changes = append(mg.Changes, migrations[v-1](&meta)...)
+	}
+	meta.Set("schema_version", SchemaVersion)
+	mg.New = frontmatter.Join(reorder(meta), body)
+	return mg, nil
+}
+
+// Apply writes the migrated file, first copying the original to
+// "<path>.bak" when backup is set.
+func (mg *Migration) Apply(backup bool) error {
+	if backup {
+		if err := WriteFileAtomic(mg.Path+".bak", []byte(mg.Old), 0o644, false); err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomic(mg.Path, []byte(mg.New), 0o644, false)
+}
+
+func reorder(m frontmatter.Map) frontmatter.Map {
+	var out frontmatter.Map
+	for _, k := range frontMatterOrder {
+		if v, ok := m.Get(k); ok {
+			out.Set(k, v)
+		}
+	}
+	for _, k := range m.Keys() {
+		if _, done := out.Get(k); !done {
+			v, _ := m.Get(k)
+			out.Set(k, v)
+		}
+	}
+	return out
+}
+
+// migrateV1 types the values version 1 wrote as strings and backfills
+// topic_id.
+func migrateV1(m *frontmatter.Map) []string {
+	var changes []string
+	if v, ok := m.Get("comments"); ok {
+		if s, isString := v.(string); isString {
+			if n, ok := typedCount(s).(int); ok {
+				m.Set("comments", n)
+				changes = append(changes, "comments to int")
+			}
+		}
+	}
+	if v, ok := m.Get("published_date"); ok {
+		var t time.Time
+		var parsed bool
+		switch val := v.(type) {
+		case string:
+			t, parsed = ParseDate(val)
+		case time.Time:
+			t, parsed = val.UTC(), val.Location() != time.UTC
+		}
+		if parsed {
+			m.Set("published_date", t)
+			changes = append(changes, "published_date to RFC 3339")
+		}
+	}
+	if _, ok := m.Get("topic_id"); !ok {
+		if id, ok := urlutil.ExtractTopicID(m.String("link")); ok {
+			m.Set("topic_id", id)
+			changes = append(changes, "topic_id from link")
+		}
+	}
+	for _, k := range []string{"user_comment_ids", "code_links", "notebook_links"} {
+		if s, ok := stringValue(m, k); ok {
+			m.Set(k, splitList(s, k == "user_comment_ids"))
+			changes = append(changes, k+" to list")
+		}
+	}
+	if s, ok := stringValue(m, "placement"); ok {
+		if n, err := strconv.Atoi(s); err == nil {
+			m.Set("placement", n)
+			changes = append(changes, "placement to int")
+		}
+	}
+	if s, ok := stringValue(m, "user_started_topic"); ok {
+		if b, err := strconv.ParseBool(s); err == nil {
+			m.Set("user_started_topic", b)
+			changes = append(changes, "user_started_topic to bool")
+		}
+	}
+	return changes
+}
+
+func stringValue(m *frontmatter.Map, key string) (string, bool) {
+	v, ok := m.Get(key)
+	s, isString := v.(string)
+	return s, ok && isString
+}
+
+// splitList splits a ", " joined version 1 list, into ints when asked and
+// every item is one.
+func splitList(s string, ints bool) []any {
+	items := []any{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(part); err == nil && ints {
+			items = append(items, n)
+			continue
+		}
+		items = append(items, part)
+	}
+	return items
+}
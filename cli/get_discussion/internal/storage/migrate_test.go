@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func TestMigrateFile(t *testing.T) {
+	legacy := "---\ntags: [cv]\ntitle: Old\nlink: https://www.kaggle.com/competitions/x/discussion/123\nauthor: bob\n" +
+		"comments: 1,204\npublished_date: 2024-03-01 09:30:00\nuser_comment_ids: 2, 3\nuser_started_topic: true\n---\n\nBody\n\n" +
+		NotesStart + "\nmine\n" + NotesEnd + "\n"
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old.md")
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mg, err := MigrateFile(path)
+	if err != nil || mg == nil {
+		t.Fatalf("expected a migration: %v", err)
+	}
+	if mg.From != 1 || mg.To != SchemaVersion {
+		t.Fatalf("unexpected versions: %d -> %d", mg.From, mg.To)
+	}
+	if err := mg.Apply(true); err != nil {
+		t.Fatal(err)
+	}
+	if backup, _ := os.ReadFile(path + ".bak"); string(backup) != legacy {
+		t.Fatalf("backup differs from the original")
+	}
+
+	meta, body, err := ReadDiscussionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"title", "link", "author", "comments", "published_date", "topic_id", "schema_version", "tags", "user_comment_ids", "user_started_topic"}
+	if !reflect.DeepEqual(meta.Keys(), want) {
+		t.Fatalf("unexpected key order: %v", meta.Keys())
+	}
+	checks := map[string]any{
+		"comments":           1204,
+		"published_date":     time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		"topic_id":           123,
+		"schema_version":     SchemaVersion,
+		"tags":               []any{"cv"},
+		"user_comment_ids":   []any{2, 3},
+		"user_started_topic": true,
+	}
+	for k, w := range checks {
+		if got, _ := meta.Get(k); !reflect.DeepEqual(got, w) {
+			t.Errorf("%s = %#v, want %#v", k, got, w)
+		}
+	}
+	if !strings.HasPrefix(body, "Body") || !strings.Contains(body, "mine") {
+		t.Fatalf("body changed: %q", body)
+	}
+
+	if again, err := MigrateFile(path); err != nil || again != nil {
+		t.Fatalf("migrated file should be up to date: %v %v", again, err)
+	}
+}
+
+func TestMigrateFileSkipsAndRejects(t *testing.T) {
+	dir := t.TempDir()
+	index := filepath.Join(dir, "index.md")
+	newer := filepath.Join(dir, "newer.md")
+	os.WriteFile(index, []byte("# Index\n"), 0o644)
+	os.WriteFile(newer, []byte("---\nlink: https://x/discussion/1\nschema_version: 99\n---\n"), 0o644)
+
+	if mg, err := MigrateFile(index); mg != nil || err != nil {
+		t.Fatalf("index should be skipped: %v %v", mg, err)
+	}
+	if _, err := MigrateFile(newer); err == nil {
+		t.Fatalf("expected an error for a newer schema version")
+	}
+}
+
+func TestSavedFilesAreCurrent(t *testing.T) {
+	d := &discussion.Discussion{Title: "T", Link: "https://www.kaggle.com/competitions/x/discussion/42", ContentMD: "Body"}
+	path, err := SaveDiscussion(d, t.TempDir(), map[string]string{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mg, err := MigrateFile(path); mg != nil || err != nil {
+		t.Fatalf("fresh file should not need migration: %+v %v", mg, err)
+	}
+	meta, _, _ := ReadDiscussionFile(path)
+	if v, _ := meta.Get("topic_id"); v != 42 {
+		t.Fatalf("topic_id not written: %#v", v)
+	}
+}
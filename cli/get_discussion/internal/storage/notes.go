@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/frontmatter"
+)
+
+// Team notes are the parts of a saved file that we wrote rather than Kaggle:
+// blocks between notes markers, which stay attached to the message they were
+// written under, and a trailing "## Team Notes" section. Both survive
+// re-downloads, as do front matter keys we did not generate, such as tags or
+// status.
+const (
+	NotesStart   = "<!-- team-notes:start -->"
+	NotesEnd     = "<!-- team-notes:end -->"
+	NotesHeading = "## Team Notes"
+)
+
+var (
+	notesBlockRe   = regexp.MustCompile(`(?s)\n*` + regexp.QuoteMeta(NotesStart) + `.*?` + regexp.QuoteMeta(NotesEnd))
+	notesHeadingRe = regexp.MustCompile(`(?m)^` + NotesHeading + `[ \t]*$`)
+)
+
+// generatedKeys are the front matter keys buildFrontMatter always writes.
+var generatedKeys = map[string]bool{
+	"title": true, "link": true, "author": true, "comments": true, "published_date": true,
+	"topic_id": true, "schema_version": true,
+}
+
+// extraKeys are the Extra keys set by the commands that save threads: --user
+// archiving and harvest-solutions. They describe one download, so a later
+// download that does not set them drops them rather than keeping them as
+// user keys.
+var extraKeys = map[string]bool{
+	"archived_user": true, "user_comment_ids": true, "user_started_topic": true,
+	"competition": true, "team": true, "placement": true, "code_links": true, "notebook_links": true,
+}
+
+// annotatedBody is a saved body split into upstream sections and our notes.
+type annotatedBody struct {
+	sections []string
+	// blocks holds the notes blocks of each section, by section index.
+	blocks   map[int][]string
+	trailing string
+}
+
+// splitAnnotated separates the team notes of a saved body from the upstream
+// sections of the thread. lastHash is the hash of the last indexed message,
+// or "" without a comment index; see trailingNotes.
+func splitAnnotated(body, lastHash string) annotatedBody {
+	var a annotatedBody
+	if at := trailingNotes(body, lastHash); at >= 0 {
+		a.trailing = strings.TrimSpace(body[at:])
+		body = strings.TrimSpace(body[:at])
+	}
+	a.sections = splitSections(body)
+	a.blocks = map[int][]string{}
+	for i, s := range a.sections {
+		for _, b := range notesBlockRe.FindAllString(s, -1) {
+			a.blocks[i] = append(a.blocks[i], strings.TrimSpace(b))
+		}
+		a.sections[i] = strings.TrimSpace(notesBlockRe.ReplaceAllString(s, ""))
+	}
+	return a
+}
+
+// trailingNotes returns the offset of the trailing notes heading in body, or
+// -1. Upstream posts may contain the heading themselves, so headings inside
+// fenced code or before the last comment are skipped, and with lastHash the
+// heading must follow the text of the last message. Otherwise the last
+// candidate wins.
+func trailingNotes(body, lastHash string) int {
+	lastComment := strings.LastIndex(body, discussion.SectionSeparator+discussion.CommentHeading)
+	var candidates []int
+	for _, loc := range notesHeadingRe.FindAllStringIndex(body, -1) {
+		if loc[0] > lastComment && !inFence(body[:loc[0]]) {
+			candidates = append(candidates, loc[0])
+		}
+	}
+	if len(candidates) == 0 {
+		return -1
+	}
+	if lastHash != "" {
+		endsWith := func(text string) bool {
+			sections := splitSections(strings.TrimSpace(text))
+			last := notesBlockRe.ReplaceAllString(sections[len(sections)-1], "")
+			return hashBody(sectionBody(last)) == lastHash
+		}
+		for _, at := range candidates {
+			if endsWith(body[:at]) {
+				return at
+			}
+		}
+		if endsWith(body) {
+			return -1
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// inFence reports whether text ends inside a fenced code block.
+func inFence(text string) bool {
+	open := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			open = !open
+		}
+	}
+	return open
+}
+
+// sectionBody strips the comment heading or deleted mark of a section.
+func sectionBody(section string) string {
+	if strings.HasPrefix(section, discussion.CommentHeading) {
+		_, section, _ = strings.Cut(section, "\n")
+	}
+	return strings.TrimPrefix(strings.TrimSpace(section), deletedPost)
+}
+
+// lastHash returns the hash of the last message in the comment index of
+// the discussion at path, or "" without one.
+func lastHash(path string) string {
+	idx, err := readCommentIndex(path)
+	if err != nil {
+		return ""
+	}
+	return idx.lastHash()
+}
+
+func (idx CommentIndex) lastHash() string {
+	if len(idx.Comments) == 0 {
+		return ""
+	}
+	return idx.Comments[len(idx.Comments)-1].Hash
+}
+
+// join renders the sections with their notes blocks and the trailing section.
+func (a annotatedBody) join() string {
+	parts := make([]string, len(a.sections))
+	for i, s := range a.sections {
+		parts[i] = strings.Join(append([]string{s}, a.blocks[i]...), "\n\n")
+	}
+	out := strings.Join(parts, discussion.SectionSeparator)
+	if a.trailing != "" {
+		out += "\n\n" + a.trailing
+	}
+	return strings.TrimSpace(out)
+}
+
+// orphan moves notes whose message is gone to the trailing section.
+func (a *annotatedBody) orphan(blocks []string) {
+	if a.trailing == "" {
+		a.trailing = NotesHeading
+	}
+	a.trailing += "\n\n" + strings.Join(blocks, "\n\n")
+}
+
+// userFrontMatter returns the front matter keys of an existing file that d
+// does not generate.
+func userFrontMatter(meta frontmatter.Map, d *discussion.Discussion) map[string]any {
+	keys := map[string]any{}
+	for _, k := range meta.Keys() {
+		if _, ok := d.Extra[k]; ok || generatedKeys[k] || extraKeys[k] {
+			continue
+		}
+		keys[k], _ = meta.Get(k)
+	}
+	return keys
+}
+
+// frontMatterWith renders the front matter of d plus the user keys.
+func frontMatterWith(d *discussion.Discussion, user map[string]any) string {
+	if len(user) == 0 {
+		return buildFrontMatter(d)
+	}
+	merged := *d
+	merged.Extra = map[string]any{}
+	for k, v := range user {
+		merged.Extra[k] = v
+	}
+	for k, v := range d.Extra {
+		merged.Extra[k] = v
+	}
+	return buildFrontMatter(&merged)
+}
+
+// messageSections renders d as one section per message, or as a single
+// section when it has no structured messages.
+func messageSections(d *discussion.Discussion) ([]string, []int) {
+	if len(d.Messages) == 0 {
+		return []string{strings.TrimSpace(d.ContentMD)}, nil
+	}
+	sections := make([]string, len(d.Messages))
+	ids := make([]int, len(d.Messages))
+	for i, m := range d.Messages {
+		sections[i] = discussion.RenderSection(m, i == 0)
+		ids[i] = m.ID
+	}
+	return sections, ids
+}
+
+// renderOver renders d for the file at path, keeping its team notes and user
+// front matter keys. conflicts lists the annotated messages whose upstream
+// text changed. A missing file yields the plain rendering; a file whose front
+// matter cannot be parsed is an error, since rendering over it would drop the
+// user's keys.
+func renderOver(d *discussion.Discussion, path string) (content string, conflicts []string, err error) {
+	if _, err := os.Stat(path); err != nil {
+		return buildFrontMatter(d) + strings.TrimSpace(d.ContentMD) + "\n", nil, nil
+	}
+	meta, body, err := ReadDiscussionFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("refusing to overwrite: %w", err)
+	}
+	old := splitAnnotated(body, lastHash(path))
+	sections, ids := messageSections(d)
+
+	// Map old sections to new ones by message ID when the comment index
+	// matches the file, otherwise by position.
+	var oldIDs []int
+	if idx, err := readCommentIndex(path); err == nil && len(idx.Comments) == len(old.sections) && ids != nil {
+		for _, c := range idx.Comments {
+			oldIDs = append(oldIDs, c.ID)
+		}
+	}
+	oldID := func(i int) int {
+		if oldIDs == nil {
+			return 0
+		}
+		return oldIDs[i]
+	}
+	target := func(i int) int {
+		if oldIDs == nil {
+			if i < len(sections) {
+				return i
+			}
+			return -1
+		}
+		for j, id := range ids {
+			if id == oldIDs[i] {
+				return j
+			}
+		}
+		return -1
+	}
+
+	fresh := annotatedBody{sections: sections, blocks: map[int][]string{}, trailing: old.trailing}
+	for i := 0; i < len(old.sections); i++ {
+		blocks, ok := old.blocks[i]
+		if !ok {
+			continue
+		}
+		j := target(i)
+		if j < 0 {
+			fresh.orphan(blocks)
+			conflicts = append(conflicts, fmt.Sprintf("%s is gone upstream; its notes moved to %q", sectionName(i, oldID(i)), NotesHeading))
+			continue
+		}
+		if old.sections[i] != sections[j] {
+			conflicts = append(conflicts, fmt.Sprintf("upstream text of %s changed under team notes", sectionName(i, oldID(i))))
+		}
+		fresh.blocks[j] = append(fresh.blocks[j], blocks...)
+	}
+	return frontMatterWith(d, userFrontMatter(meta, d)) + fresh.join() + "\n", conflicts, nil
+}
+
+// sectionName names the i-th section of a thread; id is 0 when unknown.
+func sectionName(i, id int) string {
+	switch {
+	case i == 0:
+		return "the opening post"
+	case id != 0:
+		return fmt.Sprintf("comment #%d", id)
+	}
+	return fmt.Sprintf("comment %d", i)
+}
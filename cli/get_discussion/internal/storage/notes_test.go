@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func TestSaveDiscussionKeepsNotes(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	bob := discussion.Message{ID: 2, Author: "bob", Body: "Use GroupKFold"}
+	carol := discussion.Message{ID: 3, Author: "carol", Body: "Agreed"}
+	path, err := SaveDiscussion(thread(main, bob, carol), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	annotated := strings.Replace(string(data), "\n---\n\n", "\ntags: [cv, leak]\nstatus: read\n---\n\n", 1)
+	annotated = strings.Replace(annotated, "Use GroupKFold", "Use GroupKFold\n\n"+NotesStart+"\nTried it: +0.002 CV\n"+NotesEnd, 1)
+	annotated = strings.Replace(annotated, "Agreed", "Agreed\n\n"+NotesStart+"\nAsk carol\n"+NotesEnd, 1)
+	annotated += "\n" + NotesHeading + "\n\nOverall: worth trying.\n"
+	os.WriteFile(path, []byte(annotated), 0o644)
+
+	bob.Body = "Use StratifiedGroupKFold"
+	dave := discussion.Message{ID: 4, Author: "dave", Body: "New"}
+	_, conflicts, err := saveDiscussion(thread(main, bob, dave), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 2 || !strings.Contains(conflicts[0], "comment #2 changed") || !strings.Contains(conflicts[1], "comment #3 is gone") {
+		t.Fatalf("unexpected conflicts: %v", conflicts)
+	}
+
+	meta, body, _ := ReadDiscussionFile(path)
+	if tags, _ := meta.Get("tags"); !reflect.DeepEqual(tags, []any{"cv", "leak"}) || meta.String("status") != "read" {
+		t.Fatalf("user front matter lost: %v", meta)
+	}
+	want := "Main\n\n---\n\n## Comment by bob\n\nUse StratifiedGroupKFold\n\n" + NotesStart + "\nTried it: +0.002 CV\n" + NotesEnd +
+		"\n\n---\n\n## Comment by dave\n\nNew\n\n" + NotesHeading + "\n\nOverall: worth trying.\n\n" + NotesStart + "\nAsk carol\n" + NotesEnd
+	if body != want {
+		t.Fatalf("unexpected body:\n%s\n--- want ---\n%s", body, want)
+	}
+}
+
+func TestUpdateDiscussionKeepsNotes(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	bob := discussion.Message{ID: 2, Author: "bob", Body: "First"}
+	path, _ := SaveDiscussion(thread(main, bob), dir, links, Options{})
+
+	data, _ := os.ReadFile(path)
+	annotated := strings.Replace(string(data), "First", "First\n\n"+NotesStart+"\nmine\n"+NotesEnd, 1) + "\n" + NotesHeading + "\n\nSummary\n"
+	os.WriteFile(path, []byte(annotated), 0o644)
+
+	carol := discussion.Message{ID: 3, Author: "carol", Body: "Second"}
+	td, err := DiffDiscussion(thread(main, bob, carol), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(td.Conflicts) != 0 || td.Changes.New != 1 {
+		t.Fatalf("unexpected diff: %+v", td)
+	}
+	if !strings.HasSuffix(td.New, "First\n\n"+NotesStart+"\nmine\n"+NotesEnd+"\n\n---\n\n## Comment by carol\n\nSecond\n\n"+NotesHeading+"\n\nSummary\n") {
+		t.Fatalf("new comment should go before the team notes section:\n%s", td.New)
+	}
+
+	bob.Body = "First, edited"
+	td, err = DiffDiscussion(thread(main, bob), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(td.Conflicts) != 1 || !strings.Contains(td.New, "First, edited\n\n"+NotesStart) {
+		t.Fatalf("edited comment should keep its notes and report a conflict: %v\n%s", td.Conflicts, td.New)
+	}
+}
+
+func TestUpstreamTeamNotesHeading(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	bob := discussion.Message{ID: 2, Author: "bob", Body: "Our writeup:\n\n" + NotesHeading + "\n\nWe ensembled.\n\n```\n" + NotesHeading + "\n```"}
+	path, err := SaveDiscussion(thread(main, bob), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carol := discussion.Message{ID: 3, Author: "carol", Body: "Nice"}
+	td, err := DiffDiscussion(thread(main, bob, carol), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.Changes.Rewritten || td.Changes.New != 1 || !strings.HasSuffix(td.New, "We ensembled.\n\n```\n"+NotesHeading+"\n```\n\n---\n\n## Comment by carol\n\nNice\n") {
+		t.Fatalf("upstream heading taken for team notes: %s\n%s", td.Changes, td.New)
+	}
+
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append(data, []byte("\n"+NotesHeading+"\n\nMine\n")...), 0o644)
+	td, err = DiffDiscussion(thread(main, bob, carol), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(td.New, "## Comment by carol\n\nNice\n\n"+NotesHeading+"\n\nMine\n") {
+		t.Fatalf("team notes should follow the new comment:\n%s", td.New)
+	}
+}
+
+func TestGeneratedExtraKeysAreDropped(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	d := thread(discussion.Message{ID: 1, Body: "Main", IsMain: true})
+	d.Extra = map[string]any{"archived_user": "alice", "user_comment_ids": []int{1}, "placement": "1st"}
+	path, err := SaveDiscussion(d, dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), "\n---\n\n", "\nstatus: read\n---\n\n", 1)), 0o644)
+
+	plain := thread(discussion.Message{ID: 1, Body: "Main", IsMain: true})
+	if _, _, err := saveDiscussion(plain, dir, links, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	meta, _, _ := ReadDiscussionFile(path)
+	for _, k := range []string{"archived_user", "user_comment_ids", "placement"} {
+		if _, ok := meta.Get(k); ok {
+			t.Fatalf("generated key %s kept as a user key: %v", k, meta.Keys())
+		}
+	}
+	if meta.String("status") != "read" {
+		t.Fatalf("user key lost: %v", meta.Keys())
+	}
+}
+
+func TestMalformedFrontMatterIsNotOverwritten(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	path, err := SaveDiscussion(thread(main), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	broken := strings.Replace(string(data), "\n---\n\n", "\nstatus: read\nstatus: done\n---\n\n", 1)
+	os.WriteFile(path, []byte(broken), 0o644)
+
+	main.Body = "Main, edited"
+	if _, err := SaveDiscussion(thread(main), dir, links, Options{}); err == nil {
+		t.Fatalf("expected an error for unparseable front matter")
+	}
+	if _, _, err := UpdateDiscussion(thread(main), dir, links, Options{}); err == nil {
+		t.Fatalf("expected an error for unparseable front matter")
+	}
+	if _, err := DiffDiscussion(thread(main), dir, links, Options{}); err == nil {
+		t.Fatalf("expected an error for unparseable front matter")
+	}
+	if data, _ := os.ReadFile(path); string(data) != broken {
+		t.Fatalf("file was overwritten:\n%s", data)
+	}
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSlugBytes bounds a slug so that it, a topic ID suffix, a _N suffix and
+// the longest sibling extension (.comments.json) stay well under the
+// 255-byte file name limit of common filesystems.
+const maxSlugBytes = 120
+
+// slugifyTitle turns a title into a file name: compatibility characters are
+// normalized (see normalizeTitle), letters and digits of any script are kept
+// and lowercased, whitespace and non-ASCII punctuation become "_", and
+// ASCII punctuation is dropped. Empty results become "discussion".
+func slugifyTitle(title string) string {
+	var b strings.Builder
+	sep := false
+	for _, r := range normalizeTitle(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-':
+			if sep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			sep = false
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsMark(r):
+			// Combining marks stay with the letter before them.
+			if b.Len() > 0 && !sep {
+				b.WriteRune(r)
+			}
+		case r == '_' || unicode.IsSpace(r) || (r >= utf8.RuneSelf && (unicode.IsPunct(r) || unicode.IsSymbol(r))):
+			sep = true
+		}
+	}
+	slug := truncateSlug(strings.Trim(b.String(), "_-"), maxSlugBytes)
+	if slug == "" {
+		return "discussion"
+	}
+	return slug
+}
+
+// truncateSlug cuts s to at most limit bytes without splitting a character
+// or separating a letter from its combining marks, preferring to cut at a
+// "_" in the last third.
+func truncateSlug(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	for cut > 0 {
+		r, _ := utf8.DecodeRuneInString(s[cut:])
+		if !unicode.IsMark(r) {
+			break
+		}
+		for cut--; cut > 0 && !utf8.RuneStart(s[cut]); cut-- {
+		}
+	}
+	if i := strings.LastIndexByte(s[:cut], '_'); i > cut*2/3 {
+		cut = i
+	}
+	return strings.Trim(s[:cut], "_-")
+}
+
+// slugWithID appends the topic ID to a slug, so that a thread keeps its
+// name when another thread with the same title is saved first.
+func slugWithID(slug string, id int, ok bool) string {
+	if !ok || id == 0 {
+		return slug
+	}
+	return slug + "_" + strconv.Itoa(id)
+}
+
+// normalizeTitle applies the compatibility mappings and compositions of
+// Unicode NFKC that are common in titles: full- and half-width forms,
+// ideographic and other spaces, ligatures, super- and subscript digits,
+// circled numbers, Roman numerals, and letters followed by common combining
+// accents or kana voicing marks. It is not full NFKC: other compatibility
+// characters and combining sequences are left as they are, and full-width
+// punctuation stays full-width so that it still separates words in slugs.
+func normalizeTitle(s string) string {
+	var out []rune
+	add := func(r rune) {
+		if len(out) > 0 {
+			if composed, ok := compose(out[len(out)-1], r); ok {
+				out[len(out)-1] = composed
+				return
+			}
+		}
+		out = append(out, r)
+	}
+	for _, r := range s {
+		switch m, ok := compatMap[r]; {
+		case ok:
+			for _, c := range m {
+				add(c)
+			}
+		case r >= 0xFF01 && r <= 0xFF5E && unicode.In(r-0xFEE0, unicode.Letter, unicode.Digit):
+			add(r - 0xFEE0)
+		case r >= 0xFF61 && r <= 0xFF9D:
+			add(halfwidthKana[r-0xFF61])
+		case r >= 0x2460 && r <= 0x2473:
+			for _, c := range strconv.Itoa(int(r - 0x2460 + 1)) {
+				add(c)
+			}
+		default:
+			add(r)
+		}
+	}
+	return string(out)
+}
+
+var compatMap = map[rune]string{
+	'\u00a0': " ", '\u3000': " ", '\u2002': " ", '\u2003': " ", '\u2009': " ", '\u200a': " ", '\u202f': " ", '\u205f': " ",
+	'ﬀ': "ff", 'ﬁ': "fi", 'ﬂ': "fl", 'ﬃ': "ffi", 'ﬄ': "ffl", 'ﬅ': "st", 'ﬆ': "st", 'Ĳ': "IJ", 'ĳ': "ij",
+	'⁰': "0", '¹': "1", '²': "2", '³': "3", '⁴': "4", '⁵': "5", '⁶': "6", '⁷': "7", '⁸': "8", '⁹': "9",
+	'₀': "0", '₁': "1", '₂': "2", '₃': "3", '₄': "4", '₅': "5", '₆': "6", '₇': "7", '₈': "8", '₉': "9",
+	'Ⅰ': "I", 'Ⅱ': "II", 'Ⅲ': "III", 'Ⅳ': "IV", 'Ⅴ': "V", 'Ⅵ': "VI", 'Ⅶ': "VII", 'Ⅷ': "VIII", 'Ⅸ': "IX", 'Ⅹ': "X",
+	'ⅰ': "i", 'ⅱ': "ii", 'ⅲ': "iii", 'ⅳ': "iv", 'ⅴ': "v", 'ⅵ': "vi", 'ⅶ': "vii", 'ⅷ': "viii", 'ⅸ': "ix", 'ⅹ': "x",
+	'™': "TM", '…': "...", 'µ': "μ", 'ﾞ': "\u3099", 'ﾟ': "\u309a",
+}
+
+// halfwidthKana maps U+FF61..U+FF9D to their full-width forms; the voicing
+// marks after them are in compatMap.
+var halfwidthKana = []rune("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン")
+
+// accents lists, per combining mark, the base letters it composes with and
+// the result, as "base composed" pairs.
+var accents = map[rune]string{
+	'\u0300': "aà eè iì oò uù AÀ EÈ IÌ OÒ UÙ",
+	'\u0301': "aá eé ií oó uú yý AÁ EÉ IÍ OÓ UÚ YÝ cć nń sś zź CĆ NŃ SŚ ZŹ",
+	'\u0302': "aâ eê iî oô uû AÂ EÊ IÎ OÔ UÛ",
+	'\u0303': "aã nñ oõ AÃ NÑ OÕ",
+	'\u0308': "aä eë iï oö uü yÿ AÄ EË IÏ OÖ UÜ",
+	'\u030a': "aå AÅ uů UŮ",
+	'\u0327': "cç CÇ sş SŞ",
+	'\u030c': "cč sš zž rř eě nň CČ SŠ ZŽ RŘ EĚ NŇ",
+}
+
+var compositions = func() map[[2]rune]rune {
+	m := map[[2]rune]rune{}
+	for mark, pairs := range accents {
+		for _, pair := range strings.Fields(pairs) {
+			r := []rune(pair)
+			m[[2]rune{r[0], mark}] = r[1]
+		}
+	}
+	// Kana voicing: the voiced form follows the base, the semi-voiced
+	// form (ha row only) follows it.
+	for _, base := range []rune("かきくけこさしすせそたちつてとはひふへほカキクケコサシスセソタチツテトハヒフヘホ") {
+		m[[2]rune{base, '\u3099'}] = base + 1
+	}
+	for _, base := range []rune("はひふへほハヒフヘホ") {
+		m[[2]rune{base, '\u309a'}] = base + 2
+	}
+	m[[2]rune{'う', '\u3099'}] = 'ゔ'
+	m[[2]rune{'ウ', '\u3099'}] = 'ヴ'
+	return m
+}()
+
+func compose(base, mark rune) (rune, bool) {
+	r, ok := compositions[[2]rune{base, mark}]
+	return r, ok
+}
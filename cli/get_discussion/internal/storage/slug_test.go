@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSlugifyTitleUnicode(t *testing.T) {
+	cases := map[string]string{
+		"Hello, World!!":               "hello_world",
+		"1st Place Solution - LB 0.95": "1st_place_solution_-_lb_095",
+		"日本語のタイトル":                     "日本語のタイトル",
+		"コンペ振り返り：上位解法まとめ":              "コンペ振り返り_上位解法まとめ",
+		"ｶﾞｲﾄﾞ　ＬＢ１位":                   "ガイド_lb1位",
+		"Café Crème":                   "café_crème",
+		"Cafe\u0301 decomposed":        "café_decomposed",
+		"中文标题，测试":                      "中文标题_测试",
+		"Привет, мир":                  "привет_мир",
+		"ﬁnal Ⅳ ① x²":                  "final_iv_1_x2",
+		"हिन्दी शीर्षक":                "हिन्दी_शीर्षक",
+		"!!!":                          "discussion",
+		"   ":                          "discussion",
+	}
+	for in, want := range cases {
+		if got := slugifyTitle(in); got != want {
+			t.Errorf("slugifyTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSlugifyTitleByteLimit(t *testing.T) {
+	long := strings.Repeat("解法", 100) + " " + strings.Repeat("é", 100)
+	got := slugifyTitle(long)
+	if len(got) > maxSlugBytes || !utf8.ValidString(got) {
+		t.Fatalf("slug too long or split a character: %d bytes", len(got))
+	}
+	words := slugifyTitle(strings.Repeat("word ", 60))
+	if len(words) > maxSlugBytes || strings.HasSuffix(words, "_") || strings.HasSuffix(words, "wor") {
+		t.Fatalf("expected a cut at a word boundary: %q", words)
+	}
+	marks := truncateSlug("ab"+strings.Repeat("e\u0301", 10), 4)
+	if marks != "ab" {
+		t.Fatalf("combining mark separated from its letter: %q", marks)
+	}
+}
+
+func TestSlugWithID(t *testing.T) {
+	if got := slugWithID("title", 123, true); got != "title_123" {
+		t.Fatalf("unexpected slug: %s", got)
+	}
+	if got := slugWithID("title", 0, false); got != "title" {
+		t.Fatalf("unexpected slug: %s", got)
+	}
+}
@@ -3,134 +3,118 @@ package storage
 import (
 	"bufio"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/frontmatter"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
 )
 
-var slugRe = regexp.MustCompile(`[^a-z0-9_\-]+`)
-
-func slugifyTitle(title string) string {
-	slug := strings.ToLower(strings.TrimSpace(title))
-	slug = regexp.MustCompile(`\s+`).ReplaceAllString(slug, "_")
-	slug = slugRe.ReplaceAllString(slug, "")
-	slug = strings.Trim(slug, "_")
-	if slug == "" {
-		return "discussion"
+// buildFrontMatter renders the front matter of d. The comment count is
+// written as a number and the published date as a timestamp when they parse.
+func buildFrontMatter(d *discussion.Discussion) string {
+	var m frontmatter.Map
+	m.Set("title", d.Title)
+	m.Set("link", d.Link)
+	m.Set("author", d.Author)
+	m.Set("comments", typedCount(d.Comments))
+	m.Set("published_date", typedDate(d.PublishedDate))
+	if id, ok := urlutil.ExtractTopicID(d.Link); ok {
+		m.Set("topic_id", id)
 	}
-	return slug
-}
-
-func yamlEscape(value string) string {
-	if value == "" {
-		return ""
+	m.Set("schema_version", SchemaVersion)
+	keys := make([]string, 0, len(d.Extra))
+	for k := range d.Extra {
+		keys = append(keys, k)
 	}
-	needsQuotes := strings.ContainsAny(value, ":#\n\r\t\"") ||
-		strings.HasPrefix(value, " ") || strings.HasSuffix(value, " ")
-	if needsQuotes {
-		escaped := strings.ReplaceAll(value, "\\", "\\\\")
-		escaped = strings.ReplaceAll(escaped, "\"", "\\\"")
-		return fmt.Sprintf(`"%s"`, escaped)
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Set(k, d.Extra[k])
 	}
-	return value
+	return frontmatter.Join(m, "\n")
 }
 
-func buildFrontMatter(d *discussion.Discussion) string {
-	var b strings.Builder
-	b.WriteString("---\n")
-	fmt.Fprintf(&b, "title: %s\n", yamlEscape(d.Title))
-	fmt.Fprintf(&b, "link: %s\n", yamlEscape(d.Link))
-	fmt.Fprintf(&b, "author: %s\n", yamlEscape(d.Author))
-	fmt.Fprintf(&b, "comments: %s\n", yamlEscape(d.Comments))
-	fmt.Fprintf(&b, "published_date: %s\n", yamlEscape(d.PublishedDate))
-	b.WriteString("---\n\n")
-	return b.String()
+func typedCount(s string) any {
+	if n, err := strconv.Atoi(strings.ReplaceAll(strings.TrimSpace(s), ",", "")); err == nil {
+		return n
+	}
+	return s
 }
 
-func readFrontMatter(path string) map[string]string {
-	meta := map[string]string{}
-	f, err := os.Open(path)
-	if err != nil {
-		return meta
+func typedDate(s string) any {
+	if t, ok := ParseDate(s); ok {
+		return t
 	}
-	defer f.Close()
+	return s
+}
 
-	scanner := bufio.NewScanner(f)
-	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
-		return meta
-	}
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "---" {
-			break
-		}
-		if line == "" || !strings.Contains(line, ":") {
-			continue
-		}
-		parts := strings.SplitN(line, ":", 2)
-		meta[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+func readFrontMatter(path string) frontmatter.Map {
+	meta, _, err := ReadDiscussionFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[warn] %v", err)
 	}
 	return meta
 }
 
-func LoadExistingLinks(outputDir string) map[string]string {
-	links := map[string]string{}
-	entries, err := os.ReadDir(outputDir)
+// ReadDiscussionFile returns the front matter and Markdown body of a saved
+// discussion. Files without front matter yield empty metadata and the whole
+// file as body.
+func ReadDiscussionFile(path string) (frontmatter.Map, string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return links
+		return frontmatter.Map{}, "", err
 	}
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
-			continue
-		}
-		path := filepath.Join(outputDir, e.Name())
-		meta := readFrontMatter(path)
-		if link, ok := meta["link"]; ok && link != "" {
-			links[urlutil.CanonicalizeURL(link)] = path
-		}
+	meta, body, err := frontmatter.Parse(string(data))
+	if err != nil {
+		return meta, strings.TrimSpace(body), fmt.Errorf("%s: %w", path, err)
 	}
-	return links
+	return meta, strings.TrimSpace(body), nil
 }
 
-func ensureUniquePath(outputDir, baseName string, existingPaths map[string]struct{}) string {
-	candidate := filepath.Join(outputDir, baseName+".md")
-	if _, dup := existingPaths[candidate]; !dup {
-		if _, err := os.Stat(candidate); os.IsNotExist(err) {
-			return candidate
-		}
-	}
-	for i := 2; ; i++ {
-		candidate = filepath.Join(outputDir, fmt.Sprintf("%s_%d.md", baseName, i))
-		if _, dup := existingPaths[candidate]; !dup {
-			if _, err := os.Stat(candidate); os.IsNotExist(err) {
-				return candidate
-			}
-		}
-	}
+// LoadExistingLinks maps the canonical link of every saved thread under
+// outputDir, in any subdirectory but SolutionsDir and UsersDir, to its file.
+// It reads the manifest, and scans the directory only when there is none.
+func LoadExistingLinks(outputDir string) map[string]string {
+	return LoadManifest(outputDir, false).Links()
 }
 
-func SaveDiscussion(d *discussion.Discussion, outputDir string, existingByLink map[string]string) (string, error) {
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return "", err
+// SaveDiscussion writes d to its file, keeping the team notes and user front
+// matter keys of a previous download. Conflicts between notes and upstream
+// edits are logged.
+func SaveDiscussion(d *discussion.Discussion, outputDir string, existingByLink map[string]string, opts Options) (string, error) {
+	path, conflicts, err := saveDiscussion(d, outputDir, existingByLink, opts)
+	logConflicts(path, conflicts)
+	return path, err
+}
+
+func saveDiscussion(d *discussion.Discussion, outputDir string, existingByLink map[string]string, opts Options) (string, []string, error) {
+	path := placeDiscussion(d, outputDir, existingByLink, opts)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", nil, err
 	}
-	linkKey := urlutil.CanonicalizeURL(d.Link)
-	path, exists := existingByLink[linkKey]
-	if !exists {
-		slug := slugifyTitle(d.Title)
-		existing := map[string]struct{}{}
-		for _, v := range existingByLink {
-			existing[v] = struct{}{}
-		}
-		path = ensureUniquePath(outputDir, slug, existing)
-		existingByLink[linkKey] = path
+
+	content, conflicts, err := renderOver(d, path)
+	if err != nil {
+		return path, nil, err
 	}
+	if err := WriteFileAtomic(path, []byte(content), 0o644, opts.Fsync); err != nil {
+		return path, conflicts, err
+	}
+	if !indexable(d) {
+		return path, conflicts, nil
+	}
+	return path, conflicts, writeCommentIndex(path, newCommentIndex(d), opts.Fsync)
+}
 
-	content := buildFrontMatter(d) + strings.TrimSpace(d.ContentMD) + "\n"
-	return path, os.WriteFile(path, []byte(content), 0o644)
+func logConflicts(path string, conflicts []string) {
+	for _, c := range conflicts {
+		log.Printf("[warn] %s: %s", path, c)
+	}
 }
 
 func LoadEnvFile(path string) {
@@ -3,7 +3,9 @@ package storage
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
 )
@@ -21,8 +23,9 @@ func TestFrontMatterRoundTrip(t *testing.T) {
 		Link:          "https://example.com",
 		Author:        "Author",
 		Comments:      "5",
-		PublishedDate: "2024-01-01",
+		PublishedDate: "2024-01-01T09:30:00Z",
 		ContentMD:     "Body",
+		Extra:         map[string]any{"archived_user": "bob", "user_comment_ids": []int{2, 3}},
 	}
 	content := buildFrontMatter(d) + "Body\n"
 
@@ -32,15 +35,53 @@ func TestFrontMatterRoundTrip(t *testing.T) {
 		t.Fatalf("write failed: %v", err)
 	}
 	meta := readFrontMatter(path)
-	if meta["title"] != d.Title {
-		t.Fatalf("title mismatch: %s", meta["title"])
+	if meta.String("title") != d.Title {
+		t.Fatalf("title mismatch: %s", meta.String("title"))
 	}
-	if meta["link"] != d.Link {
-		t.Fatalf("link mismatch: %s", meta["link"])
+	if meta.String("link") != d.Link {
+		t.Fatalf("link mismatch: %s", meta.String("link"))
+	}
+	if v, _ := meta.Get("comments"); v != 5 {
+		t.Fatalf("comments should be an int: %#v", v)
+	}
+	if v, _ := meta.Get("published_date"); !v.(time.Time).Equal(time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("published_date should be a time: %#v", v)
+	}
+	if v, _ := meta.Get("user_comment_ids"); !reflect.DeepEqual(v, []any{2, 3}) {
+		t.Fatalf("extra mismatch: %#v", v)
+	}
+	if _, body, err := ReadDiscussionFile(path); err != nil || body != "Body" {
+		t.Fatalf("unexpected body %q: %v", body, err)
 	}
 }
 
-func TestEnsureUniquePath(t *testing.T) {
+func TestReadLegacyFrontMatter(t *testing.T) {
+	// Written by the old string-only serializer.
+	legacy := "---\ntitle: [WIP] 'quoted' - ideas\nlink: https://www.kaggle.com/discussions/1\n" +
+		"author: \"a: \\\"b\\\"\"\ncomments: 5\npublished_date: \ncode_links: https://a, https://b\n---\n\nBody\n"
+	path := filepath.Join(t.TempDir(), "legacy.md")
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	meta, body, err := ReadDiscussionFile(path)
+	if err != nil || body != "Body" {
+		t.Fatalf("unexpected body %q: %v", body, err)
+	}
+	want := map[string]string{
+		"title":          "[WIP] 'quoted' - ideas",
+		"author":         `a: "b"`,
+		"comments":       "5",
+		"published_date": "",
+		"code_links":     "https://a, https://b",
+	}
+	for k, v := range want {
+		if got := meta.String(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestAvailablePath(t *testing.T) {
 	dir := t.TempDir()
 	base := "discussion"
 	first := filepath.Join(dir, base+".md")
@@ -49,8 +90,11 @@ func TestEnsureUniquePath(t *testing.T) {
 	}
 
 	paths := map[string]struct{}{first: {}}
-	got := ensureUniquePath(dir, base, paths)
+	got := availablePath(dir, base, paths, "", "")
 	if got == first {
 		t.Fatalf("expected unique path, got %s", got)
 	}
+	if own := availablePath(dir, base, nil, first, ""); own != first {
+		t.Fatalf("a thread's own file should stay available, got %s", own)
+	}
 }
@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/frontmatter"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// CommentIndex is the sidecar saved next to a discussion, listing its
+// messages in file order with a hash of their content.
+type CommentIndex struct {
+	Link     string         `json:"link"`
+	Comments []CommentState `json:"comments"`
+}
+
+// CommentState is one message of a CommentIndex. Votes is nil in indexes
+// written before votes were tracked, and a vote change is only reported
+// once it is known.
+type CommentState struct {
+	ID      int    `json:"id"`
+	Hash    string `json:"hash"`
+	Votes   *int   `json:"votes,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// commentState returns the index entry of m as it is now.
+func commentState(m discussion.Message) CommentState {
+	votes := m.Votes
+	return CommentState{ID: m.ID, Hash: hashBody(m.Body), Votes: &votes}
+}
+
+// oldVotes returns the recorded votes of st, 0 when unknown.
+func (st CommentState) oldVotes() int {
+	if st.Votes == nil {
+		return 0
+	}
+	return *st.Votes
+}
+
+// Changes summarises an incremental update of a thread.
+type Changes struct {
+	Created   bool
+	Rewritten bool
+	New       int
+	Edited    int
+	Deleted   int
+	Votes     int
+	// Metadata is set when the front matter (title, comment count, ...)
+	// changed upstream.
+	Metadata bool
+}
+
+func (c Changes) String() string {
+	switch {
+	case c.Created:
+		return fmt.Sprintf("new thread, %d messages", c.New)
+	case c.Rewritten:
+		return "rewritten, no comment index"
+	case c == Changes{}:
+		return "up to date"
+	}
+	var parts []string
+	if c.New > 0 {
+		parts = append(parts, fmt.Sprintf("+%d new", c.New))
+	}
+	if c.Edited > 0 {
+		parts = append(parts, fmt.Sprintf("%d edited", c.Edited))
+	}
+	if c.Deleted > 0 {
+		parts = append(parts, fmt.Sprintf("%d deleted", c.Deleted))
+	}
+	if c.Votes > 0 {
+		parts = append(parts, fmt.Sprintf("%d vote changes", c.Votes))
+	}
+	if c.Metadata {
+		parts = append(parts, "front matter updated")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Change kinds of a CommentChange.
+const (
+	CommentNew     = "new"
+	CommentEdited  = "edited"
+	CommentDeleted = "deleted"
+	CommentVotes   = "votes"
+)
+
+// CommentChange is one message-level difference between a saved thread and
+// its fresh copy.
+type CommentChange struct {
+	Kind     string
+	ID       int
+	Author   string
+	Body     string
+	OldVotes int
+	Votes    int
+}
+
+// ThreadDiff is the difference between a saved thread and its fresh copy.
+// Apply writes the fresh copy the way UpdateDiscussion would.
+type ThreadDiff struct {
+	Path     string
+	Changes  Changes
+	Comments []CommentChange
+	// Conflicts lists team notes whose message changed or disappeared upstream.
+	Conflicts []string
+	// Old and New are the full file contents before and after the update.
+	Old string
+	New string
+
+	d              *discussion.Discussion
+	outputDir      string
+	existingByLink map[string]string
+	opts           Options
+	index          CommentIndex
+	// recordVotes is set when the saved index lacks votes, so Apply writes
+	// it even if nothing else changed.
+	recordVotes bool
+}
+
+// deletedMark is appended to the heading of comments removed upstream. Their
+// last known text stays in the file.
+const deletedMark = " (deleted)"
+
+// deletedPost starts an opening post removed upstream.
+const deletedPost = "*(deleted)*\n\n"
+
+// commentIndexPath returns the sidecar path of a saved discussion.
+func commentIndexPath(mdPath string) string {
+	return strings.TrimSuffix(mdPath, ".md") + ".comments.json"
+}
+
+func hashBody(body string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(body)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// indexable reports whether every message has an ID to track it by.
+func indexable(d *discussion.Discussion) bool {
+	if len(d.Messages) == 0 {
+		return false
+	}
+	for _, m := range d.Messages {
+		if m.ID == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func newCommentIndex(d *discussion.Discussion) CommentIndex {
+	idx := CommentIndex{Link: urlutil.CanonicalizeURL(d.Link)}
+	for _, m := range d.Messages {
+		idx.Comments = append(idx.Comments, commentState(m))
+	}
+	return idx
+}
+
+func readCommentIndex(path string) (CommentIndex, error) {
+	var idx CommentIndex
+	data, err := os.ReadFile(commentIndexPath(path))
+	if err != nil {
+		return idx, err
+	}
+	return idx, json.Unmarshal(data, &idx)
+}
+
+func writeCommentIndex(path string, idx CommentIndex, sync bool) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(commentIndexPath(path), append(data, '\n'), 0o644, sync)
+}
+
+// splitSections splits a saved thread body into the opening post and the
+// comment sections, in file order.
+func splitSections(body string) []string {
+	parts := strings.Split(body, discussion.SectionSeparator+discussion.CommentHeading)
+	for i := 1; i < len(parts); i++ {
+		parts[i] = discussion.CommentHeading + parts[i]
+	}
+	return parts
+}
+
+func markDeleted(section string) string {
+	if !strings.HasPrefix(section, discussion.CommentHeading) {
+		return deletedPost + section
+	}
+	heading, rest, _ := strings.Cut(section, "\n")
+	if strings.HasSuffix(heading, deletedMark) {
+		return section
+	}
+	return heading + deletedMark + "\n" + rest
+}
+
+// UpdateDiscussion updates a saved thread in place: unchanged messages keep
+// their text in the file, edited ones are re-rendered, new ones are appended
+// and deleted ones are kept but marked. Threads not saved yet, or saved
+// without a comment index, are written in full.
+func UpdateDiscussion(d *discussion.Discussion, outputDir string, existingByLink map[string]string, opts Options) (string, Changes, error) {
+	td, err := DiffDiscussion(d, outputDir, existingByLink, opts)
+	if err != nil {
+		return td.Path, Changes{}, err
+	}
+	err = td.Apply()
+	logConflicts(td.Path, td.Conflicts)
+	return td.Path, td.Changes, err
+}
+
+// DiffDiscussion compares d with its saved file, found through
+// existingByLink, without writing anything. It fails when the saved front
+// matter cannot be parsed, as the new rendering would drop the user's keys.
+func DiffDiscussion(d *discussion.Discussion, outputDir string, existingByLink map[string]string, opts Options) (*ThreadDiff, error) {
+	td := &ThreadDiff{d: d, outputDir: outputDir, existingByLink: existingByLink, opts: opts}
+
+	path, exists := existingByLink[urlutil.CanonicalizeURL(d.Link)]
+	if !exists {
+		td.Changes = Changes{Created: true, New: len(d.Messages)}
+		td.New = buildFrontMatter(d) + strings.TrimSpace(d.ContentMD) + "\n"
+		return td, nil
+	}
+	td.Path = path
+	old, oldErr := os.ReadFile(path)
+	td.Old = string(old)
+
+	idx, err := readCommentIndex(path)
+	meta, body, readErr := ReadDiscussionFile(path)
+	if readErr != nil && oldErr == nil {
+		return td, fmt.Errorf("refusing to overwrite: %w", readErr)
+	}
+	ann := splitAnnotated(body, idx.lastHash())
+	sections := ann.sections
+	if err != nil || oldErr != nil || !indexable(d) || len(sections) != len(idx.Comments) {
+		td.Changes = Changes{Rewritten: true}
+		td.New, td.Conflicts, err = renderOver(d, path)
+		return td, err
+	}
+
+	current := map[int]int{}
+	for i, m := range d.Messages {
+		current[m.ID] = i
+	}
+
+	var out []string
+	seen := map[int]bool{}
+	td.index = CommentIndex{Link: urlutil.CanonicalizeURL(d.Link)}
+	for i, st := range idx.Comments {
+		section := sections[i]
+		seen[st.ID] = true
+		j, ok := current[st.ID]
+		if !ok {
+			if !st.Deleted {
+				td.Changes.Deleted++
+				td.Comments = append(td.Comments, CommentChange{Kind: CommentDeleted, ID: st.ID, Author: sectionAuthor(section), OldVotes: st.oldVotes()})
+				section = markDeleted(section)
+			}
+			st.Deleted = true
+			out = append(out, section)
+			td.index.Comments = append(td.index.Comments, st)
+			continue
+		}
+
+		m := d.Messages[j]
+		if st.Deleted || hashBody(m.Body) != st.Hash {
+			td.Changes.Edited++
+			td.Comments = append(td.Comments, CommentChange{Kind: CommentEdited, ID: m.ID, Author: m.Author, Body: m.Body, OldVotes: st.oldVotes(), Votes: m.Votes})
+			section = discussion.RenderSection(m, i == 0)
+			if len(ann.blocks[i]) > 0 {
+				td.Conflicts = append(td.Conflicts, fmt.Sprintf("upstream text of %s changed under team notes", sectionName(i, st.ID)))
+			}
+		}
+		if st.Votes == nil {
+			td.recordVotes = true
+		} else if m.Votes != *st.Votes {
+			td.Changes.Votes++
+			td.Comments = append(td.Comments, CommentChange{Kind: CommentVotes, ID: m.ID, Author: m.Author, OldVotes: st.oldVotes(), Votes: m.Votes})
+		}
+		out = append(out, section)
+		td.index.Comments = append(td.index.Comments, commentState(m))
+	}
+	for _, m := range d.Messages {
+		if seen[m.ID] {
+			continue
+		}
+		td.Changes.New++
+		td.Comments = append(td.Comments, CommentChange{Kind: CommentNew, ID: m.ID, Author: m.Author, Body: m.Body, Votes: m.Votes})
+		out = append(out, discussion.RenderSection(m, len(out) == 0))
+		td.index.Comments = append(td.index.Comments, commentState(m))
+	}
+
+	fm := frontMatterWith(d, userFrontMatter(meta, d))
+	td.New = td.Old
+	if td.Changes.New+td.Changes.Edited+td.Changes.Deleted > 0 {
+		ann.sections = out
+		td.New = fm + ann.join() + "\n"
+	} else if _, oldBody, ok := frontmatter.Split(td.Old); ok {
+		// Only the front matter block is replaced, keeping the body as is.
+		block := strings.TrimSuffix(fm, "\n")
+		if td.Old[:len(td.Old)-len(oldBody)] != block {
+			td.New = block + oldBody
+			td.Changes.Metadata = true
+		}
+	}
+	return td, nil
+}
+
+// Apply writes the update described by td, moving the file first when its
+// templated path changed.
+func (td *ThreadDiff) Apply() error {
+	if td.Changes.Created || td.Changes.Rewritten {
+		path, conflicts, err := saveDiscussion(td.d, td.outputDir, td.existingByLink, td.opts)
+		td.Path, td.Conflicts = path, conflicts
+		return err
+	}
+	td.Path = placeDiscussion(td.d, td.outputDir, td.existingByLink, td.opts)
+	if td.Changes == (Changes{}) && !td.recordVotes {
+		return nil
+	}
+	if td.New != td.Old {
+		if err := WriteFileAtomic(td.Path, []byte(td.New), 0o644, td.opts.Fsync); err != nil {
+			return err
+		}
+	}
+	return writeCommentIndex(td.Path, td.index, td.opts.Fsync)
+}
+
+// sectionAuthor reads the author from a comment heading.
+func sectionAuthor(section string) string {
+	heading, _, _ := strings.Cut(section, "\n")
+	if !strings.HasPrefix(heading, discussion.CommentHeading) {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(heading, discussion.CommentHeading), deletedMark)
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func thread(messages ...discussion.Message) *discussion.Discussion {
+	parts := make([]string, len(messages))
+	for i, m := range messages {
+		parts[i] = discussion.RenderSection(m, i == 0)
+	}
+	return &discussion.Discussion{
+		Title:     "Thread",
+		Link:      "https://www.kaggle.com/competitions/x/discussion/1",
+		ContentMD: strings.Join(parts, discussion.SectionSeparator),
+		Messages:  messages,
+	}
+}
+
+func TestUpdateDiscussion(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	bob := discussion.Message{ID: 2, Author: "bob", Body: "First"}
+	carol := discussion.Message{ID: 3, Author: "carol", Body: "Second"}
+
+	path, changes, err := UpdateDiscussion(thread(main, bob, carol), dir, links, Options{})
+	if err != nil || !changes.Created || changes.New != 3 {
+		t.Fatalf("unexpected first save: %+v %v", changes, err)
+	}
+
+	// Hand edits to unchanged sections survive an update.
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), "Main", "Main (pinned by us)", 1)), 0o644)
+
+	_, changes, err = UpdateDiscussion(thread(main, bob, carol), dir, links, Options{})
+	if err != nil || changes.String() != "up to date" {
+		t.Fatalf("expected no changes, got %s %v", changes, err)
+	}
+
+	edited := discussion.Message{ID: 3, Author: "carol", Body: "Second, edited"}
+	dave := discussion.Message{ID: 4, Author: "dave", Body: "Third"}
+	erin := discussion.Message{ID: 5, Author: "erin", Body: "Fourth"}
+	_, changes, err = UpdateDiscussion(thread(main, edited, dave, erin), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changes.String(); got != "+2 new, 1 edited, 1 deleted" {
+		t.Fatalf("unexpected summary: %s", got)
+	}
+
+	_, body, _ := ReadDiscussionFile(path)
+	want := "Main (pinned by us)\n\n---\n\n## Comment by bob (deleted)\n\nFirst\n\n---\n\n## Comment by carol\n\nSecond, edited" +
+		"\n\n---\n\n## Comment by dave\n\nThird\n\n---\n\n## Comment by erin\n\nFourth"
+	if body != want {
+		t.Fatalf("unexpected body:\n%s", body)
+	}
+
+	_, changes, _ = UpdateDiscussion(thread(main, edited, dave, erin), dir, links, Options{})
+	if changes.String() != "up to date" {
+		t.Fatalf("deleted comment should only be reported once, got %s", changes)
+	}
+}
+
+func TestUpdateDiscussionRefreshesFrontMatter(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true}
+	d := thread(main)
+	d.Comments = "1"
+	path, _, err := UpdateDiscussion(d, dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), "Main", "Main (annotated)", 1)), 0o644)
+
+	d = thread(main)
+	d.Title = "Thread, renamed"
+	d.Comments = "3"
+	path, changes, err := UpdateDiscussion(d, dir, links, Options{})
+	if err != nil || changes.String() != "front matter updated" {
+		t.Fatalf("expected a front matter update, got %s %v", changes, err)
+	}
+	meta, body, _ := ReadDiscussionFile(path)
+	if meta.String("title") != "Thread, renamed" || meta.String("comments") != "3" || body != "Main (annotated)" {
+		t.Fatalf("unexpected file: %v %q", meta, body)
+	}
+	if _, changes, _ = UpdateDiscussion(d, dir, links, Options{}); changes.String() != "up to date" {
+		t.Fatalf("expected no changes, got %s", changes)
+	}
+}
+
+func TestUpdateDiscussionWithoutIndex(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	d := thread(discussion.Message{ID: 1, Body: "Main", IsMain: true})
+	path, err := SaveDiscussion(d, dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(commentIndexPath(path))
+
+	_, changes, err := UpdateDiscussion(d, dir, links, Options{})
+	if err != nil || !changes.Rewritten {
+		t.Fatalf("expected a full rewrite, got %+v %v", changes, err)
+	}
+	if _, err := os.Stat(commentIndexPath(path)); err != nil {
+		t.Fatalf("comment index should be recreated: %v", err)
+	}
+}
+
+func TestDiffDiscussion(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true, Votes: 3}
+	bob := discussion.Message{ID: 2, Author: "bob", Body: "First"}
+	path, err := SaveDiscussion(thread(main, bob), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(path)
+
+	main.Votes = 5
+	carol := discussion.Message{ID: 3, Author: "carol", Body: "New reply"}
+	td, err := DiffDiscussion(thread(main, bob, carol), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := td.Changes.String(); got != "+1 new, 1 vote changes" {
+		t.Fatalf("unexpected summary: %s", got)
+	}
+	if len(td.Comments) != 2 || td.Comments[0].Kind != CommentVotes || td.Comments[0].OldVotes != 3 ||
+		td.Comments[1].Kind != CommentNew || td.Comments[1].Author != "carol" {
+		t.Fatalf("unexpected comment changes: %+v", td.Comments)
+	}
+	if td.Old != string(before) || !strings.HasSuffix(td.New, "## Comment by carol\n\nNew reply\n") {
+		t.Fatalf("unexpected contents:\n%s", td.New)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Fatalf("diff must not write")
+	}
+
+	if err := td.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != td.New {
+		t.Fatalf("apply should write the new content")
+	}
+	if again, err := DiffDiscussion(thread(main, bob, carol), dir, links, Options{}); err != nil || again.Changes != (Changes{}) {
+		t.Fatalf("expected no changes after apply, got %+v: %v", again, err)
+	}
+}
+
+func TestDiffDiscussionIgnoresUnrecordedVotes(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	main := discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true, Votes: 4}
+	path, err := SaveDiscussion(thread(main), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Indexes written before votes were tracked have no votes key.
+	legacy := `{"link":"` + thread(main).Link + `","comments":[{"id":1,"hash":"` + hashBody("Main") + `"}]}`
+	if err := os.WriteFile(commentIndexPath(path), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := DiffDiscussion(thread(main), dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.Changes != (Changes{}) {
+		t.Fatalf("unknown votes should not count as a change, got %s", td.Changes)
+	}
+	if err := td.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	main.Votes = 6
+	if again, err := DiffDiscussion(thread(main), dir, links, Options{}); err != nil || again.Changes.String() != "1 vote changes" {
+		t.Fatalf("votes should be tracked once recorded, got %+v: %v", again, err)
+	}
+}
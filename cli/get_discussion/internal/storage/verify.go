@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/frontmatter"
+)
+
+// Problem is an issue Verify found in the output directory.
+type Problem struct {
+	Path  string
+	Issue string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Issue
+}
+
+// generatedIndexes are Markdown files the tool writes without front matter.
+var generatedIndexes = map[string]bool{"index.md": true, "links.md": true}
+
+// Verify checks the saved discussions under dir for truncated files, files
+// without front matter or link, and comment indexes that do not match
+// their thread. It also reports temporary files and stale locks left by
+// interrupted runs.
+func Verify(dir string) ([]Problem, error) {
+	var problems []Problem
+	host, _ := os.Hostname()
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := e.Name()
+		switch {
+		case e.IsDir():
+			if name == "snippets" {
+				return filepath.SkipDir
+			}
+		case isTempFile(name):
+			problems = append(problems, Problem{path, "leftover temporary file of an interrupted write"})
+		case name == LockFile:
+			if holder, stale := readLock(path, host); stale {
+				problems = append(problems, Problem{path, "stale lock held by " + holder})
+			}
+		case strings.HasSuffix(name, ".comments.json"):
+			md := strings.TrimSuffix(path, ".comments.json") + ".md"
+			if _, err := os.Stat(md); os.IsNotExist(err) {
+				problems = append(problems, Problem{path, "comment index without its thread"})
+			}
+		case strings.HasSuffix(name, ".md") && !generatedIndexes[name]:
+			for _, issue := range verifyFile(path) {
+				problems = append(problems, Problem{path, issue})
+			}
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// verifyFile returns the issues of one saved discussion.
+func verifyFile(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	content := string(data)
+	if strings.TrimSpace(content) == "" {
+		return []string{"empty file"}
+	}
+	yaml, body, ok := frontmatter.Split(content)
+	if !ok {
+		if strings.HasPrefix(content, frontmatter.Delimiter+"\n") {
+			return []string{"front matter is not closed (truncated?)"}
+		}
+		return []string{"no front matter"}
+	}
+
+	var issues []string
+	meta, err := frontmatter.Decode(yaml)
+	switch {
+	case err != nil:
+		issues = append(issues, "invalid front matter: "+err.Error())
+	case meta.String("link") == "":
+		issues = append(issues, "front matter has no link")
+	}
+	if !strings.HasSuffix(content, "\n") {
+		issues = append(issues, "does not end with a newline (truncated?)")
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		issues = append(issues, "empty body")
+	}
+
+	idxData, err := os.ReadFile(commentIndexPath(path))
+	if err != nil {
+		return issues
+	}
+	var idx CommentIndex
+	if err := json.Unmarshal(idxData, &idx); err != nil {
+		return append(issues, "invalid comment index: "+err.Error())
+	}
+	if n := len(splitAnnotated(body, idx.lastHash()).sections); n < len(idx.Comments) {
+		issues = append(issues, fmt.Sprintf("has %d of %d indexed messages (truncated?)", n, len(idx.Comments)))
+	}
+	return issues
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	links := map[string]string{}
+	d := thread(
+		discussion.Message{ID: 1, Author: "alice", Body: "Main", IsMain: true},
+		discussion.Message{ID: 2, Author: "bob", Body: "Reply"},
+	)
+	good, err := SaveDiscussion(d, dir, links, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems, err := Verify(dir); err != nil || len(problems) != 0 {
+		t.Fatalf("clean directory: %v %v", problems, err)
+	}
+
+	write := func(name, content string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+	data, _ := os.ReadFile(good)
+	content := string(data)
+	cut := strings.Index(content, discussion.SectionSeparator)
+	os.WriteFile(good, []byte(content[:cut]), 0o644)
+	write("plain.md", "# Notes\n")
+	write("open.md", "---\ntitle: x\nlink: https://x/discussion/2\n")
+	write("nolink.md", "---\ntitle: x\n---\n\nBody\n")
+	write("empty.md", "")
+	write("index.md", "# Index\n")
+	write("thread/snippets/index.md", "# Snippets\n")
+	write(".tmp-a.md-123", "partial")
+	write("gone.comments.json", "{}")
+
+	problems, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range problems {
+		rel, _ := filepath.Rel(dir, p.Path)
+		got = append(got, rel+": "+p.Issue)
+	}
+	sort.Strings(got)
+	base := filepath.Base(good)
+	want := []string{
+		".tmp-a.md-123: leftover temporary file of an interrupted write",
+		fmt.Sprintf("%s: does not end with a newline (truncated?)", base),
+		fmt.Sprintf("%s: has 1 of 2 indexed messages (truncated?)", base),
+		"empty.md: empty file",
+		"gone.comments.json: comment index without its thread",
+		"nolink.md: front matter has no link",
+		"open.md: front matter is not closed (truncated?)",
+		"plain.md: no front matter",
+	}
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
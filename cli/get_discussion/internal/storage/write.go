@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"os"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/fsutil"
+)
+
+// WriteFileAtomic is fsutil.WriteFileAtomic: path is replaced by a rename,
+// flushed to disk first with sync set.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode, sync bool) error {
+	return fsutil.WriteFileAtomic(path, data, perm, sync)
+}
+
+// isTempFile reports whether name is a temporary file of WriteFileAtomic.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, fsutil.TempPrefix)
+}
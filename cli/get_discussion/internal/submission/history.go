@@ -0,0 +1,42 @@
+package submission
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HistoryRecord is one line of the local submission history file.
+type HistoryRecord struct {
+	SubmittedAt  time.Time `json:"submitted_at"`
+	Competition  string    `json:"competition"`
+	File         string    `json:"file"`
+	Message      string    `json:"message"`
+	Ref          int       `json:"ref"`
+	Status       string    `json:"status"`
+	PublicScore  string    `json:"public_score"`
+	PrivateScore string    `json:"private_score,omitempty"`
+	RankBefore   int       `json:"rank_before,omitempty"`
+	RankAfter    int       `json:"rank_after,omitempty"`
+	Remaining    int       `json:"remaining_today"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// AppendHistory appends rec as a JSON line to path, creating it if needed.
+func AppendHistory(path string, rec HistoryRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
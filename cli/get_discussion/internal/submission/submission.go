@@ -0,0 +1,139 @@
+package submission
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+)
+
+const (
+	statusPending  = "pending"
+	statusComplete = "complete"
+	statusError    = "error"
+)
+
+// Result summarises a scored submission.
+type Result struct {
+	Submission api.Submission
+	RankBefore int
+	RankAfter  int
+	Remaining  int
+}
+
+// RankChange returns how many places the team moved up (negative means down).
+// It is zero when either rank is unknown.
+func (r Result) RankChange() int {
+	if r.RankBefore <= 0 || r.RankAfter <= 0 {
+		return 0
+	}
+	return r.RankBefore - r.RankAfter
+}
+
+// Upload sends the file at path to competition with message and returns the new ref.
+func Upload(c *client.Client, competition, path, message string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	upload, err := api.CreateSubmissionUpload(c, competition, path, info.Size(), info.ModTime().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("create upload: %w", err)
+	}
+	if err := c.PutFile(upload.CreateURL, path); err != nil {
+		return 0, fmt.Errorf("upload file: %w", err)
+	}
+	resp, err := api.SubmitCompetition(c, competition, upload.Token, message)
+	if err != nil {
+		return 0, fmt.Errorf("submit: %w", err)
+	}
+	return resp.Ref, nil
+}
+
+// Poll waits until the submission identified by ref leaves the pending state.
+// A zero ref matches the most recent submission. Each poll reads only the
+// first page of submissions, which is also what it returns.
+func Poll(c *client.Client, competition string, ref int, interval, timeout time.Duration) (api.Submission, []api.Submission, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		subs, err := api.ListRecentSubmissions(c, competition)
+		if err != nil {
+			return api.Submission{}, nil, err
+		}
+		sub, ok := findSubmission(subs, ref)
+		if !ok {
+			return api.Submission{}, subs, fmt.Errorf("submission ref=%d not found", ref)
+		}
+		if !isPending(sub.Status) {
+			return sub, subs, nil
+		}
+		if time.Now().After(deadline) {
+			return sub, subs, fmt.Errorf("timed out waiting for ref=%d (status %s)", ref, sub.Status)
+		}
+		c.LogInfo("Submission ref=%d still %s, polling again in %s", ref, sub.Status, interval)
+		time.Sleep(interval)
+	}
+}
+
+func findSubmission(subs []api.Submission, ref int) (api.Submission, bool) {
+	if ref == 0 {
+		if len(subs) == 0 {
+			return api.Submission{}, false
+		}
+		return subs[0], true
+	}
+	for _, s := range subs {
+		if s.Ref == ref {
+			return s, true
+		}
+	}
+	return api.Submission{}, false
+}
+
+// isPending reports whether status is still being scored. The API has used
+// both "pending" and "SubmissionStatus.PENDING" over time.
+func isPending(status string) bool {
+	return strings.Contains(strings.ToLower(status), statusPending)
+}
+
+// IsError reports whether status marks a failed submission.
+func IsError(status string) bool {
+	return strings.Contains(strings.ToLower(status), statusError)
+}
+
+// TeamRank returns the 1-based leaderboard position of team, or 0 if absent.
+func TeamRank(board []api.LeaderboardEntry, team string) int {
+	if team == "" {
+		return 0
+	}
+	for i, e := range board {
+		if e.TeamName == team {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// RemainingToday returns how many submissions are left for the current UTC day.
+func RemainingToday(subs []api.Submission, maxDaily int, now time.Time) int {
+	if maxDaily <= 0 {
+		return -1
+	}
+	today := now.UTC().Format("2006-01-02")
+	used := 0
+	for _, s := range subs {
+		if IsError(s.Status) {
+			continue
+		}
+		if strings.HasPrefix(s.Date, today) {
+			used++
+		}
+	}
+	if used >= maxDaily {
+		return 0
+	}
+	return maxDaily - used
+}
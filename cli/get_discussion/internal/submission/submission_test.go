@@ -0,0 +1,64 @@
+package submission
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+)
+
+func TestRemainingToday(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	subs := []api.Submission{
+		{Date: "2024-05-01T10:00:00Z", Status: "complete"},
+		{Date: "2024-05-01T09:00:00Z", Status: "error"},
+		{Date: "2024-04-30T23:00:00Z", Status: "complete"},
+	}
+	if got := RemainingToday(subs, 5, now); got != 4 {
+		t.Fatalf("unexpected remaining: %d", got)
+	}
+	if got := RemainingToday(subs, 0, now); got != -1 {
+		t.Fatalf("expected unknown remaining, got %d", got)
+	}
+}
+
+func TestTeamRankAndChange(t *testing.T) {
+	board := []api.LeaderboardEntry{{TeamName: "a"}, {TeamName: "b"}, {TeamName: "c"}}
+	if got := TeamRank(board, "c"); got != 3 {
+		t.Fatalf("unexpected rank: %d", got)
+	}
+	r := Result{RankBefore: 5, RankAfter: 3}
+	if r.RankChange() != 2 {
+		t.Fatalf("unexpected change: %d", r.RankChange())
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+	for i := 1; i <= 2; i++ {
+		if err := AppendHistory(path, HistoryRecord{Ref: i, File: "sub.csv"}); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer f.Close()
+	var refs []int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("bad line: %v", err)
+		}
+		refs = append(refs, rec.Ref)
+	}
+	if len(refs) != 2 || refs[1] != 2 {
+		t.Fatalf("unexpected refs: %v", refs)
+	}
+}
@@ -0,0 +1,192 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// op is one line of an edit script.
+type op struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// Unified returns a unified diff of two texts with the given number of
+// context lines, or "" when they are equal.
+func Unified(oldName, newName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := lineOps(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change and the hunk around it.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		lo := max(first-context, start)
+		hi := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				hi = i
+				continue
+			}
+			if i-hi > 2*context {
+				break
+			}
+		}
+		end := min(hi+context+1, len(ops))
+
+		oldStart, newStart := position(ops, lo)
+		oldCount, newCount := 0, 0
+		for _, o := range ops[lo:end] {
+			if o.kind != '+' {
+				oldCount++
+			}
+			if o.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, o := range ops[lo:end] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.text)
+			out.WriteByte('\n')
+		}
+		start = end
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineOps computes a shortest edit script with Myers' algorithm, after
+// trimming the common prefix and suffix. Time and memory grow with the
+// number of changed lines rather than with the product of the lengths, so
+// small edits to long threads stay cheap.
+func lineOps(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]op, 0, len(a)+len(b)-prefix-suffix)
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{' ', line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{' ', line})
+	}
+	return ops
+}
+
+// myers returns the edit script of a into b. trace keeps the furthest
+// reaching x of the diagonals -d-1..d+1 before each edit count d, the only
+// ones round d reads, and the script is read back from it. Memory is
+// O(D²) for D edits rather than O(D·(N+M)).
+func myers(a, b []string) []op {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+	off := n + m + 1
+	v := make([]int, 2*off+1)
+	var trace [][]int
+	// down reports whether diagonal k is reached by an insertion from k+1
+	// rather than a deletion from k-1; diagonal 0 is at v[o].
+	down := func(v []int, o, k, d int) bool {
+		return k == -d || (k != d && v[o+k-1] < v[o+k+1])
+	}
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if down(v, off, k, d) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var rev []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, o := trace[d], d+1
+		k := x - y
+		prevK := k - 1
+		if down(v, o, k, d) {
+			prevK = k + 1
+		}
+		prevX := v[o+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, op{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				rev = append(rev, op{'+', b[y-1]})
+			} else {
+				rev = append(rev, op{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	ops := make([]op, len(rev))
+	for i, o := range rev {
+		ops[len(rev)-1-i] = o
+	}
+	return ops
+}
+
+// position returns the 1-based old and new line numbers of ops[idx].
+func position(ops []op, idx int) (int, int) {
+	oldLine, newLine := 1, 1
+	for _, o := range ops[:idx] {
+		if o.kind != '+' {
+			oldLine++
+		}
+		if o.kind != '-' {
+			newLine++
+		}
+	}
+	return oldLine, newLine
+}
+
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,120 @@
+package textdiff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	want := "--- old\n+++ new\n" +
+		"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n" +
+		"@@ -9 +9,2 @@\n i\n+j\n"
+	if got := Unified("old", "new", a, b, 1); got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Unified("old", "new", a, a, 3); got != "" {
+		t.Fatalf("equal texts should not differ: %q", got)
+	}
+	if got := Unified("old", "new", "", "x\n", 3); got != "--- old\n+++ new\n@@ -0,0 +1 @@\n+x\n" {
+		t.Fatalf("unexpected diff from empty: %q", got)
+	}
+}
+
+func TestLineOpsIsMinimal(t *testing.T) {
+	cases := [][2]string{
+		{"abcabba", "cbabac"},
+		{"", "xyz"},
+		{"xyz", ""},
+		{"aaaa", "aa"},
+		{"abcd", "dcba"},
+		{"abxcd", "abycd"},
+	}
+	for _, c := range cases {
+		a, b := strings.Split(c[0], ""), strings.Split(c[1], "")
+		if c[0] == "" {
+			a = nil
+		}
+		if c[1] == "" {
+			b = nil
+		}
+		ops := lineOps(a, b)
+		var gotA, gotB []string
+		edits := 0
+		for _, o := range ops {
+			if o.kind != '+' {
+				gotA = append(gotA, o.text)
+			}
+			if o.kind != '-' {
+				gotB = append(gotB, o.text)
+			}
+			if o.kind != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(gotA, "") != c[0] || strings.Join(gotB, "") != c[1] {
+			t.Fatalf("%q -> %q: script does not rebuild the inputs: %+v", c[0], c[1], ops)
+		}
+		if want := len(a) + len(b) - 2*lcsLen(a, b); edits != want {
+			t.Fatalf("%q -> %q: %d edits, want %d", c[0], c[1], edits, want)
+		}
+	}
+}
+
+func TestLineOpsLongInputs(t *testing.T) {
+	a := make([]string, 200000)
+	for i := range a {
+		a[i] = strconv.Itoa(i)
+	}
+	b := append([]string(nil), a...)
+	b[100000] = "changed"
+	ops := lineOps(a, b)
+	if len(ops) != len(a)+1 {
+		t.Fatalf("expected one replaced line, got %d ops", len(ops))
+	}
+}
+
+// TestLineOpsScatteredEdits changes both ends, so nothing is trimmed, and
+// a line every 100: the trace must stay small for these 400 edits.
+func TestLineOpsScatteredEdits(t *testing.T) {
+	a := make([]string, 20000)
+	for i := range a {
+		a[i] = strconv.Itoa(i)
+	}
+	b := append([]string(nil), a...)
+	changed := 0
+	for i := 0; i < len(b); i += 100 {
+		b[i] = "changed " + b[i]
+		changed++
+	}
+	b[len(b)-1] = "changed end"
+	changed++
+	edits := 0
+	for _, o := range lineOps(a, b) {
+		if o.kind != ' ' {
+			edits++
+		}
+	}
+	if edits != 2*changed {
+		t.Fatalf("expected %d edits, got %d", 2*changed, edits)
+	}
+}
+
+func lcsLen(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/linkgraph"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+)
+
+func runLinks(args []string) {
+	fs := flag.NewFlagSet("links", flag.ExitOnError)
+	var (
+		dir       string
+		outDir    string
+		paperFile string
+		top       int
+	)
+	fs.StringVar(&dir, "dir", "discussion", "Directory of saved discussions, scanned recursively.")
+	fs.StringVar(&outDir, "output-dir", "", "Where links.json and links.md are written (default --dir).")
+	fs.StringVar(&paperFile, "papers", filepath.Join("docs", "Paper.md"), "Paper table receiving new arXiv papers as Todo; empty to skip.")
+	fs.IntVar(&top, "top", 20, "Number of notebooks and datasets in the most-referenced lists (0 for all).")
+	fs.Parse(args)
+
+	if outDir == "" {
+		outDir = dir
+	}
+	docs, err := linkgraph.Load(dir)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	if len(docs) == 0 {
+		log.Fatalf("No saved discussions found in %s", dir)
+	}
+	index := linkgraph.Build(docs, top)
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	jsonPath := filepath.Join(outDir, "links.json")
+	if err := storage.WriteFileAtomic(jsonPath, append(data, '\n'), 0o644, false); err != nil {
+		log.Fatalf("Failed to write %s: %v", jsonPath, err)
+	}
+	mdPath := filepath.Join(outDir, "links.md")
+	if err := storage.WriteFileAtomic(mdPath, []byte(index.Markdown(outDir)), 0o644, false); err != nil {
+		log.Fatalf("Failed to write %s: %v", mdPath, err)
+	}
+	fmt.Println(jsonPath)
+	fmt.Println(mdPath)
+
+	if paperFile == "" || len(index.Papers) == 0 {
+		return
+	}
+	doc, err := os.ReadFile(paperFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Failed to read %s: %v", paperFile, err)
+	}
+	out, added := linkgraph.AppendPapers(string(doc), index.Papers)
+	if len(added) == 0 {
+		fmt.Printf("%s is up to date (%d papers referenced)\n", paperFile, len(index.Papers))
+		return
+	}
+	if err := storage.WriteFileAtomic(paperFile, []byte(out), 0o644, false); err != nil {
+		log.Fatalf("Failed to write %s: %v", paperFile, err)
+	}
+	fmt.Printf("Added %d papers to %s\n", len(added), paperFile)
+}
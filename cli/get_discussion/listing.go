@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
+)
+
+// forumTarget describes where topics are listed from: a forum ID resolver for
+// the listing API, HTML listing pages tried in order when the API yields
+// nothing, and the URL path of its topics for the archive.
+type forumTarget struct {
+	name        string
+	resolve     func() (int, error)
+	listingURLs []string
+	topicPath   string
+}
+
+// selectForum picks the forum from the CLI selectors. Precedence is
+// --forum-id, --dataset, --model, --forum, COMPETITION, then the global
+// listing.
+func selectForum(c *client.Client, forumID int, dataset, model, forum, competition, sortKey, timeKey string) (forumTarget, error) {
+	switch {
+	case forumID > 0:
+		return forumTarget{
+			name:    fmt.Sprintf("forumId=%d", forumID),
+			resolve: func() (int, error) { return forumID, nil },
+		}, nil
+	case dataset != "":
+		owner, slug, err := ownerSlug("dataset", dataset)
+		if err != nil {
+			return forumTarget{}, err
+		}
+		return forumTarget{
+			name:        "dataset=" + dataset,
+			resolve:     func() (int, error) { return api.FetchDatasetForumID(c, owner, slug) },
+			listingURLs: []string{urlutil.BuildDatasetListingURL(owner, slug, sortKey, timeKey)},
+			topicPath:   "/datasets/" + owner + "/" + slug + "/discussion/",
+		}, nil
+	case model != "":
+		owner, slug, err := ownerSlug("model", model)
+		if err != nil {
+			return forumTarget{}, err
+		}
+		return forumTarget{
+			name:        "model=" + model,
+			resolve:     func() (int, error) { return api.FetchModelForumID(c, owner, slug) },
+			listingURLs: []string{urlutil.BuildModelListingURL(owner, slug, sortKey, timeKey)},
+			topicPath:   "/models/" + owner + "/" + slug + "/discussion/",
+		}, nil
+	case forum != "":
+		slug, ok := urlutil.SiteForumSlug(urlutil.NormalizeChoice(forum))
+		if !ok {
+			return forumTarget{}, fmt.Errorf("unknown forum: %s", forum)
+		}
+		return forumTarget{
+			name:        "forum=" + slug,
+			resolve:     func() (int, error) { return api.FetchForumIDBySlug(c, slug) },
+			listingURLs: []string{urlutil.BuildForumListingURL(slug, sortKey, timeKey)},
+			topicPath:   "/discussions/" + slug + "/",
+		}, nil
+	case competition != "":
+		return forumTarget{
+			name:    "competition=" + competition,
+			resolve: func() (int, error) { return api.FetchCompetitionForumID(c, competition) },
+			listingURLs: []string{
+				urlutil.BuildListingURL(sortKey, timeKey),
+				urlutil.BuildCompetitionListingURL(competition, sortKey, timeKey),
+			},
+			topicPath: "/competitions/" + competition + "/discussion/",
+		}, nil
+	}
+	return forumTarget{
+		name:        "global",
+		listingURLs: []string{urlutil.BuildListingURL(sortKey, timeKey)},
+	}, nil
+}
+
+// ownerSlug splits the owner/slug value of a --dataset or --model flag.
+func ownerSlug(flagName, value string) (owner, slug string, err error) {
+	owner, slug, ok := strings.Cut(strings.Trim(value, "/"), "/")
+	if !ok || owner == "" || slug == "" || strings.Contains(slug, "/") {
+		return "", "", fmt.Errorf("invalid --%s %q, expected owner/slug", flagName, value)
+	}
+	return owner, slug, nil
+}
+
+// listForum lists topic URLs of target, asking each source in order until
+// one returns topics.
+func listForum(c *client.Client, sources []discussion.DiscussionSource, target forumTarget, sortKey, timeKey string, limit int, filter api.TopicFilter) []string {
+	req := discussion.ListRequest{
+		ListingURLs: target.listingURLs,
+		TopicPath:   target.topicPath,
+		SortKey:     sortKey,
+		TimeKey:     timeKey,
+		Limit:       limit,
+		Filter:      filter,
+	}
+	if target.resolve != nil {
+		forumID, err := target.resolve()
+		if err != nil {
+			log.Printf("[warn] Forum lookup failed for %s: %v", target.name, err)
+		}
+		req.ForumID = forumID
+	}
+
+	for _, src := range sources {
+		urls, err := src.ListTopics(req)
+		if errors.Is(err, source.ErrUnsupported) {
+			continue
+		}
+		if errors.Is(err, source.ErrFilteredOut) {
+			// Unfiltered fallbacks would list exactly what the filters dropped.
+			log.Printf("[warn] No topics matched the filters for %s", target.name)
+			return nil
+		}
+		if err != nil {
+			log.Printf("[warn] %s listing failed for %s: %v", src.Name(), target.name, err)
+		}
+		if len(urls) > 0 {
+			return urls
+		}
+		log.Printf("[warn] No topics found via %s for %s", src.Name(), target.name)
+		if filter.Active() {
+			log.Printf("[warn] Listings other than the api carry no topic metadata; topic filters are ignored")
+		}
+	}
+	return nil
+}
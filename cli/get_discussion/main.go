@@ -5,16 +5,42 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/discussion"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/export"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/schema"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/snippets"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/source"
 	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/pkg/urlutil"
 )
 
+// subcommands maps the first CLI argument to a handler receiving the remaining
+// arguments. Without a known subcommand the discussion downloader runs.
+var subcommands = map[string]func(args []string){
+	"submit":     runSubmit,
+	"score-sync": runScoreSync,
+	"harvest":    runHarvest,
+	"links":      runLinks,
+	"diff":       runDiff,
+	"migrate":    runMigrate,
+	"verify":     runVerify,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			storage.LoadEnvFile(".env")
+			run(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		link       string
 		sort       string
@@ -24,6 +50,25 @@ func main() {
 		verbose    bool
 		limit      int
 		all        bool
+		query      string
+		forumID    int
+		dataset    string
+		model      string
+		forum      string
+		user       string
+		strict     bool
+		sourceList string
+		rawDir     string
+		minConf    float64
+		extract    bool
+		update     bool
+		rescan     bool
+		layout     storage.Options
+		formats    string
+		driftPath  string
+		since      string
+		until      string
+		filter     api.TopicFilter
 	)
 
 	flag.StringVar(&link, "link", "", "Download a single discussion by URL.")
@@ -33,23 +78,116 @@ func main() {
 	flag.Float64Var(&delay, "delay", 0.5, "Delay in seconds between requests.")
 	flag.IntVar(&limit, "limit", 10, "Max discussions to download (default 10).")
 	flag.BoolVar(&all, "all", false, "Download all discussions (ignores --limit).")
+	flag.IntVar(&forumID, "forum-id", 0, "List topics of the forum with this ID.")
+	flag.StringVar(&dataset, "dataset", "", "List topics of a dataset forum (owner/slug).")
+	flag.StringVar(&model, "model", "", "List topics of a model forum (owner/slug).")
+	flag.StringVar(&forum, "forum", "", "List topics of a site forum: general, getting-started, questions-and-answers, product-feedback, competition-hosting, accomplishments.")
+	flag.StringVar(&user, "user", "", "Archive the topics and comments written by this Kaggle user.")
+	flag.StringVar(&query, "query", "", "Search discussions by keyword instead of listing.")
+	flag.IntVar(&filter.MinVotes, "min-votes", 0, "Skip topics with fewer votes.")
+	flag.IntVar(&filter.MinComments, "min-comments", 0, "Skip topics with fewer comments.")
+	flag.StringVar(&since, "since", "", "Skip topics without activity since this date (YYYY-MM-DD or RFC 3339).")
+	flag.StringVar(&until, "until", "", "Skip topics whose last activity is after this date (YYYY-MM-DD or RFC 3339).")
+	flag.StringVar(&filter.Author, "author", "", "Only keep topics started by this author (display or user name).")
+	flag.BoolVar(&filter.ExcludePinned, "exclude-pinned", false, "Skip pinned topics.")
+	flag.StringVar(&sourceList, "sources", "api,html", "Fallback order of discussion sources: api, html, archive.")
+	flag.StringVar(&rawDir, "raw-dir", "", "Directory where the api source saves raw payloads and the archive source reads them.")
+	flag.Float64Var(&minConf, "min-content-confidence", 0, "Skip HTML pages whose extracted content confidence (0-1) is below this.")
+	flag.BoolVar(&update, "update", false, "Update saved threads in place: append new comments, refresh edited ones and mark deleted ones.")
+	flag.BoolVar(&rescan, "rescan", false, "Scan --output-dir for files added, moved or deleted by hand instead of trusting manifest.json.")
+	flag.StringVar(&layout.FilenameTemplate, "filename-template", storage.DefaultFilenameTemplate, "Path of each thread under --output-dir; placeholders {slug}, {id}, {author}, {date}, {year}, {month}, {competition}.")
+	flag.StringVar(&formats, "format", "md", "Comma-separated output formats: md, json, jsonl, html, csv (summary).")
+	flag.BoolVar(&layout.Fsync, "fsync", false, "Flush each written file to disk before moving on (slower, survives power loss).")
+	flag.BoolVar(&extract, "snippets", false, "Also save each fenced code block to <output-dir>/<slug>/snippets/.")
+	flag.BoolVar(&strict, "strict-schema", false, "Check API payloads for unknown, missing and retyped fields.")
+	flag.StringVar(&driftPath, "schema-report", "", "Drift report path for --strict-schema (default <output-dir>/schema_drift.json).")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging.")
 	flag.Parse()
 
 	storage.LoadEnvFile(".env")
 
+	if err := storage.ValidateTemplate(layout.FilenameTemplate); err != nil {
+		log.Fatal(err)
+	}
+	var err error
+	if since != "" {
+		if filter.Since, err = api.ParseFilterTime(since); err != nil {
+			log.Fatalf("Invalid --since: %v", err)
+		}
+	}
+	if until != "" {
+		if filter.Until, err = api.ParseFilterUntil(until); err != nil {
+			log.Fatalf("Invalid --until: %v", err)
+		}
+	}
+
+	if user != "" {
+		userDir, err := storage.UserDir(outputDir, user)
+		if err != nil {
+			log.Fatalf("Invalid --user: %v", err)
+		}
+		if link == "" {
+			outputDir = userDir
+		}
+	}
+	markdown, writers, err := export.ParseFormats(formats, outputDir, layout.Fsync)
+	if err != nil {
+		log.Fatalf("Invalid --format: %v", err)
+	}
+	if update && !markdown {
+		log.Fatal("--update needs the md format")
+	}
+
 	httpClient := client.NewClient(verbose)
+	var recorder *schema.Recorder
+	if strict {
+		recorder = schema.NewRecorder()
+		httpClient.SetSchemaRecorder(recorder)
+	}
+
+	sources, err := discussion.ParseSources(sourceList, httpClient, discussion.SourceOptions{
+		RawDir:        rawDir,
+		MinConfidence: minConf,
+		Fsync:         layout.Fsync,
+	})
+	if err != nil {
+		log.Fatalf("Invalid --sources: %v", err)
+	}
 
 	var urls []string
 
+	effectiveLimit := limit
+	if all {
+		effectiveLimit = 0
+	}
+	competition := os.Getenv("COMPETITION")
+
+	var (
+		userMessageIDs map[int]bool
+		userPosts      []api.UserPost
+	)
+
 	if link != "" {
 		urls = []string{urlutil.CanonicalizeURL(link)}
-	} else {
-		effectiveLimit := limit
-		if all {
-			effectiveLimit = 0
+	} else if user != "" {
+		userPosts, err = api.FetchUserPosts(httpClient, user, effectiveLimit)
+		if err != nil {
+			log.Printf("[warn] User messages API failed: %v", err)
 		}
-
+		if len(userPosts) == 0 {
+			log.Printf("[warn] No posts found for user=%s", user)
+		}
+		userMessageIDs = map[int]bool{}
+		for _, p := range userPosts {
+			userMessageIDs[p.MessageID] = true
+		}
+		urls = api.TopicLinks(userPosts)
+	} else if query != "" {
+		if filter.Active() {
+			log.Printf("[warn] Topic filters are not applied to --query results")
+		}
+		urls = searchDiscussions(httpClient, query, competition, effectiveLimit)
+	} else {
 		sortKey := urlutil.NormalizeChoice(sort)
 		timeKey := urlutil.NormalizeChoice(timeFilter)
 
@@ -64,64 +202,175 @@ func main() {
 			}
 		}
 
-		competition := os.Getenv("COMPETITION")
-
-		if competition != "" {
-			forumID, err := api.FetchCompetitionForumID(httpClient, competition)
-			if err != nil {
-				log.Printf("[warn] Competition API failed: %v", err)
-			} else {
-				urls, err = api.FetchTopicListByForumID(httpClient, forumID, sortKey, timeKey, effectiveLimit)
-				if err != nil {
-					log.Printf("[warn] Topic list API failed: %v", err)
-					urls = nil
-				}
-				if len(urls) == 0 {
-					log.Printf("[warn] No topics found via forumId=%d competition=%s", forumID, competition)
-				}
-			}
-		}
-
-		if len(urls) == 0 {
-			listingURL := urlutil.BuildListingURL(sortKey, timeKey)
-			body, err := httpClient.FetchBody(listingURL, nil)
-			if err != nil {
-				log.Printf("[warn] Failed to fetch listing: %v", err)
-			} else {
-				urls = discussion.ExtractDiscussionLinksFromHTML(body, listingURL)
-				if effectiveLimit > 0 && len(urls) > effectiveLimit {
-					urls = urls[:effectiveLimit]
-				}
-			}
-
-			if len(urls) == 0 && competition != "" {
-				compURL := urlutil.BuildCompetitionListingURL(competition, sortKey, timeKey)
-				httpClient.LogInfo("Retrying with competition listing url=%s", compURL)
-				body, err = httpClient.FetchBody(compURL, nil)
-				if err != nil {
-					log.Printf("[warn] Competition listing failed: %v", err)
-				} else {
-					urls = discussion.ExtractDiscussionLinksFromHTML(body, compURL)
-					if effectiveLimit > 0 && len(urls) > effectiveLimit {
-						urls = urls[:effectiveLimit]
-					}
-					if len(urls) == 0 {
-						log.Printf("[warn] No discussion links found on competition listing url=%s", compURL)
-					}
-				}
-			}
+		target, err := selectForum(httpClient, forumID, dataset, model, forum, competition, sortKey, timeKey)
+		if err != nil {
+			log.Fatal(err)
 		}
+		urls = listForum(httpClient, sources, target, sortKey, timeKey, effectiveLimit, filter)
 	}
 
-	existingByLink := storage.LoadExistingLinks(outputDir)
+	if strict && driftPath == "" {
+		driftPath = filepath.Join(outputDir, "schema_drift.json")
+	}
+	lock := lockOutput(outputDir)
+	defer lock.Unlock()
+	manifest := storage.LoadManifest(outputDir, rescan)
+	existingByLink := manifest.Links()
 	d := time.Duration(float64(time.Second) * delay)
 
-	for discussionItem := range discussion.IterDiscussions(urls, httpClient, d) {
-		path, err := storage.SaveDiscussion(discussionItem, outputDir, existingByLink)
+	for discussionItem := range source.IterDiscussions(urls, sources, d) {
+		if user != "" {
+			markUserPosts(discussionItem, user, userMessageIDs)
+		}
+		var path string
+		var err error
+		switch {
+		case !markdown:
+			path = storage.ThreadPath(discussionItem, outputDir, existingByLink, layout)
+		case update:
+			var changes storage.Changes
+			path, changes, err = storage.UpdateDiscussion(discussionItem, outputDir, existingByLink, layout)
+			if err == nil {
+				fmt.Printf("%s: %s\n", path, changes)
+			}
+		default:
+			path, err = storage.SaveDiscussion(discussionItem, outputDir, existingByLink, layout)
+			if err == nil {
+				fmt.Println(path)
+			}
+		}
 		if err != nil {
 			log.Printf("[warn] Failed to save %s: %v", discussionItem.Link, err)
 			continue
 		}
-		fmt.Println(path)
+		var exported []string
+		for _, w := range writers {
+			if err := w.Write(discussionItem, path); err != nil {
+				log.Printf("[warn] Failed to export %s: %v", discussionItem.Link, err)
+				continue
+			}
+			exported = append(exported, w.Path(path))
+		}
+		if !markdown && len(exported) > 0 {
+			fmt.Println(strings.Join(exported, " "))
+		}
+		// Threads exported without Markdown are listed too, so that their
+		// files move with them when the title changes.
+		if err := manifest.Record(discussionItem, path); err != nil {
+			log.Printf("[warn] Failed to record %s in the manifest: %v", path, err)
+		}
+		if extract {
+			saveSnippets(httpClient, discussionItem, path, layout.Fsync)
+		}
+	}
+
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			log.Printf("[warn] Failed to write export: %v", err)
+		}
+	}
+	if err := manifest.Save(layout.Fsync); err != nil {
+		log.Printf("[warn] Failed to write manifest: %v", err)
+	}
+	if user != "" {
+		writeUserIndex(outputDir, user, userPosts, manifest.Links(), layout.Fsync)
+	}
+	if recorder != nil {
+		writeDriftReport(recorder, driftPath, layout.Fsync)
+	}
+}
+
+// lockOutput takes the lock of an output directory so that two runs do not
+// write the same files, exiting when another run holds it.
+func lockOutput(dir string) *storage.Lock {
+	lock, err := storage.LockDir(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return lock
+}
+
+// saveSnippets writes the code blocks of d next to its Markdown file at path.
+func saveSnippets(c *client.Client, d *discussion.Discussion, path string, sync bool) {
+	dir := filepath.Join(strings.TrimSuffix(path, filepath.Ext(path)), "snippets")
+	entries, err := snippets.Write(dir, snippets.Extract(d), sync)
+	if err != nil {
+		log.Printf("[warn] Failed to save snippets for %s: %v", d.Link, err)
+		return
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Status, "syntax error") {
+			log.Printf("[warn] %s: %s", filepath.Join(dir, e.File), e.Status)
+		}
+	}
+	c.LogInfo("Saved %d snippets to %s", len(entries), dir)
+}
+
+func writeDriftReport(r *schema.Recorder, path string, sync bool) {
+	report := r.Report()
+	if err := r.WriteReport(path, sync); err != nil {
+		log.Printf("[warn] Failed to write schema report: %v", err)
+		return
+	}
+	if len(report) > 0 {
+		log.Printf("[warn] Schema drift detected (%s), see %s", schema.Summary(report), path)
+		return
+	}
+	log.Printf("[info] No schema drift, report written to %s", path)
+}
+
+// searchDiscussions finds topics matching query via the search API, falling
+// back to scraping the HTML search page.
+func searchDiscussions(c *client.Client, query, competition string, limit int) []string {
+	urls, err := api.SearchDiscussions(c, query, competition, limit)
+	if err != nil {
+		log.Printf("[warn] Search API failed: %v", err)
+	}
+	if len(urls) > 0 {
+		return urls
+	}
+
+	searchURL := urlutil.BuildSearchURL(query, competition)
+	c.LogInfo("Retrying with search page url=%s", searchURL)
+	body, err := c.FetchBody(searchURL, nil)
+	if err != nil {
+		log.Printf("[warn] Search page failed: %v", err)
+		return nil
+	}
+	urls = discussion.ExtractDiscussionLinksFromHTML(body, searchURL)
+	if competition != "" {
+		urls = filterCompetition(urls, competition)
+	}
+	if limit > 0 && len(urls) > limit {
+		urls = urls[:limit]
+	}
+	if len(urls) == 0 {
+		log.Printf("[warn] No discussions found for query=%q", query)
+	}
+	return urls
+}
+
+// filterCompetition keeps topic URLs that belong to the competition forum.
+func filterCompetition(urls []string, competition string) []string {
+	var out []string
+	for _, u := range urls {
+		if urlutil.InCompetitionForum(u, competition) {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// markUserPosts records in the front matter which messages of d were written
+// by user.
+func markUserPosts(d *discussion.Discussion, user string, knownIDs map[int]bool) {
+	ids := discussion.MarkUserMessages(d, user, knownIDs)
+	if d.Extra == nil {
+		d.Extra = map[string]any{}
+	}
+	d.Extra["archived_user"] = user
+	d.Extra["user_comment_ids"] = ids
+	if len(d.Messages) > 0 && d.Messages[0].IsMain && len(ids) > 0 && ids[0] == d.Messages[0].ID {
+		d.Extra["user_started_topic"] = true
 	}
 }
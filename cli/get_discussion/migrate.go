@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/textdiff"
+)
+
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var (
+		dir     string
+		dryRun  bool
+		backup  bool
+		unified bool
+	)
+	flags.StringVar(&dir, "dir", "discussion", "Directory of saved discussions, scanned recursively.")
+	flags.BoolVar(&dryRun, "dry-run", false, "Print the changes without writing any file.")
+	flags.BoolVar(&backup, "backup", false, "Keep a copy of each migrated file as <file>.bak.")
+	flags.BoolVar(&unified, "unified", false, "Also print a unified diff of each file.")
+	flags.Parse(args)
+
+	// Threads of --user and harvest-solutions runs live below dir under
+	// their own locks, so migrate takes those too.
+	var locks []*storage.Lock
+	if !dryRun {
+		var err error
+		if locks, err = storage.LockTree(dir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var pending []*storage.Migration
+	failed := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return err
+		}
+		mg, err := storage.MigrateFile(path)
+		if err != nil {
+			log.Printf("[warn] %v", err)
+			failed++
+			return nil
+		}
+		if mg != nil {
+			pending = append(pending, mg)
+		}
+		return nil
+	})
+	if err != nil {
+		storage.UnlockAll(locks)
+		log.Fatalf("Failed to read %s: %v", dir, err)
+	}
+
+	migrated := 0
+	manifests := map[string]*storage.Manifest{}
+	for _, mg := range pending {
+		fmt.Printf("%s: v%d -> v%d: %s\n", mg.Path, mg.From, mg.To, strings.Join(append(mg.Changes, "schema_version"), ", "))
+		if unified {
+			fmt.Print(textdiff.Unified("a/"+mg.Path, "b/"+mg.Path, mg.Old, mg.New, 1))
+		}
+		if dryRun {
+			continue
+		}
+		if err := mg.Apply(backup); err != nil {
+			log.Printf("[warn] Failed to write %s: %v", mg.Path, err)
+			failed++
+			continue
+		}
+		migrated++
+		root := storage.ManifestDir(dir, mg.Path)
+		if manifests[root] == nil {
+			manifests[root] = storage.LoadManifest(root, false)
+		}
+		if err := manifests[root].Refresh(mg.Path); err != nil {
+			log.Printf("[warn] Failed to update the manifest entry of %s: %v", mg.Path, err)
+		}
+	}
+	for root, m := range manifests {
+		if err := m.Save(false); err != nil {
+			log.Printf("[warn] Failed to save the manifest of %s: %v", root, err)
+		}
+	}
+
+	storage.UnlockAll(locks)
+	switch {
+	case dryRun:
+		fmt.Printf("%d files would be migrated to schema version %d\n", len(pending), storage.SchemaVersion)
+	default:
+		fmt.Printf("Migrated %d files to schema version %d\n", migrated, storage.SchemaVersion)
+	}
+	if failed > 0 {
+		log.Fatalf("%d files could not be migrated", failed)
+	}
+}
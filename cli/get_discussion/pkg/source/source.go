@@ -0,0 +1,148 @@
+// Package source defines the discussion sources the downloader reads from.
+// Implement DiscussionSource to plug in a backend of your own and pass it to
+// IterDiscussions alongside, or instead of, the built-in ones.
+package source
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported is returned when a source cannot serve a request at all,
+// as opposed to failing while trying.
+var ErrUnsupported = errors.New("unsupported by source")
+
+// ErrFilteredOut is returned by ListTopics when the listing succeeded but no
+// topic passed the request's filter. Callers must not fall back to sources
+// that cannot filter, which would list the topics the filter dropped.
+var ErrFilteredOut = errors.New("no topics matched the filters")
+
+// DiscussionSource lists topics and fetches discussions from one backend.
+// IterDiscussions tries a list of sources in order for every URL.
+type DiscussionSource interface {
+	Name() string
+	ListTopics(req ListRequest) ([]string, error)
+	FetchDiscussion(rawURL string) (*Discussion, error)
+}
+
+// ListRequest describes a topic listing. Each source uses the parts it
+// understands: ForumID for the API, ListingURLs for HTML, TopicPath for the
+// archive.
+type ListRequest struct {
+	ForumID     int
+	ListingURLs []string
+	// TopicPath is contained in the URL of every topic of the forum, e.g.
+	// "/competitions/titanic/discussion/". Empty lists every forum.
+	TopicPath string
+	SortKey   string
+	TimeKey   string
+	Limit     int
+	Filter    TopicFilter
+}
+
+// TopicFilter drops topics from a listing before they are downloaded.
+// Zero values disable the corresponding check.
+type TopicFilter struct {
+	MinVotes      int
+	MinComments   int
+	Since         time.Time
+	Until         time.Time
+	Author        string
+	ExcludePinned bool
+}
+
+// Active reports whether any criterion is set.
+func (f TopicFilter) Active() bool {
+	return f != TopicFilter{}
+}
+
+// Discussion holds all metadata and content for a single Kaggle discussion.
+type Discussion struct {
+	Title         string
+	Link          string
+	Author        string
+	Comments      string
+	PublishedDate string
+	ContentMD     string
+	// Messages is the structured thread, opening post first. It is empty when
+	// the discussion came from the HTML fallback.
+	Messages []Message
+	// Extra holds additional front matter keys written after the standard
+	// ones. Values are anything the frontmatter package encodes: strings,
+	// numbers, bools, times, lists and maps.
+	Extra map[string]any
+	// Source names the DiscussionSource the discussion came from and
+	// FetchedAt when it was downloaded. Warnings collects what went wrong
+	// on the way, such as sources that failed before it.
+	Source    string
+	FetchedAt time.Time
+	Warnings  []string
+}
+
+// Message is a single post of a thread.
+type Message struct {
+	ID         int
+	Author     string
+	AuthorName string
+	Body       string
+	IsMain     bool
+	Votes      int
+}
+
+// IterDiscussions yields Discussion values for each URL, trying sources in
+// order until one succeeds.
+func IterDiscussions(urls []string, sources []DiscussionSource, delay time.Duration) <-chan *Discussion {
+	ch := make(chan *Discussion)
+	go func() {
+		defer close(ch)
+		for _, rawURL := range urls {
+			d, err := fetchFromSources(sources, rawURL)
+			if err != nil {
+				log.Printf("[warn] Skipping %s: %v", rawURL, err)
+				continue
+			}
+			if strings.TrimSpace(d.ContentMD) == "" {
+				log.Printf("[warn] Empty content for %s", rawURL)
+				d.Warnings = append(d.Warnings, "empty content")
+			}
+			ch <- d
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+	}()
+	return ch
+}
+
+// fetchFromSources returns the discussion of the first source that serves
+// rawURL, noting the failures of the sources before it in its Warnings.
+func fetchFromSources(sources []DiscussionSource, rawURL string) (*Discussion, error) {
+	var lastErr error = fmt.Errorf("no discussion source configured")
+	var failures []string
+	for i, src := range sources {
+		d, err := src.FetchDiscussion(rawURL)
+		if err == nil {
+			d.Source = src.Name()
+			if d.FetchedAt.IsZero() {
+				d.FetchedAt = time.Now().UTC()
+			}
+			d.Warnings = append(failures, d.Warnings...)
+			return d, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s failed: %v", src.Name(), err))
+		lastErr = err
+		if i == len(sources)-1 {
+			break
+		}
+		next := sources[i+1].Name()
+		if errors.Is(err, ErrUnsupported) {
+			log.Printf("[warn] %s cannot handle %s: %v — using %s", src.Name(), rawURL, err, next)
+		} else {
+			log.Printf("[warn] %s failed for %s: %v — falling back to %s", src.Name(), rawURL, err, next)
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,52 @@
+package source
+
+import (
+	"errors"
+	"testing"
+)
+
+type stubSource struct {
+	name string
+	d    *Discussion
+	err  error
+}
+
+func (s *stubSource) Name() string                             { return s.name }
+func (s *stubSource) ListTopics(ListRequest) ([]string, error) { return nil, ErrUnsupported }
+func (s *stubSource) FetchDiscussion(string) (*Discussion, error) {
+	return s.d, s.err
+}
+
+func TestFetchFromSourcesFallsBack(t *testing.T) {
+	want := &Discussion{Title: "ok"}
+	sources := []DiscussionSource{
+		&stubSource{name: "a", err: ErrUnsupported},
+		&stubSource{name: "b", err: errors.New("boom")},
+		&stubSource{name: "c", d: want},
+	}
+	got, err := fetchFromSources(sources, "https://www.kaggle.com/discussion/1")
+	if err != nil || got != want {
+		t.Fatalf("unexpected result: %v err=%v", got, err)
+	}
+	if got.Source != "c" || got.FetchedAt.IsZero() {
+		t.Errorf("provenance not recorded: source=%q fetched_at=%v", got.Source, got.FetchedAt)
+	}
+	if len(got.Warnings) != 2 || got.Warnings[1] != "b failed: boom" {
+		t.Errorf("unexpected warnings: %q", got.Warnings)
+	}
+}
+
+func TestIterDiscussionsSkipsFailures(t *testing.T) {
+	ok := &stubSource{name: "custom", d: &Discussion{Title: "ok", ContentMD: "body"}}
+	var got []*Discussion
+	for d := range IterDiscussions([]string{"a", "b"}, []DiscussionSource{ok}, 0) {
+		got = append(got, d)
+	}
+	if len(got) != 2 || got[0].Source != "custom" {
+		t.Fatalf("unexpected discussions: %+v", got)
+	}
+	failing := &stubSource{name: "bad", err: errors.New("boom")}
+	for d := range IterDiscussions([]string{"a"}, []DiscussionSource{failing}, 0) {
+		t.Fatalf("unexpected discussion: %+v", d)
+	}
+}
@@ -8,8 +8,13 @@ import (
 )
 
 const (
+	siteURL            = "https://www.kaggle.com"
 	baseListingURL     = "https://www.kaggle.com/discussions"
 	competitionListURL = "https://www.kaggle.com/competitions/%s/discussion"
+	searchPageURL      = "https://www.kaggle.com/search"
+	datasetListURL     = "https://www.kaggle.com/datasets/%s/%s/discussion"
+	forumListURL       = "https://www.kaggle.com/discussions/%s"
+	modelListURL       = "https://www.kaggle.com/models/%s/%s/discussion"
 )
 
 var sortOptions = map[string]string{
@@ -26,6 +31,15 @@ var timeFilterOptions = map[string]string{
 	"today":        "today",
 }
 
+var siteForums = map[string]string{
+	"general":               "general",
+	"getting_started":       "getting-started",
+	"questions_and_answers": "questions-and-answers",
+	"product_feedback":      "product-feedback",
+	"competition_hosting":   "competition-hosting",
+	"accomplishments":       "accomplishments",
+}
+
 func EnsureURL(raw string) string {
 	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
 		return "https://" + strings.TrimLeft(raw, "/")
@@ -43,6 +57,23 @@ func CanonicalizeURL(raw string) string {
 	return u.String()
 }
 
+// AbsoluteURL resolves a root- or protocol-relative reference against
+// kaggle.com. Absolute URLs, fragments and other references are returned as is.
+func AbsoluteURL(ref string) string {
+	if !strings.HasPrefix(ref, "/") {
+		return ref
+	}
+	if strings.HasPrefix(ref, "//") {
+		return "https:" + ref
+	}
+	return siteURL + ref
+}
+
+// ProfileURL returns the Kaggle profile page of a user name.
+func ProfileURL(userName string) string {
+	return siteURL + "/" + url.PathEscape(userName)
+}
+
 var topicIDRegex = regexp.MustCompile(`/discussion/(\d+)`)
 
 func ExtractTopicID(rawURL string) (int, bool) {
@@ -55,6 +86,28 @@ func ExtractTopicID(rawURL string) (int, bool) {
 	return id, true
 }
 
+var competitionRegex = regexp.MustCompile(`/competitions?/([A-Za-z0-9_-]+)`)
+
+// CompetitionSlug returns the competition a discussion URL belongs to.
+func CompetitionSlug(rawURL string) (string, bool) {
+	m := competitionRegex.FindStringSubmatch(rawURL)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// InCompetitionForum reports whether raw is a discussion of the competition's
+// forum.
+func InCompetitionForum(raw, competition string) bool {
+	return strings.Contains(raw, "/competitions/"+competition+"/discussion/")
+}
+
+// IsDiscussionURL reports whether raw points at a discussion thread or listing.
+func IsDiscussionURL(raw string) bool {
+	return strings.Contains(raw, "/discussions/") || strings.Contains(raw, "/discussion/")
+}
+
 func SortParam(key string) (string, bool) {
 	v, ok := sortOptions[key]
 	return v, ok
@@ -65,22 +118,39 @@ func TimeFilterParam(key string) (string, bool) {
 	return v, ok
 }
 
+// SiteForumSlug maps a normalized forum choice to its URL slug.
+func SiteForumSlug(key string) (string, bool) {
+	v, ok := siteForums[key]
+	return v, ok
+}
+
 func BuildListingURL(sortKey, timeKey string) string {
-	params := url.Values{}
-	if v, ok := SortParam(sortKey); ok {
-		params.Set("sort", v)
-	}
-	if v, ok := TimeFilterParam(timeKey); ok {
-		params.Set("time", v)
-	}
-	if len(params) == 0 {
-		return baseListingURL
-	}
-	return baseListingURL + "?" + params.Encode()
+	return withListingParams(baseListingURL, sortKey, timeKey)
 }
 
 func BuildCompetitionListingURL(competition, sortKey, timeKey string) string {
 	base := fmt.Sprintf(competitionListURL, strings.TrimSpace(competition))
+	return withListingParams(base, sortKey, timeKey)
+}
+
+// BuildDatasetListingURL returns the discussion listing of a dataset.
+func BuildDatasetListingURL(owner, slug, sortKey, timeKey string) string {
+	base := fmt.Sprintf(datasetListURL, strings.TrimSpace(owner), strings.TrimSpace(slug))
+	return withListingParams(base, sortKey, timeKey)
+}
+
+// BuildModelListingURL returns the discussion listing of a model.
+func BuildModelListingURL(owner, slug, sortKey, timeKey string) string {
+	base := fmt.Sprintf(modelListURL, strings.TrimSpace(owner), strings.TrimSpace(slug))
+	return withListingParams(base, sortKey, timeKey)
+}
+
+// BuildForumListingURL returns the listing of a site forum slug.
+func BuildForumListingURL(slug, sortKey, timeKey string) string {
+	return withListingParams(fmt.Sprintf(forumListURL, slug), sortKey, timeKey)
+}
+
+func withListingParams(base, sortKey, timeKey string) string {
 	params := url.Values{}
 	if v, ok := SortParam(sortKey); ok {
 		params.Set("sort", v)
@@ -94,6 +164,17 @@ func BuildCompetitionListingURL(competition, sortKey, timeKey string) string {
 	return base + "?" + params.Encode()
 }
 
+// BuildSearchURL returns the HTML search page for query, scoped to the
+// competition forum when competition is set.
+func BuildSearchURL(query, competition string) string {
+	query = strings.TrimSpace(query)
+	if competition != "" {
+		base := fmt.Sprintf(competitionListURL, strings.TrimSpace(competition))
+		return base + "?" + url.Values{"search": {query}}.Encode()
+	}
+	return searchPageURL + "?" + url.Values{"q": {query + " in:discussions"}}.Encode()
+}
+
 func NormalizeChoice(s string) string {
 	s = strings.TrimSpace(strings.ToLower(s))
 	s = strings.ReplaceAll(s, " ", "_")
@@ -19,6 +19,23 @@ func TestCanonicalizeURL(t *testing.T) {
 	}
 }
 
+func TestAbsoluteURL(t *testing.T) {
+	cases := map[string]string{
+		"/code/alice/eda":                "https://www.kaggle.com/code/alice/eda",
+		"//storage.googleapis.com/x.png": "https://storage.googleapis.com/x.png",
+		"https://example.com/a":          "https://example.com/a",
+		"#comment-1":                     "#comment-1",
+	}
+	for in, want := range cases {
+		if got := AbsoluteURL(in); got != want {
+			t.Fatalf("AbsoluteURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := ProfileURL("bob"); got != "https://www.kaggle.com/bob" {
+		t.Fatalf("unexpected profile url: %s", got)
+	}
+}
+
 func TestExtractTopicID(t *testing.T) {
 	id, ok := ExtractTopicID("https://www.kaggle.com/discussion/98765/foo")
 	if !ok || id != 98765 {
@@ -26,6 +43,30 @@ func TestExtractTopicID(t *testing.T) {
 	}
 }
 
+func TestCompetitionSlug(t *testing.T) {
+	slug, ok := CompetitionSlug("https://www.kaggle.com/competitions/titanic/discussion/123")
+	if !ok || slug != "titanic" {
+		t.Fatalf("unexpected slug: %q ok=%v", slug, ok)
+	}
+	if _, ok := CompetitionSlug("https://www.kaggle.com/discussions/general/123"); ok {
+		t.Fatalf("site forum has no competition")
+	}
+}
+
+func TestInCompetitionForum(t *testing.T) {
+	if !InCompetitionForum("https://www.kaggle.com/competitions/titanic/discussion/123", "titanic") {
+		t.Fatalf("expected topic in forum")
+	}
+	for _, u := range []string{
+		"https://www.kaggle.com/competitions/titanic/overview",
+		"https://www.kaggle.com/competitions/titanic-2/discussion/123",
+	} {
+		if InCompetitionForum(u, "titanic") {
+			t.Fatalf("%s is not in the titanic forum", u)
+		}
+	}
+}
+
 func TestBuildListingURL(t *testing.T) {
 	got := BuildListingURL("hotness", "last_7_days")
 	if got == "https://www.kaggle.com/discussions" {
@@ -43,6 +84,31 @@ func TestBuildCompetitionListingURL(t *testing.T) {
 	}
 }
 
+func TestBuildForumListingURLs(t *testing.T) {
+	if got := BuildModelListingURL("google", "gemma", "", ""); got != "https://www.kaggle.com/models/google/gemma/discussion" {
+		t.Fatalf("unexpected model listing URL: %s", got)
+	}
+	if got := BuildDatasetListingURL("owner", "data", "", ""); got != "https://www.kaggle.com/datasets/owner/data/discussion" {
+		t.Fatalf("unexpected dataset url: %s", got)
+	}
+	slug, ok := SiteForumSlug(NormalizeChoice("Getting-Started"))
+	if !ok || slug != "getting-started" {
+		t.Fatalf("unexpected forum slug: %s ok=%v", slug, ok)
+	}
+	if got := BuildForumListingURL(slug, "most_votes", ""); got != "https://www.kaggle.com/discussions/getting-started?sort=most-votes" {
+		t.Fatalf("unexpected forum url: %s", got)
+	}
+}
+
+func TestBuildSearchURL(t *testing.T) {
+	if got := BuildSearchURL("target encoding", "titanic"); got != "https://www.kaggle.com/competitions/titanic/discussion?search=target+encoding" {
+		t.Fatalf("unexpected competition search url: %s", got)
+	}
+	if got := BuildSearchURL("leak", ""); got != "https://www.kaggle.com/search?q=leak+in%3Adiscussions" {
+		t.Fatalf("unexpected search url: %s", got)
+	}
+}
+
 func TestNormalizeChoice(t *testing.T) {
 	if got := NormalizeChoice("Recent Comments"); got != "recent_comments" {
 		t.Fatalf("unexpected normalized: %s", got)
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/scoreboard"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+)
+
+func runScoreSync(args []string) {
+	fs := flag.NewFlagSet("score-sync", flag.ExitOnError)
+	var (
+		competition   string
+		scoreFile     string
+		lowerIsBetter bool
+		dryRun        bool
+		verbose       bool
+	)
+	fs.StringVar(&competition, "competition", os.Getenv("COMPETITION"), "Competition slug (default $COMPETITION).")
+	fs.StringVar(&scoreFile, "file", filepath.Join("docs", "Score.md"), "Score table to update.")
+	fs.BoolVar(&lowerIsBetter, "lower-is-better", false, "Treat lower scores as better when highlighting the best rows.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the updated document instead of writing it.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose logging.")
+	fs.Parse(args)
+
+	if competition == "" {
+		log.Fatal("--competition or COMPETITION is required")
+	}
+	creds := client.CredentialsFromEnv()
+	if !creds.Valid() {
+		log.Fatal("KAGGLE_USERNAME/KAGGLE_KEY or KAGGLE_API_TOKEN must be set")
+	}
+
+	data, err := os.ReadFile(scoreFile)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", scoreFile, err)
+	}
+
+	c := client.NewAuthenticatedClient(verbose, creds)
+	subs, err := api.ListSubmissions(c, competition)
+	if err != nil {
+		log.Fatalf("Submissions API failed: %v", err)
+	}
+
+	table := scoreboard.Merge(scoreboard.Parse(string(data)), subs)
+	out := scoreboard.Render(string(data), table, lowerIsBetter)
+
+	if dryRun {
+		fmt.Print(out)
+		return
+	}
+	if out == string(data) {
+		fmt.Printf("%s is up to date (%d submissions)\n", scoreFile, len(table.Rows))
+		return
+	}
+	if err := storage.WriteFileAtomic(scoreFile, []byte(out), 0o644, false); err != nil {
+		log.Fatalf("Failed to write %s: %v", scoreFile, err)
+	}
+	fmt.Printf("Updated %s (%d submissions)\n", scoreFile, len(table.Rows))
+}
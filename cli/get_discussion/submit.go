@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/client"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/submission"
+)
+
+func runSubmit(args []string) {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	var (
+		file        string
+		message     string
+		competition string
+		historyPath string
+		interval    float64
+		timeout     float64
+		verbose     bool
+	)
+	fs.StringVar(&file, "file", "", "Submission CSV to upload (e.g. data/submissions/sub.csv).")
+	fs.StringVar(&message, "message", "", "Submission description.")
+	fs.StringVar(&competition, "competition", os.Getenv("COMPETITION"), "Competition slug (default $COMPETITION).")
+	fs.StringVar(&historyPath, "history", filepath.Join("data", "submissions", "history.jsonl"), "JSONL file recording every submission.")
+	fs.Float64Var(&interval, "poll-interval", 10, "Seconds between scoring status checks.")
+	fs.Float64Var(&timeout, "timeout", 1800, "Seconds to wait for scoring before giving up.")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose logging.")
+	fs.Parse(args)
+
+	if file == "" {
+		log.Fatal("--file is required")
+	}
+	if competition == "" {
+		log.Fatal("--competition or COMPETITION is required")
+	}
+	creds := client.CredentialsFromEnv()
+	if !creds.Valid() {
+		log.Fatal("KAGGLE_USERNAME/KAGGLE_KEY or KAGGLE_API_TOKEN must be set")
+	}
+	if message == "" {
+		message = filepath.Base(file)
+	}
+
+	c := client.NewAuthenticatedClient(verbose, creds)
+	submittedAt := time.Now().UTC()
+
+	teamBefore, rankBefore := currentRank(c, competition)
+
+	ref, err := submission.Upload(c, competition, file, message)
+	if err != nil {
+		recordSubmission(historyPath, submission.HistoryRecord{
+			SubmittedAt: submittedAt, Competition: competition, File: file,
+			Message: message, Status: "upload_failed", Remaining: -1, Error: err.Error(),
+		})
+		log.Fatalf("Submission failed: %v", err)
+	}
+	fmt.Printf("Submitted %s (ref=%d), waiting for score...\n", file, ref)
+
+	sub, subs, err := submission.Poll(c, competition, ref,
+		time.Duration(float64(time.Second)*interval), time.Duration(float64(time.Second)*timeout))
+	result := submission.Result{Submission: sub, RankBefore: rankBefore, Remaining: -1}
+	if err != nil {
+		log.Printf("[warn] Polling failed: %v", err)
+	} else {
+		team := sub.TeamName
+		if team == "" {
+			team = teamBefore
+		}
+		if board, err := api.FetchLeaderboard(c, competition); err != nil {
+			log.Printf("[warn] Leaderboard API failed: %v", err)
+		} else {
+			result.RankAfter = submission.TeamRank(board, team)
+		}
+		if info, err := api.FetchCompetitionInfo(c, competition); err != nil {
+			log.Printf("[warn] Competition info failed: %v", err)
+		} else {
+			result.Remaining = submission.RemainingToday(subs, info.MaxDailySubmissions, time.Now())
+		}
+		printResult(result)
+	}
+
+	rec := submission.HistoryRecord{
+		SubmittedAt:  submittedAt,
+		Competition:  competition,
+		File:         file,
+		Message:      message,
+		Ref:          ref,
+		Status:       result.Submission.Status,
+		PublicScore:  result.Submission.PublicScore,
+		PrivateScore: result.Submission.PrivateScore,
+		RankBefore:   result.RankBefore,
+		RankAfter:    result.RankAfter,
+		Remaining:    result.Remaining,
+		Error:        result.Submission.ErrorDescription,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	recordSubmission(historyPath, rec)
+}
+
+// currentRank returns our team name and leaderboard rank before submitting.
+// Failures only cost the rank change line, so they are logged and ignored.
+func currentRank(c *client.Client, competition string) (string, int) {
+	subs, err := api.ListSubmissions(c, competition)
+	if err != nil {
+		log.Printf("[warn] Submissions API failed: %v", err)
+	}
+	if len(subs) == 0 {
+		return "", 0
+	}
+	team := subs[0].TeamName
+	board, err := api.FetchLeaderboard(c, competition)
+	if err != nil {
+		log.Printf("[warn] Leaderboard API failed: %v", err)
+		return team, 0
+	}
+	return team, submission.TeamRank(board, team)
+}
+
+func printResult(r submission.Result) {
+	s := r.Submission
+	if submission.IsError(s.Status) {
+		fmt.Printf("Status: %s (%s)\n", s.Status, s.ErrorDescription)
+	} else {
+		fmt.Printf("Public score: %s\n", s.PublicScore)
+	}
+	switch {
+	case r.RankAfter == 0:
+		fmt.Println("Rank: unknown")
+	case r.RankBefore == 0:
+		fmt.Printf("Rank: %d\n", r.RankAfter)
+	default:
+		fmt.Printf("Rank: %d -> %d (%+d)\n", r.RankBefore, r.RankAfter, r.RankChange())
+	}
+	if r.Remaining >= 0 {
+		fmt.Printf("Remaining submissions today: %d\n", r.Remaining)
+	}
+}
+
+func recordSubmission(path string, rec submission.HistoryRecord) {
+	if err := submission.AppendHistory(path, rec); err != nil {
+		log.Printf("[warn] Failed to record history: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/api"
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+)
+
+// writeUserIndex writes the index.md of a --user archive: one row per post of
+// user, newest first, with its parent topic and the saved thread. saved maps
+// canonical topic links to their files; threads without a Markdown file,
+// exported in other formats only, are not linked.
+func writeUserIndex(dir, user string, posts []api.UserPost, saved map[string]string, sync bool) {
+	for link, file := range saved {
+		if _, err := os.Stat(file); err != nil {
+			delete(saved, link)
+		}
+	}
+	path := filepath.Join(dir, "index.md")
+	if err := storage.WriteFileAtomic(path, []byte(buildUserIndex(dir, user, posts, saved)), 0o644, sync); err != nil {
+		log.Printf("[warn] Failed to write %s: %v", path, err)
+		return
+	}
+	fmt.Println(path)
+}
+
+func buildUserIndex(dir, user string, posts []api.UserPost, saved map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Posts by %s\n\n", user)
+	b.WriteString("| Post | Topic | File |\n")
+	b.WriteString("| ---- | ----- | ---- |\n")
+	for _, p := range posts {
+		kind := fmt.Sprintf("Comment %d", p.MessageID)
+		if p.IsTopicPost {
+			kind = "Started topic"
+		}
+		title := p.TopicTitle
+		if title == "" {
+			title = p.TopicLink
+		}
+		file := "-"
+		if path, ok := saved[p.TopicLink]; ok {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				rel = path
+			}
+			rel = filepath.ToSlash(rel)
+			file = fmt.Sprintf("[%s](%s)", indexCell(rel), rel)
+		}
+		fmt.Fprintf(&b, "| %s | [%s](%s) | %s |\n", kind, indexCell(title), p.TopicLink, file)
+	}
+	return b.String()
+}
+
+func indexCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "[", `\[`, "]", `\]`).Replace(strings.TrimSpace(s))
+}
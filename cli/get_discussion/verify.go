@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/shotomorisaki/kaggle_pacakge/cli/get_discussion/internal/storage"
+)
+
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	var dir string
+	flags.StringVar(&dir, "dir", "discussion", "Directory of saved discussions, scanned recursively.")
+	flags.Parse(args)
+
+	problems, err := storage.Verify(dir)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d problems found\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Printf("No problems found in %s\n", dir)
+}